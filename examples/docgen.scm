@@ -1,32 +1,149 @@
-;**x** A string containing the file name of the file to generate a doc from.
-;Returns a string containing documentation generated from the file.
-(define docgen (lambda (x)
-	(begin
-	  (define slurpfile (lambda (x)
-		(bytes->chars (read-bytes (file-size x) (open-input-file x)))))
-	  (define islf (lambda (x) (eqv? x #\lf)))
-	  (define isspace (lambda (x) (eqv? x #\space)))
-
-	  (define flines (split islf (slurpfile x)))
-
-	  (define iter (lambda (li accum)
-		(if (null? li) '()
-		(begin
-		  (define splitline (split isspace (car li)))
-		  (cond
-		    ((eqv? #\; (caar li)) (iter (cdr li) (append accum (append (cdar li) #\space #\space #\lf))))
-		    ((eqv? (string->list "(define") (car splitline)) (cons (append '(#\# #\# #\# #\space) (cadr splitline) #\lf accum #\lf) (iter (cdr li) '())))
-		    (else (iter (cdr li) '())))))))
-	  (list->string (concatenate (iter flines '()))))))
-
-;**inname** The name of the file to generate a doc from.
-;**outname** The name of the file to write the resulting documentation to.
-;Uses docgen on inname and writes the result to the file with the name outname.
-(define docgen-and-write (lambda (inname outname)
-	(begin
-	  (define outfile (open-output-file outname))
-	  ;TODO: display instead of write
-	  (write (docgen inname) outfile)
-	  (flush outfile)
-	  (close-output-port outfile))))
-
+;**in** An input port to read Scheme source from.
+;Returns a string containing documentation generated from the source. Every
+;define gets a heading, followed by the comment lines right above it.
+(define docgen-port (lambda (in)
+	(begin
+	  (define out (open-output-string))
+	  (define doc (open-output-string))
+	  (define defname (lambda (line) (cadr (regexp-match "^\\(define \\(?([^ )]+)" line))))
+	  (port-for-each-line (lambda (line)
+		(if (string-prefix? ";" line)
+		  (begin
+		    (write-string (substring line 1 (string-length line)) doc)
+		    (write-string "  \n" doc))
+		  (begin
+		    (if (regexp-match "^\\(define " line)
+		      (write-string (string-append "### " (defname line) "\n" (get-output-string doc) "\n") out)
+		      #f)
+		    (set! doc (open-output-string)))))
+		in)
+	  (get-output-string out))))
+
+;**s** A string containing Scheme source.
+;Returns a string containing documentation generated from s.
+(define docgen-string (lambda (s)
+	(docgen-port (open-input-string s))))
+
+;**x** A string containing the file name of the file to generate a doc from.
+;Returns a string containing documentation generated from the file.
+(define docgen (lambda (x)
+	(begin
+	  (define in (open-input-file x))
+	  (define doc (docgen-port in))
+	  (close-input-port in)
+	  doc)))
+
+;**inname** The name of the file to generate a doc from.
+;**outname** The name of the file to write the resulting documentation to.
+;Uses docgen on inname and writes the result to the file with the name outname.
+(define docgen-and-write (lambda (inname outname)
+	(begin
+	  (define outfile (open-output-file outname))
+	  (write-string (docgen inname) outfile)
+	  (close-output-port outfile))))
+
+;**in** An input port to read Go source registering builtins from, such as
+;lib/standard_env.go.
+;Returns a hash table from the names of the Go functions the builtins are made
+;from to the first name each one is registered under.
+(define docgen-builtins (lambda (in)
+	(begin
+	  (define names (make-hash-table))
+	  (port-for-each-line (lambda (line)
+		(begin
+		  (define m (regexp-match "NewBuiltIn\\(\"([^\"]+)\", -?[0-9]+, -?[0-9]+, ([A-Za-z0-9_]+)\\)" line))
+		  (if m
+		    (if (hash-table-contains? names (caddr m))
+		      #f
+		      (hash-table-set! names (caddr m) (cadr m)))
+		    #f)))
+		in)
+	  names)))
+
+;**prev** The previous comment line.
+;**line** A comment line.
+;Returns #t if line starts a new line of documentation instead of continuing
+;the sentence in prev, as Go doc comments are wrapped.
+(define docgen-go-break? (lambda (prev line)
+	(if (string-prefix? "**" line)
+	  #t
+	  (if (regexp-match "^(`|Example)" line)
+	    #t
+	    (if (regexp-match "[.:`]$" prev) #t #f)))))
+
+;**a** A list of characters.
+;**b** A list of characters.
+;Returns #t if a comes before b when compared character by character.
+(define docgen-chars<? (lambda (a b)
+	(if (null? b)
+	  #f
+	  (if (null? a)
+	    #t
+	    (if (char=? (car a) (car b))
+	      (docgen-chars<? (cdr a) (cdr b))
+	      (char<? (car a) (car b)))))))
+
+;**in** An input port to read Go source from.
+;**names** A hash table from Go function names to the names of the builtins
+;they are registered as, as returned by docgen-builtins.
+;Returns a string containing documentation generated from the source, in the
+;same format as docgen-port. Every function in names gets a heading with its
+;builtin name, followed by the comment lines right above it. The headings are
+;sorted by name.
+(define docgen-go-port (lambda (in names)
+	(begin
+	  (define docs '())
+	  (define doc (open-output-string))
+	  (define prev "")
+	  (port-for-each-line (lambda (line)
+		(if (string-prefix? "//" line)
+		  (begin
+		    (define text (substring line 2 (string-length line)))
+		    (if (string=? prev "")
+		      #f
+		      (write-string (if (docgen-go-break? prev text) "  \n" " ") doc))
+		    (write-string text doc)
+		    (set! prev text))
+		  (begin
+		    (define m (regexp-match "^func ([A-Za-z0-9_]+)\\(" line))
+		    (if m
+		      (if (hash-table-contains? names (cadr m))
+		        (if (string=? prev "")
+		          #f
+		          (set! docs (cons (list (hash-table-ref names (cadr m)) (get-output-string doc)) docs)))
+		        #f)
+		      #f)
+		    (set! doc (open-output-string))
+		    (set! prev ""))))
+		in)
+	  (define out (open-output-string))
+	  (for-each (lambda (d)
+		(write-string (string-append "### " (car d) "\n" (cadr d) "  \n\n") out))
+		(sort docs (lambda (a b) (docgen-chars<? (string->list (car a)) (string->list (car b))))))
+	  (get-output-string out))))
+
+;**x** The name of a Go source file to generate a doc from.
+;**env** The name of the Go source file registering the builtins, such as
+;lib/standard_env.go.
+;Returns a string containing documentation generated from the file.
+(define docgen-go (lambda (x env)
+	(begin
+	  (define in (open-input-file env))
+	  (define names (docgen-builtins in))
+	  (close-input-port in)
+	  (set! in (open-input-file x))
+	  (define doc (docgen-go-port in names))
+	  (close-input-port in)
+	  doc)))
+
+;**inname** The name of a Go source file to generate a doc from.
+;**env** The name of the Go source file registering the builtins.
+;**outname** The name of the file to write the resulting documentation to.
+;Uses docgen-go on inname and writes the result to the file with the name
+;outname.
+;Example: `(docgen-go-and-write "lib/lists.go" "lib/standard_env.go" "docs/lists.md")`
+(define docgen-go-and-write (lambda (inname env outname)
+	(begin
+	  (define outfile (open-output-file outname))
+	  (write-string (docgen-go inname env) outfile)
+	  (close-output-port outfile))))
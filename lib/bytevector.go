@@ -0,0 +1,177 @@
+package goscheme
+
+import (
+	"bytes"
+	"strconv"
+)
+
+//Bytevectors are stored as a plain byte slice, so like Vector they can be
+//mutated in place but not resized.
+type Bytevector []byte
+
+func (b Bytevector) isExpr() {}
+
+func (b Bytevector) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("#u8(")
+	for i, v := range b {
+		if i != 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(strconv.Itoa(int(v)))
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func bytevector_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(Bytevector)
+	return Boolean(ok)
+}
+
+//byteArg converts args[i] to a byte, failing if it is not an integer in the
+//range 0-255.
+func byteArg(name string, args []Expr, i int) (byte, Expr) {
+	n, ok := args[i].(Number)
+	if !ok || n < 0 || n > 255 || float64(n) != float64(int(n)) {
+		return 0, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a byte."}
+	}
+	return byte(n), nil
+}
+
+func bytevector(e Environment, args ...Expr) Expr {
+	ret := make([]byte, len(args))
+	for i := range args {
+		b, err := byteArg("bytevector", args, i)
+		if err != nil {
+			return err
+		}
+		ret[i] = b
+	}
+	return Bytevector(ret)
+}
+
+func bytevectorappend(e Environment, args ...Expr) Expr {
+	ret := make([]byte, 0)
+	for i, arg := range args {
+		b, ok := arg.(Bytevector)
+		if !ok {
+			return Error{"bytevector-append: Argument " + strconv.Itoa(i+1) + " is not a bytevector."}
+		}
+		ret = append(ret, b...)
+	}
+	return Bytevector(ret)
+}
+
+func bytevectorcopy(e Environment, args ...Expr) Expr {
+	b, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"bytevector-copy: Argument 1 is not a bytevector."}
+	}
+	start, end, err := rangeArgs("bytevector-copy", args, 1, len(b))
+	if err != nil {
+		return err
+	}
+	return Bytevector(append([]byte{}, b[start:end]...))
+}
+
+//(bytevector-copy! to at from [start [end]])
+func bytevectorcopy_(e Environment, args ...Expr) Expr {
+	to, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"bytevector-copy!: Argument 1 is not a bytevector."}
+	}
+	at, err := indexArg("bytevector-copy!", args, 1, len(to))
+	if err != nil {
+		return err
+	}
+	from, ok := args[2].(Bytevector)
+	if !ok {
+		return Error{"bytevector-copy!: Argument 3 is not a bytevector."}
+	}
+	start, end, err := rangeArgs("bytevector-copy!", args, 3, len(from))
+	if err != nil {
+		return err
+	}
+	if at+end-start > len(to) {
+		return Error{"bytevector-copy!: Not enough room in destination bytevector."}
+	}
+	copy(to[at:], from[start:end])
+	return to
+}
+
+func bytevectorlength(e Environment, args ...Expr) Expr {
+	if b, ok := args[0].(Bytevector); !ok {
+		return Error{"bytevector-length: Argument 1 is not a bytevector."}
+	} else {
+		return Number(len(b))
+	}
+}
+
+func bytevectoru8ref(e Environment, args ...Expr) Expr {
+	b, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"bytevector-u8-ref: Argument 1 is not a bytevector."}
+	}
+	k, err := indexArg("bytevector-u8-ref", args, 1, len(b)-1)
+	if err != nil {
+		return err
+	}
+	return Number(b[k])
+}
+
+func bytevectoru8set(e Environment, args ...Expr) Expr {
+	b, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"bytevector-u8-set!: Argument 1 is not a bytevector."}
+	}
+	k, err := indexArg("bytevector-u8-set!", args, 1, len(b)-1)
+	if err != nil {
+		return err
+	}
+	v, err := byteArg("bytevector-u8-set!", args, 2)
+	if err != nil {
+		return err
+	}
+	b[k] = v
+	return b
+}
+
+func makebytevector(e Environment, args ...Expr) Expr {
+	k, ok := args[0].(Number)
+	if !ok || k < 0 {
+		return Error{"make-bytevector: Argument 1 is not a non-negative number."}
+	}
+	ret := make([]byte, int(k))
+	if len(args) == 2 {
+		fill, err := byteArg("make-bytevector", args, 1)
+		if err != nil {
+			return err
+		}
+		for i := range ret {
+			ret[i] = fill
+		}
+	}
+	return Bytevector(ret)
+}
+
+func stringtoutf8(e Environment, args ...Expr) Expr {
+	s, start, end, err := stringAndRange("string->utf8", args, 1)
+	if err != nil {
+		return err
+	}
+	return Bytevector([]byte(string(s[start:end])))
+}
+
+//Invalid UTF-8 sequences are decoded as U+FFFD.
+func utf8tostring(e Environment, args ...Expr) Expr {
+	b, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"utf8->string: Argument 1 is not a bytevector."}
+	}
+	start, end, err := rangeArgs("utf8->string", args, 1, len(b))
+	if err != nil {
+		return err
+	}
+	return NewString(string(b[start:end]))
+}
@@ -0,0 +1,80 @@
+package goscheme
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//Binary files are read and written byte for byte, including bytes that are
+//not valid UTF-8.
+func TestBinaryFile(t *testing.T) {
+	data := []byte{0, 0xff, 0xce, 0xbb, '\n', 0x80}
+	dir := writeFiles(t, map[string]string{"in.bin": string(data)})
+	got := evalString(t, `(define bin-in (open-input-file "`+filepath.Join(dir, "in.bin")+`"))
+(define bin-first (read-u8 bin-in))
+(define bin-rest (read-bytevector 100 bin-in))
+(close-port bin-in)
+(define bin-out (open-output-file "`+filepath.Join(dir, "out.bin")+`"))
+(write-u8 bin-first bin-out)
+(write-bytevector bin-rest bin-out)
+(close-port bin-out)
+bin-rest`)
+	if b, ok := got.(Bytevector); !ok || !bytes.Equal(b, data[1:]) {
+		t.Errorf("read-bytevector after read-u8 returned %s, want %v", Sprint(got), data[1:])
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "out.bin")); !bytes.Equal(b, data) {
+		t.Errorf("The bytes written are %v, want %v", b, data)
+	}
+}
+
+func TestBytevectorPort(t *testing.T) {
+	evalString(t, `(define bp (open-input-bytevector #u8(1 2 3 255 4))) (define buf (make-bytevector 4 0))`)
+	steps := []struct {
+		src, want string
+	}{
+		{"(peek-u8 bp)", "1"},
+		{"(read-u8 bp)", "1"},
+		{"(read-bytevector 2 bp)", "#u8(2 3)"},
+		{"(u8-ready? bp)", "#t"},
+		{"(read-bytevector! buf bp 1)", "2"},
+		{"buf", "#u8(0 255 4 0)"},
+		{"(read-u8 bp)", "#<eof>"},
+		{"(read-bytevector 3 bp)", "#<eof>"},
+	}
+	for _, step := range steps {
+		if got := Sprint(evalString(t, step.src)); got != step.want {
+			t.Fatalf("%s = %s, want %s", step.src, got, step.want)
+		}
+	}
+	if got := Sprint(evalString(t, `(define bo (open-output-bytevector)) (write-u8 65 bo) (write-bytevector #u8(1 2 3) bo 1) (get-output-bytevector bo)`)); got != "#u8(65 2 3)" {
+		t.Errorf("The output bytevector is %s, want #u8(65 2 3)", got)
+	}
+}
+
+func TestBytevectorProcedures(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(utf8->string #u8(206 187 97))`, `"λa"`},
+		{`(string->utf8 "λa")`, "#u8(206 187 97)"},
+		{`(string->utf8 "abc" 1)`, "#u8(98 99)"},
+		{`(bytevector-append #u8(1) #u8() #u8(2 3))`, "#u8(1 2 3)"},
+		{`(bytevector-copy #u8(1 2 3) 1)`, "#u8(2 3)"},
+		{`(bytevector-u8-ref #u8(1 2) 2)`, "bytevector-u8-ref: Index 2 is out of range."},
+		{`(bytevector-u8-set! (make-bytevector 1) 0 256)`, "bytevector-u8-set!: Argument 3 is not a byte."},
+		{`(bytevector-u8-set! (make-bytevector 1) 0 1.5)`, "bytevector-u8-set!: Argument 3 is not a byte."},
+		{`(make-bytevector 2 -1)`, "make-bytevector: Argument 2 is not a byte."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+	_, err := readDatum(newInputPort(strings.NewReader("#u8(1 300)"), nopCloser{}), testEnv(t))
+	if err == nil || err.Error() != "#u8: Element 1 is not a valid u8 value." {
+		t.Errorf("Reading #u8(1 300) gives the error %v", err)
+	}
+}
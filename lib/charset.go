@@ -0,0 +1,503 @@
+package goscheme
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+/*
+CharSet type (SRFI 14)
+A character set is stored as a sorted list of disjoint, non-adjacent rune
+ranges. This keeps sets built from the unicode tables and their complements
+small, and membership tests are a binary search.
+Character sets are immutable, so the linear update (!) procedures of SRFI 14
+are not provided.
+*/
+type CharSet struct {
+	ranges *[]runeRange
+}
+
+//runeRange is an inclusive range of runes.
+type runeRange struct {
+	lo, hi rune
+}
+
+func (c CharSet) isExpr() {}
+
+func (c CharSet) String() string {
+	var b bytes.Buffer
+	b.WriteString("#<char-set")
+	for _, r := range *c.ranges {
+		if r.lo == r.hi {
+			fmt.Fprintf(&b, " %U", r.lo)
+		} else {
+			fmt.Fprintf(&b, " %U-%U", r.lo, r.hi)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func (c CharSet) contains(r rune) bool {
+	rs := *c.ranges
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].hi >= r })
+	return i < len(rs) && rs[i].lo <= r
+}
+
+//newCharSet sorts and merges rs into a CharSet.
+func newCharSet(rs []runeRange) CharSet {
+	sort.Slice(rs, func(i, j int) bool { return rs[i].lo < rs[j].lo })
+	merged := make([]runeRange, 0, len(rs))
+	for _, r := range rs {
+		if n := len(merged); n != 0 && r.lo <= merged[n-1].hi+1 {
+			if r.hi > merged[n-1].hi {
+				merged[n-1].hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return CharSet{&merged}
+}
+
+//tableCharSet creates a CharSet containing every rune in the given tables.
+func tableCharSet(tables ...*unicode.RangeTable) CharSet {
+	var rs []runeRange
+	add := func(lo, hi, stride rune) {
+		if stride == 1 {
+			rs = append(rs, runeRange{lo, hi})
+			return
+		}
+		for c := lo; c <= hi; c += stride {
+			rs = append(rs, runeRange{c, c})
+		}
+	}
+	for _, t := range tables {
+		for _, r := range t.R16 {
+			add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+		}
+		for _, r := range t.R32 {
+			add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+		}
+	}
+	return newCharSet(rs)
+}
+
+func (c CharSet) complement() CharSet {
+	var rs []runeRange
+	next := rune(0)
+	for _, r := range *c.ranges {
+		if r.lo > next {
+			rs = append(rs, runeRange{next, r.lo - 1})
+		}
+		next = r.hi + 1
+	}
+	if next <= unicode.MaxRune {
+		rs = append(rs, runeRange{next, unicode.MaxRune})
+	}
+	return CharSet{&rs}
+}
+
+func (c CharSet) union(o CharSet) CharSet {
+	rs := make([]runeRange, 0, len(*c.ranges)+len(*o.ranges))
+	rs = append(append(rs, *c.ranges...), *o.ranges...)
+	return newCharSet(rs)
+}
+
+func (c CharSet) intersection(o CharSet) CharSet {
+	return c.complement().union(o.complement()).complement()
+}
+
+func (c CharSet) difference(o CharSet) CharSet {
+	return c.intersection(o.complement())
+}
+
+func (c CharSet) size() int {
+	n := 0
+	for _, r := range *c.ranges {
+		n += int(r.hi-r.lo) + 1
+	}
+	return n
+}
+
+func (c CharSet) runes() []rune {
+	ret := make([]rune, 0, c.size())
+	for _, r := range *c.ranges {
+		for i := r.lo; i <= r.hi; i++ {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func (c CharSet) equal(o CharSet) bool {
+	if len(*c.ranges) != len(*o.ranges) {
+		return false
+	}
+	for i, r := range *c.ranges {
+		if r != (*o.ranges)[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//The standard character sets. The names are bound in StandardEnv with a
+//"char-set:" prefix.
+var standardCharSets = map[string]CharSet{
+	"ascii":        newCharSet([]runeRange{{0, 0x7F}}),
+	"blank":        tableCharSet(unicode.Zs).union(newCharSet([]runeRange{{'\t', '\t'}})),
+	"digit":        tableCharSet(unicode.Nd),
+	"empty":        newCharSet(nil),
+	"full":         newCharSet([]runeRange{{0, unicode.MaxRune}}),
+	"graphic":      tableCharSet(unicode.L, unicode.M, unicode.N, unicode.P, unicode.S),
+	"hex-digit":    newCharSet([]runeRange{{'0', '9'}, {'A', 'F'}, {'a', 'f'}}),
+	"iso-control":  newCharSet([]runeRange{{0, 0x1F}, {0x7F, 0x9F}}),
+	"letter":       tableCharSet(unicode.L),
+	"letter+digit": tableCharSet(unicode.L, unicode.Nd),
+	"lower-case":   tableCharSet(unicode.Lower),
+	"printing":     tableCharSet(unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.White_Space),
+	"punctuation":  tableCharSet(unicode.P),
+	"symbol":       tableCharSet(unicode.S),
+	"title-case":   tableCharSet(unicode.Title),
+	"upper-case":   tableCharSet(unicode.Upper),
+	"whitespace":   tableCharSet(unicode.White_Space),
+}
+
+//charSetArgs checks that every argument from args[from] onwards is a char-set.
+func charSetArgs(name string, args []Expr, from int) ([]CharSet, Expr) {
+	ret := make([]CharSet, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		cs, ok := args[i].(CharSet)
+		if !ok {
+			return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a char-set."}
+		}
+		ret = append(ret, cs)
+	}
+	return ret, nil
+}
+
+//charArgs checks that every argument from args[from] onwards is a character.
+func charArgs(name string, args []Expr, from int) ([]runeRange, Expr) {
+	ret := make([]runeRange, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		c, ok := args[i].(Character)
+		if !ok {
+			return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a character."}
+		}
+		ret = append(ret, runeRange{rune(c), rune(c)})
+	}
+	return ret, nil
+}
+
+//baseArg returns the ranges of the optional base char-set at args[i].
+func baseArg(name string, args []Expr, i int) ([]runeRange, Expr) {
+	if len(args) <= i {
+		return nil, nil
+	}
+	cs, ok := args[i].(CharSet)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a char-set."}
+	}
+	return append([]runeRange{}, *cs.ranges...), nil
+}
+
+func charset(e Environment, args ...Expr) Expr {
+	rs, err := charArgs("char-set", args, 0)
+	if err != nil {
+		return err
+	}
+	return newCharSet(rs)
+}
+
+func charset_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(CharSet)
+	return Boolean(ok)
+}
+
+func charsetadjoin(e Environment, args ...Expr) Expr {
+	cs, ok := args[0].(CharSet)
+	if !ok {
+		return Error{"char-set-adjoin: Argument 1 is not a char-set."}
+	}
+	rs, err := charArgs("char-set-adjoin", args, 1)
+	if err != nil {
+		return err
+	}
+	return cs.union(newCharSet(rs))
+}
+
+func charsetcomplement(e Environment, args ...Expr) Expr {
+	if cs, ok := args[0].(CharSet); !ok {
+		return Error{"char-set-complement: Argument 1 is not a char-set."}
+	} else {
+		return cs.complement()
+	}
+}
+
+func charsetcontains_(e Environment, args ...Expr) Expr {
+	cs, ok := args[0].(CharSet)
+	if !ok {
+		return Error{"char-set-contains?: Argument 1 is not a char-set."}
+	}
+	c, ok := args[1].(Character)
+	if !ok {
+		return Error{"char-set-contains?: Argument 2 is not a character."}
+	}
+	return Boolean(cs.contains(rune(c)))
+}
+
+//Returns the number of characters in the set that satisfy pred.
+func charsetcount(e Environment, args ...Expr) Expr {
+	pred, ok := args[0].(Proc)
+	if !ok {
+		return Error{"char-set-count: Argument 1 is not a procedure."}
+	}
+	cs, ok := args[1].(CharSet)
+	if !ok {
+		return Error{"char-set-count: Argument 2 is not a char-set."}
+	}
+	n := 0
+	for _, c := range cs.runes() {
+		r := callProc(e, pred, Character(c))
+		if _, ok := r.(Error); ok {
+			return r
+		}
+		if truthy(r) {
+			n++
+		}
+	}
+	return Number(n)
+}
+
+func charsetdelete(e Environment, args ...Expr) Expr {
+	cs, ok := args[0].(CharSet)
+	if !ok {
+		return Error{"char-set-delete: Argument 1 is not a char-set."}
+	}
+	rs, err := charArgs("char-set-delete", args, 1)
+	if err != nil {
+		return err
+	}
+	return cs.difference(newCharSet(rs))
+}
+
+func charsetdifference(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set-difference", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := css[0]
+	for _, cs := range css[1:] {
+		ret = ret.difference(cs)
+	}
+	return ret
+}
+
+//(char-set-filter pred cs [base]) returns the characters of cs that satisfy
+//pred, added to base.
+func charsetfilter(e Environment, args ...Expr) Expr {
+	pred, ok := args[0].(Proc)
+	if !ok {
+		return Error{"char-set-filter: Argument 1 is not a procedure."}
+	}
+	cs, ok := args[1].(CharSet)
+	if !ok {
+		return Error{"char-set-filter: Argument 2 is not a char-set."}
+	}
+	rs, err := baseArg("char-set-filter", args, 2)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs.runes() {
+		r := callProc(e, pred, Character(c))
+		if _, ok := r.(Error); ok {
+			return r
+		}
+		if truthy(r) {
+			rs = append(rs, runeRange{c, c})
+		}
+	}
+	return newCharSet(rs)
+}
+
+//(char-set-fold kons knil cs) calls (kons c acc) for every character in the set.
+func charsetfold(e Environment, args ...Expr) Expr {
+	kons, ok := args[0].(Proc)
+	if !ok {
+		return Error{"char-set-fold: Argument 1 is not a procedure."}
+	}
+	cs, ok := args[2].(CharSet)
+	if !ok {
+		return Error{"char-set-fold: Argument 3 is not a char-set."}
+	}
+	acc := args[1]
+	for _, c := range cs.runes() {
+		acc = callProc(e, kons, Character(c), acc)
+		if _, ok := acc.(Error); ok {
+			return acc
+		}
+	}
+	return acc
+}
+
+func charsetforeach(e Environment, args ...Expr) Expr {
+	proc, ok := args[0].(Proc)
+	if !ok {
+		return Error{"char-set-for-each: Argument 1 is not a procedure."}
+	}
+	cs, ok := args[1].(CharSet)
+	if !ok {
+		return Error{"char-set-for-each: Argument 2 is not a char-set."}
+	}
+	for _, c := range cs.runes() {
+		if r, ok := callProc(e, proc, Character(c)).(Error); ok {
+			return r
+		}
+	}
+	return Boolean(true)
+}
+
+func charsetintersection(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set-intersection", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := standardCharSets["full"]
+	for _, cs := range css {
+		ret = ret.intersection(cs)
+	}
+	return ret
+}
+
+func charsetsize(e Environment, args ...Expr) Expr {
+	if cs, ok := args[0].(CharSet); !ok {
+		return Error{"char-set-size: Argument 1 is not a char-set."}
+	} else {
+		return Number(cs.size())
+	}
+}
+
+func charsettolist(e Environment, args ...Expr) Expr {
+	cs, ok := args[0].(CharSet)
+	if !ok {
+		return Error{"char-set->list: Argument 1 is not a char-set."}
+	}
+	rs := cs.runes()
+	ret := make([]Expr, len(rs))
+	for i, r := range rs {
+		ret[i] = Character(r)
+	}
+	return SliceToExprList(ret)
+}
+
+func charsettostring(e Environment, args ...Expr) Expr {
+	if cs, ok := args[0].(CharSet); !ok {
+		return Error{"char-set->string: Argument 1 is not a char-set."}
+	} else {
+		return runesToString(cs.runes())
+	}
+}
+
+func charsetunion(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set-union", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := standardCharSets["empty"]
+	for _, cs := range css {
+		ret = ret.union(cs)
+	}
+	return ret
+}
+
+func charsetxor(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set-xor", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := standardCharSets["empty"]
+	for _, cs := range css {
+		ret = ret.union(cs).difference(ret.intersection(cs))
+	}
+	return ret
+}
+
+//(char-set= cs ...) is true if all the sets contain the same characters.
+func charseteq(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set=", args, 0)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(css); i++ {
+		if !css[i].equal(css[0]) {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+//(char-set<= cs ...) is true if every set is a subset of the next one.
+func charsetle(e Environment, args ...Expr) Expr {
+	css, err := charSetArgs("char-set<=", args, 0)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(css); i++ {
+		if !css[i-1].difference(css[i]).equal(standardCharSets["empty"]) {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+func listtocharset(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"list->char-set: Argument 1 is not a list."}
+	}
+	rs, err := charArgs("list->char-set", ExprListToSlice(l), 0)
+	if err != nil {
+		return Error{"list->char-set: All members of list must be characters."}
+	}
+	base, err := baseArg("list->char-set", args, 1)
+	if err != nil {
+		return err
+	}
+	return newCharSet(append(rs, base...))
+}
+
+func stringtocharset(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string->char-set: Argument 1 is not a string."}
+	}
+	rs, err := baseArg("string->char-set", args, 1)
+	if err != nil {
+		return err
+	}
+	for _, c := range *s.runes {
+		rs = append(rs, runeRange{c, c})
+	}
+	return newCharSet(rs)
+}
+
+//(ucs-range->char-set lo hi) contains the code points from lo up to but not
+//including hi.
+func ucsrangetocharset(e Environment, args ...Expr) Expr {
+	lo, err := indexArg("ucs-range->char-set", args, 0, unicode.MaxRune+1)
+	if err != nil {
+		return err
+	}
+	hi, err := indexArg("ucs-range->char-set", args, 1, unicode.MaxRune+1)
+	if err != nil {
+		return err
+	}
+	if lo >= hi {
+		return standardCharSets["empty"]
+	}
+	return newCharSet([]runeRange{{rune(lo), rune(hi - 1)}})
+}
@@ -0,0 +1,62 @@
+package goscheme
+
+import (
+	"testing"
+	"unicode"
+)
+
+//The set algebra on ranges agrees with testing membership of each set.
+func TestCharSetAlgebra(t *testing.T) {
+	a := standardCharSets["letter"]
+	b := newCharSet([]runeRange{{'0', 'z'}, {0x3B0, 0x3C0}, {0x10FFF0, unicode.MaxRune}})
+	samples := []rune{0, '/', '0', '9', 'A', 'Z', '_', 'a', 'z', '{', 0x3AF, 0x3B0, 0x3BB, 0x3C0, 0x3C1, 0x10FFEF, 0x10FFF0, unicode.MaxRune}
+	sets := []struct {
+		name string
+		set  CharSet
+		want func(inA, inB bool) bool
+	}{
+		{"union", a.union(b), func(x, y bool) bool { return x || y }},
+		{"intersection", a.intersection(b), func(x, y bool) bool { return x && y }},
+		{"difference", a.difference(b), func(x, y bool) bool { return x && !y }},
+		{"complement", b.complement(), func(x, y bool) bool { return !y }},
+	}
+	for _, s := range sets {
+		for _, r := range samples {
+			if got, want := s.set.contains(r), s.want(a.contains(r), b.contains(r)); got != want {
+				t.Errorf("The %s contains %U: %v, want %v", s.name, r, got, want)
+			}
+		}
+	}
+	if !b.complement().complement().equal(b) {
+		t.Errorf("The complement of the complement of %s is not the same set", b)
+	}
+	if n := newCharSet([]runeRange{{'a', 'c'}, {'d', 'f'}, {'b', 'b'}}); len(*n.ranges) != 1 || n.size() != 6 {
+		t.Errorf("Adjacent and overlapping ranges are not merged: %s", n)
+	}
+}
+
+func TestUnicodeCharacters(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`#\x41`, `#\A`},
+		{`#\x3bb`, `#\λ`},
+		{`#\x`, `#\x`},
+		{`(digit-value #\7)`, "7"},
+		{`(digit-value #\x0664)`, "4"},
+		{`(digit-value #\a)`, "#f"},
+		{`(char-foldcase #\Σ)`, `#\σ`},
+		{`(char-general-category #\a)`, "Ll"},
+		{`(char-set-contains? char-set:letter #\λ)`, "#t"},
+		{`(char-set-contains? char-set:digit #\x0664)`, "#t"},
+		{`(char-set-size (char-set-intersection char-set:hex-digit char-set:letter))`, "12"},
+		{`(char-set->list (char-set-difference (string->char-set "abcd") (char-set #\b #\c)))`, `(#\a #\d)`},
+		{`(char-set #\a #\b #\c #\x)`, "#<char-set U+0061-U+0063 U+0078>"},
+		{`(digit-value 7)`, "digit-value: Argument 1 is not a char."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
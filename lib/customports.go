@@ -0,0 +1,145 @@
+package goscheme
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+/*
+Custom ports are backed by Scheme procedures. They are adapted to io.Reader,
+io.Writer and io.Closer so that they get the same buffering as every other
+port.
+*/
+
+//procReader calls a procedure for more input whenever the bytes from the last
+//call have been used up. The procedure returns a string, character or
+//bytevector, or the EOF object when there is no more input. An empty string or
+//bytevector is also taken as the end of the input.
+type procReader struct {
+	e    Environment
+	read Proc
+	buf  []byte
+}
+
+func (r *procReader) Read(b []byte) (int, error) {
+	if len(r.buf) == 0 {
+		switch v := callProc(r.e, r.read).(type) {
+		case EOFObject:
+			return 0, io.EOF
+		case String:
+			r.buf = []byte(string(*v.runes))
+		case Character:
+			r.buf = []byte(string(rune(v)))
+		case Bytevector:
+			r.buf = append([]byte{}, v...)
+		case Error:
+			return 0, v
+		default:
+			return 0, Error{"Custom input port: The read procedure returned " + Sprint(v) + "."}
+		}
+		if len(r.buf) == 0 {
+			return 0, io.EOF
+		}
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+//procWriter calls a procedure with each string written to the port. Bytes
+//that end in the middle of a UTF-8 sequence are held back until the rest of
+//the character has been written.
+type procWriter struct {
+	e       Environment
+	write   Proc
+	pending []byte
+}
+
+func (w *procWriter) Write(b []byte) (int, error) {
+	w.pending = append(w.pending, b...)
+	end := len(w.pending)
+	//Back up to the start of the last character and check if it is complete.
+	for i := 0; i < utf8.UTFMax && end > 0 && !utf8.RuneStart(w.pending[end-1]); i++ {
+		end--
+	}
+	if end > 0 && !utf8.FullRune(w.pending[end-1:]) {
+		end--
+	} else {
+		end = len(w.pending)
+	}
+	if end == 0 {
+		return len(b), nil
+	}
+	s := string(w.pending[:end])
+	w.pending = append([]byte{}, w.pending[end:]...)
+	if r, ok := callProc(w.e, w.write, NewString(s)).(Error); ok {
+		return 0, r
+	}
+	return len(b), nil
+}
+
+//procCloser calls a thunk when the port is closed, if one was given.
+type procCloser struct {
+	e     Environment
+	close Proc
+}
+
+func (c procCloser) Close() error {
+	if c.close == nil {
+		return nil
+	}
+	if r, ok := callProc(c.e, c.close).(Error); ok {
+		return r
+	}
+	return nil
+}
+
+//closeArg returns the optional close thunk in args[i].
+func closeArg(name string, args []Expr, i int) (Proc, Expr) {
+	if len(args) <= i {
+		return nil, nil
+	}
+	p, ok := args[i].(Proc)
+	if !ok {
+		return nil, Error{name + ": Argument 2 is not a procedure."}
+	}
+	return p, nil
+}
+
+//(make-custom-input-port read [close]) creates an input port that gets its
+//input by calling the thunk read.
+func makecustominputport(e Environment, args ...Expr) Expr {
+	read, ok := args[0].(Proc)
+	if !ok {
+		return Error{"make-custom-input-port: Argument 1 is not a procedure."}
+	}
+	close, err := closeArg("make-custom-input-port", args, 1)
+	if err != nil {
+		return err
+	}
+	return newInputPort(&procReader{e: e, read: read}, procCloser{e, close})
+}
+
+//(make-custom-output-port write [close]) creates an output port that calls
+//write with a string whenever its buffer is flushed.
+func makecustomoutputport(e Environment, args ...Expr) Expr {
+	write, ok := args[0].(Proc)
+	if !ok {
+		return Error{"make-custom-output-port: Argument 1 is not a procedure."}
+	}
+	close, err := closeArg("make-custom-output-port", args, 1)
+	if err != nil {
+		return err
+	}
+	return newOutputPort(&procWriter{e: e, write: write}, procCloser{e, close})
+}
+
+//(make-pipe) returns a list of an input port and an output port connected to
+//it. Anything written to the output port can be read from the input port once
+//the output port is flushed, and closing the output port ends the input.
+//Writes block until the data has been read, so the two ends are meant to be
+//used from different goroutines.
+func makepipe(e Environment, args ...Expr) Expr {
+	r, w := io.Pipe()
+	return SliceToExprList([]Expr{newInputPort(r, r), newOutputPort(w, w)})
+}
@@ -0,0 +1,78 @@
+package goscheme
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+//The write procedure of a custom output port is only given whole characters,
+//even when a write ends in the middle of one.
+func TestProcWriterSplitsCharacters(t *testing.T) {
+	evalString(t, `(define written '()) (define collect (lambda (s) (set! written (cons s written))))`)
+	w := &procWriter{e: GlobalEnv, write: evalString(t, "collect").(Proc)}
+	b := []byte("aλb€")
+	for _, chunk := range [][]byte{b[:2], b[2:4], b[4:6], b[6:]} {
+		if n, err := w.Write(chunk); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%v) = %d, %v", chunk, n, err)
+		}
+	}
+	if got := Sprint(evalString(t, "(reverse written)")); got != `("a" "λb" "€")` {
+		t.Errorf("The write procedure was called with %s", got)
+	}
+}
+
+func TestCustomPorts(t *testing.T) {
+	evalString(t, `(define custom-out '())
+(define closed #f)
+(define op (make-custom-output-port
+  (lambda (s) (set! custom-out (cons s custom-out)))
+  (lambda () (set! closed #t))))`)
+	evalString(t, `(write-string "hello" op) (write-char #\space op)`)
+	if got := Sprint(evalString(t, "custom-out")); got != "()" {
+		t.Errorf("The write procedure was called before the port was flushed: %s", got)
+	}
+	evalString(t, `(close-port op)`)
+	if got := Sprint(evalString(t, "(list custom-out closed)")); got != `(("hello ") #t)` {
+		t.Errorf("Closing the port gives %s, want ((\"hello \") #t)", got)
+	}
+	want := "Custom input port: The read procedure returned 5."
+	if got := Sprint(evalString(t, "(read-char (make-custom-input-port (lambda () 5)))")); got != want {
+		t.Errorf("A read procedure returning 5 gives %s, want %s", got, want)
+	}
+	want = "make-custom-input-port: Argument 2 is not a procedure."
+	if got := Sprint(evalString(t, "(make-custom-input-port (lambda () \"\") 5)")); got != want {
+		t.Errorf("A close argument of 5 gives %s, want %s", got, want)
+	}
+}
+
+//Go readers and writers can be used as ports.
+func TestGoPorts(t *testing.T) {
+	env := testEnv(t)
+	var out bytes.Buffer
+	env.Local["go-in"] = NewInputPort(strings.NewReader("first line\nsecond"))
+	env.Local["go-out"] = NewOutputPort(&out)
+	defer delete(env.Local, "go-in")
+	defer delete(env.Local, "go-out")
+	evalString(t, `(write-string (read-line go-in) go-out) (write (read go-in) go-out) (flush go-out)`)
+	if out.String() != "first linesecond" {
+		t.Errorf("The Go writer got %q, want \"first linesecond\"", out.String())
+	}
+}
+
+//What is written to one end of a pipe is read from the other once it is
+//flushed, and closing the output port ends the input.
+func TestMakePipe(t *testing.T) {
+	evalString(t, `(define pipe (make-pipe)) (define pipe-in (car pipe)) (define pipe-out (cadr pipe))`)
+	d, err := readOne(t, `(begin (write-string "through the pipe" pipe-out) (close-port pipe-out))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Eval(d, GlobalEnv)
+	if got := Sprint(evalString(t, "(read-line pipe-in)")); got != `"through the pipe"` {
+		t.Errorf("The pipe gives %s", got)
+	}
+	if got := Sprint(evalString(t, "(read-char pipe-in)")); got != "#<eof>" {
+		t.Errorf("The pipe gives %s after the output port is closed", got)
+	}
+}
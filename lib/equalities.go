@@ -1,6 +1,7 @@
 package goscheme
 
 import (
+	"bytes"
 	"reflect"
 	"strconv"
 )
@@ -55,25 +56,126 @@ func eq(e Environment, args ...Expr) Expr {
 
 //TODO: char=?, exact/inexact
 func eqv(e Environment, args ...Expr) Expr {
-	if v, ok := args[0].(ExprList); ok {
-		if v2, ok2 := args[1].(ExprList); ok2 {
-			return Boolean(listeqv(v, v2))
-		}
-	}
+	return Boolean(isEqv(args[0], args[1]))
+}
 
-	if v, ok := args[0].(BuiltIn); ok {
-		if v2, ok2 := args[1].(BuiltIn); ok2 {
-			return Boolean(builtineqv(v, v2))
+//isEqv is eqv? for use from Go. Strings and all kinds of vectors are eqv? only
+//if they share storage.
+func isEqv(a, b Expr) bool {
+	switch v := a.(type) {
+	case ExprList:
+		if v2, ok := b.(ExprList); ok {
+			return listeqv(v, v2)
+		}
+	case BuiltIn:
+		if v2, ok := b.(BuiltIn); ok {
+			return builtineqv(v, v2)
+		}
+	case UserProc:
+		if v2, ok := b.(UserProc); ok {
+			return isEqv(v.body, v2.body)
+		}
+	case String:
+		if v2, ok := b.(String); ok {
+			return v.runes == v2.runes
+		}
+	case Vector:
+		if v2, ok := b.(Vector); ok {
+			return len(v) == len(v2) && (len(v) == 0 || &v[0] == &v2[0])
+		}
+	case Bytevector:
+		if v2, ok := b.(Bytevector); ok {
+			return len(v) == len(v2) && (len(v) == 0 || &v[0] == &v2[0])
 		}
+	case homogeneous:
+		if v2, ok := b.(homogeneous); ok {
+			return v.kind() == v2.kind() && v.Len() == v2.Len() && (v.Len() == 0 || v.base() == v2.base())
+		}
+	}
+	//Comparing two values of the same uncomparable type would panic.
+	if a != nil && !reflect.TypeOf(a).Comparable() {
+		return false
 	}
+	return a == b
+}
+
+func equal(e Environment, args ...Expr) Expr {
+	return Boolean(isEqual(args[0], args[1]))
+}
+
+//isEqual is equal? for use from Go. It compares strings, vectors,
+//bytevectors and homogeneous vectors by their contents, and lists and vectors element by element.
+func isEqual(a, b Expr) bool {
+	return equalNodes(a, b, map[[2]*Expr]bool{})
+}
 
-	if v, ok := args[0].(UserProc); ok {
-		if v2, ok2 := args[1].(UserProc); ok2 {
-			return eqv(e, v.body, v2.body).(Boolean)
+//equalNodes is isEqual. seen holds the pairs of cells and vectors that are
+//already being compared, keyed by their first element as in sharedNodes.
+//Meeting such a pair again means the structure is circular, and the pair is
+//taken to be equal so that the comparison ends.
+func equalNodes(a, b Expr, seen map[[2]*Expr]bool) bool {
+	switch v := a.(type) {
+	case ExprList:
+		v2, ok := b.(ExprList)
+		if !ok {
+			return false
+		}
+		for {
+			if v.car == nil || v2.car == nil {
+				return v.car == nil && v2.car == nil
+			}
+			k := [2]*Expr{v.car, v2.car}
+			if seen[k] {
+				return true
+			}
+			seen[k] = true
+			if !equalNodes(*v.car, *v2.car, seen) {
+				return false
+			}
+			//The cdr of the last cell of a dotted list is nil.
+			if v.cdr == nil || v2.cdr == nil {
+				return v.cdr == nil && v2.cdr == nil
+			}
+			v, v2 = *v.cdr, *v2.cdr
+		}
+	case String:
+		v2, ok := b.(String)
+		return ok && string(*v.runes) == string(*v2.runes)
+	case Vector:
+		v2, ok := b.(Vector)
+		if !ok || len(v) != len(v2) {
+			return false
+		}
+		if len(v) == 0 {
+			return true
+		}
+		k := [2]*Expr{&v[0], &v2[0]}
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+		for i := range v {
+			if !equalNodes(v[i], v2[i], seen) {
+				return false
+			}
+		}
+		return true
+	case Bytevector:
+		v2, ok := b.(Bytevector)
+		return ok && bytes.Equal(v, v2)
+	case homogeneous:
+		v2, ok := b.(homogeneous)
+		if !ok || v.kind() != v2.kind() || v.Len() != v2.Len() {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if v.ref(i) != v2.ref(i) {
+				return false
+			}
 		}
+		return true
 	}
-
-	return Boolean(args[0] == args[1])
+	return isEqv(a, b)
 }
 
 //This is a bad approach, but as far as I can tell there is no better way.
@@ -83,22 +185,9 @@ func builtineqv(a, b BuiltIn) bool {
 	return aa.Pointer() == bb.Pointer()
 }
 
+//listeqv compares pairs by identity. An ExprList is a copy of the cell it
+//was taken from, so two pairs are the same if they share their car and cdr.
+//The elements are never looked at, so that circular lists can be compared.
 func listeqv(a, b ExprList) bool {
-	if a.Length() != b.Length() {
-		return false
-	}
-	ita := a
-	itb := b
-	for {
-		if ita.car == nil {
-			break
-		}
-		//fuck.
-		if !bool(eqv(Environment{}, *ita.car, *itb.car).(Boolean)) {
-			return false
-		}
-		ita = *ita.cdr
-		itb = *itb.cdr
-	}
-	return true
+	return a.car == b.car && a.cdr == b.cdr
 }
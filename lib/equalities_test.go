@@ -0,0 +1,53 @@
+package goscheme
+
+import "testing"
+
+func TestIsEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"(cons 1 2)", "(cons 1 2)", true},
+		{"(cons 1 2)", "(cons 1 3)", false},
+		{"(cons 1 2)", "(list 1 2)", false},
+		{"(list 1 (cons 2 3))", "(list 1 (cons 2 3))", true},
+		{"'(a b . c)", "'(a b . c)", true},
+		{"'#0=(a b . #0#)", "'#0=(a b . #0#)", true},
+		{"'#0=(a b . #0#)", "'#0=(a b a b . #0#)", true},
+		{"'#0=(a b . #0#)", "'#0=(a c . #0#)", false},
+		{"'#0=#(1 #0#)", "'#0=#(1 #0#)", true},
+		{"'#0=(1 #0#)", "'#1=(1 #1#)", true},
+	}
+	for _, test := range tests {
+		a, b := evalString(t, test.a), evalString(t, test.b)
+		if got := isEqual(a, b); got != test.want {
+			t.Errorf("(equal? %s %s) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+	x := evalString(t, "'#0=(a b . #0#)")
+	if !isEqual(x, x) {
+		t.Errorf("A circular list is not equal? to itself.")
+	}
+}
+
+//eqv? compares pairs by identity, without looking at their elements.
+func TestIsEqv(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(define c (circular-list 1 2)) (eqv? c c)`, "#t"},
+		{`(eq? c (cddr c))`, "#t"},
+		{`(eqv? c (cdr c))`, "#f"},
+		{`(eqv? (list 1 2) (list 1 2))`, "#f"},
+		{`(eqv? '() '())`, "#t"},
+		{`(define l (list 1 2)) (memv l (list 0 l))`, "((1 2))"},
+		{`(define h (make-hash-table eqv?)) (hash-table-set! h c 1) (hash-table-ref/default h c 0)`, "1"},
+		{`(hash-table-ref/default h (circular-list 1 2) 0)`, "0"},
+		{`(string-ci=? "aB" "Ab")`, "#t"},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
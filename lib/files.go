@@ -0,0 +1,209 @@
+package goscheme
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+//halfCloser closes a file shared by the input and output side of a read-write
+//port once both sides have been closed.
+type halfCloser struct {
+	c    io.Closer
+	open *int
+}
+
+func (h halfCloser) Close() error {
+	*h.open--
+	if *h.open == 0 {
+		return h.c.Close()
+	}
+	return nil
+}
+
+//(close-port port) closes both sides of a port.
+func closeport(e Environment, args ...Expr) Expr {
+	p, ok := args[0].(Port)
+	if !ok || p.port == nil {
+		return Error{"close-port: Argument 1 is not a port."}
+	}
+	if p.w != nil {
+		if r, ok := closeoutport(e, p).(Error); ok {
+			return r
+		}
+	}
+	if p.r != nil {
+		if r, ok := closeinport(e, p).(Error); ok {
+			return r
+		}
+	}
+	return Boolean(true)
+}
+
+/*
+(open-file filename mode ...) opens a file with the given modes, which are
+symbols:
+	read             - Open the file for reading. This is the default.
+	write            - Open the file for writing, creating it if needed and
+	                   truncating it.
+	append           - Open the file for writing at the end, creating it if
+	                   needed.
+	read-write       - Open the file for both reading and writing, creating it
+	                   if needed. Like with C stdio, set-port-position! must be
+	                   used when switching between reading and writing.
+	create-exclusive - Fail if the file already exists. Implies write if no
+	                   other mode allows writing.
+*/
+func openfile(e Environment, args ...Expr) Expr {
+	name, ok := args[0].(String)
+	if !ok {
+		return Error{"open-file: Argument 1 is not a string."}
+	}
+	var read, write bool
+	flags := 0
+	for _, m := range args[1:] {
+		s, ok := m.(Symbol)
+		if !ok {
+			return Error{"open-file: Mode " + Sprint(m) + " is not a symbol."}
+		}
+		switch s.name {
+		case "read":
+			read = true
+		case "write":
+			write = true
+			flags |= os.O_CREATE | os.O_TRUNC
+		case "append":
+			write = true
+			flags |= os.O_CREATE | os.O_APPEND
+		case "read-write":
+			read, write = true, true
+			flags |= os.O_CREATE
+		case "create-exclusive":
+			flags |= os.O_CREATE | os.O_EXCL
+		default:
+			return Error{"open-file: Unknown mode " + s.name + "."}
+		}
+	}
+	if flags&os.O_EXCL != 0 && !write {
+		write = true
+	}
+	switch {
+	case read && write:
+		flags |= os.O_RDWR
+	case write:
+		flags |= os.O_WRONLY
+	default:
+		read = true
+		flags |= os.O_RDONLY
+	}
+	f, err := os.OpenFile(unwrapString(name), flags, 0666)
+	if err != nil {
+		return Error{err.Error()}
+	}
+	switch {
+	case read && write:
+		open := 2
+		p := newInputPort(f, halfCloser{f, &open})
+		p.wclose, p.w, p.mu = halfCloser{f, &open}, bufio.NewWriter(f), &sync.Mutex{}
+		registerOutputPort(p.port)
+		return p
+	case write:
+		return newOutputPort(f, f)
+	}
+	return newInputPort(f, f)
+}
+
+//seekablePort checks that arg is an open port that supports positioning.
+func seekablePort(name string, arg Expr) (Port, Expr) {
+	p, ok := arg.(Port)
+	if !ok || p.port == nil || (p.r == nil && p.w == nil) {
+		return Port{}, Error{name + ": Argument 1 is not an open port."}
+	}
+	if p.seek == nil {
+		return Port{}, Error{name + ": The port does not support positioning."}
+	}
+	return p, nil
+}
+
+//(port-position port) returns the position of the port in bytes, taking into
+//account what has been buffered but not yet read or written.
+func portposition(e Environment, args ...Expr) Expr {
+	p, err := seekablePort("port-position", args[0])
+	if err != nil {
+		return err
+	}
+	if p.w != nil {
+		if ferr := p.flush(); ferr != nil {
+			return Error{ferr.Error()}
+		}
+	}
+	pos, serr := p.seek.Seek(0, io.SeekCurrent)
+	if serr != nil {
+		return Error{serr.Error()}
+	}
+	if p.r != nil {
+		pos -= int64(p.r.Buffered())
+	}
+	return Number(pos)
+}
+
+//(set-port-position! port pos) flushes any pending output and discards any
+//buffered input before moving to pos.
+func setportposition(e Environment, args ...Expr) Expr {
+	p, err := seekablePort("set-port-position!", args[0])
+	if err != nil {
+		return err
+	}
+	pos, ok := args[1].(Number)
+	if !ok || pos < 0 {
+		return Error{"set-port-position!: Argument 2 is not a non-negative number."}
+	}
+	if p.w != nil {
+		if ferr := p.flush(); ferr != nil {
+			return Error{ferr.Error()}
+		}
+	}
+	if _, serr := p.seek.Seek(int64(pos), io.SeekStart); serr != nil {
+		return Error{serr.Error()}
+	}
+	if p.r != nil {
+		p.r.Reset(p.src)
+	}
+	return Boolean(true)
+}
+
+//(truncate-file file [length]) truncates the file, given either as a file name
+//or an open output port, to length bytes. length defaults to 0.
+func truncatefile(e Environment, args ...Expr) Expr {
+	length := Number(0)
+	if len(args) == 2 {
+		l, ok := args[1].(Number)
+		if !ok || l < 0 {
+			return Error{"truncate-file: Argument 2 is not a non-negative number."}
+		}
+		length = l
+	}
+	var err error
+	switch v := args[0].(type) {
+	case String:
+		err = os.Truncate(unwrapString(v), int64(length))
+	case Port:
+		if v.port == nil || v.w == nil {
+			return Error{"truncate-file: Argument 1 is not an output file port."}
+		}
+		f, ok := v.seek.(*os.File)
+		if !ok {
+			return Error{"truncate-file: Argument 1 is not an output file port."}
+		}
+		if err = v.flush(); err == nil {
+			err = f.Truncate(int64(length))
+		}
+	default:
+		return Error{"truncate-file: Argument 1 is not a string or a port."}
+	}
+	if err != nil {
+		return Error{err.Error()}
+	}
+	return Boolean(true)
+}
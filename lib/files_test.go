@@ -0,0 +1,82 @@
+package goscheme
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestTruncateFile(t *testing.T) {
+	name := filepath.Join(writeFiles(t, nil), "truncate.txt")
+	evalString(t, `(define truncated (open-output-file "`+name+`"))`)
+	if got := Sprint(evalString(t, `(write-string "0123456789" truncated) (truncate-file truncated 6)`)); got != "#t" {
+		t.Fatalf("truncate-file returned %s", got)
+	}
+	if b, _ := ioutil.ReadFile(name); string(b) != "012345" {
+		t.Errorf("The file contains %q after truncating the port to 6 bytes.", b)
+	}
+	evalString(t, `(close-port truncated)`)
+	if got := Sprint(evalString(t, `(truncate-file "`+name+`" 3)`)); got != "#t" {
+		t.Fatalf("truncate-file returned %s", got)
+	}
+	if b, _ := ioutil.ReadFile(name); string(b) != "012" {
+		t.Errorf("The file contains %q after truncating it to 3 bytes.", b)
+	}
+	want := "truncate-file: Argument 1 is not an output file port."
+	for _, p := range []Expr{Port{}, evalString(t, "truncated"), evalString(t, `(open-output-string)`)} {
+		if got := Sprint(truncatefile(GlobalEnv, p)); got != want {
+			t.Errorf("(truncate-file %s) = %s, want %s", Sprint(p), got, want)
+		}
+	}
+}
+
+//A read-write port reads and writes at the same position, which
+//set-port-position! moves.
+func TestReadWritePort(t *testing.T) {
+	name := filepath.Join(writeFiles(t, nil), "rw.txt")
+	evalString(t, `(define rw (open-file "`+name+`" 'read-write))`)
+	steps := []struct {
+		src, want string
+	}{
+		{`(write-string "hello world" rw)`, "#t"},
+		{"(port-position rw)", "11"},
+		{"(set-port-position! rw 6)", "#t"},
+		{"(read-char rw)", `#\w`},
+		//The rest of the file has been buffered, but the position is
+		//still just after the character read.
+		{"(port-position rw)", "7"},
+		{"(read-line rw)", `"orld"`},
+		{"(set-port-position! rw 0)", "#t"},
+		{`(write-char #\J rw)`, "#t"},
+		{"(set-port-position! rw 0)", "#t"},
+		{"(read-line rw)", `"Jello world"`},
+		{"(close-port rw)", "#t"},
+		{"(set-port-position! rw 0)", "set-port-position!: Argument 1 is not an open port."},
+	}
+	for _, step := range steps {
+		if got := Sprint(evalString(t, step.src)); got != step.want {
+			t.Fatalf("%s = %s, want %s", step.src, got, step.want)
+		}
+	}
+}
+
+func TestOpenFileModes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"log.txt": "one\n"})
+	name := filepath.Join(dir, "log.txt")
+	evalString(t, `(define appended (open-file "`+name+`" 'append)) (write-string "two\n" appended) (close-port appended)`)
+	if b, _ := ioutil.ReadFile(name); string(b) != "one\ntwo\n" {
+		t.Errorf("Appending to the file gives %q", b)
+	}
+	if got := Sprint(evalString(t, `(open-file "`+name+`" 'create-exclusive)`)); got != "open "+name+": file exists" {
+		t.Errorf("Opening an existing file with create-exclusive gives %s", got)
+	}
+	if got := Sprint(evalString(t, `(open-file "`+name+`" 'bogus)`)); got != "open-file: Unknown mode bogus." {
+		t.Errorf("Opening a file with an unknown mode gives %s", got)
+	}
+	if got := Sprint(evalString(t, `(port-position (open-file "`+name+`" 'write))`)); got != "0" {
+		t.Errorf("The position of a file opened with write is %s", got)
+	}
+	if b, _ := ioutil.ReadFile(name); len(b) != 0 {
+		t.Errorf("Opening the file with write does not truncate it: %q", b)
+	}
+}
@@ -0,0 +1,235 @@
+package goscheme
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+format from SRFI 28 and SRFI 48. Directives start with ~ and are not case
+sensitive:
+~a and ~s print the next argument like display and write, ~w like
+write-shared. ~d, ~x, ~o and ~b print a number in radix 10, 16, 8 and 2.
+~c prints a character. ~f prints a number padded to a width and with a number
+of digits after the point, such as ~8,2F. ~% and ~n print a newline, ~& a newline
+unless at the start of a line, ~_ a space and ~~ a tilde. ~t prints a tab, or
+with a parameter, ~cT, pads with spaces to column c. ~? and ~k format the
+next argument as a format string, with the argument after it as the list of
+its arguments.
+As an extension, ~a, ~s, ~w and the number directives take a width too. ~a,
+~s and ~w pad on the right, so the text is aligned to the left, and numbers
+pad on the left.
+*/
+
+type formatter struct {
+	b    strings.Builder
+	args []Expr
+}
+
+//next returns the next argument for the directive d.
+func (f *formatter) next(d rune) (Expr, Expr) {
+	if len(f.args) == 0 {
+		return nil, Error{"format: Too few arguments for ~" + string(d) + "."}
+	}
+	x := f.args[0]
+	f.args = f.args[1:]
+	return x, nil
+}
+
+//column returns the column the output is at.
+func (f *formatter) column() int {
+	s := f.b.String()
+	return len([]rune(s[strings.LastIndex(s, "\n")+1:]))
+}
+
+//pad writes s padded with spaces to width. If left is true the spaces are
+//written before s.
+func (f *formatter) pad(s string, width int, left bool) {
+	n := width - len([]rune(s))
+	if n > 0 && left {
+		f.b.WriteString(strings.Repeat(" ", n))
+	}
+	f.b.WriteString(s)
+	if n > 0 && !left {
+		f.b.WriteString(strings.Repeat(" ", n))
+	}
+}
+
+//formatParams reads the numeric parameters of a directive, such as the 8,2
+//in ~8,2F. Missing parameters are -1.
+func formatParams(s []rune, i int) ([]int, int) {
+	params := []int{}
+	for {
+		n, start := 0, i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			n = n*10 + int(s[i]-'0')
+			i++
+		}
+		if i == start {
+			n = -1
+		}
+		params = append(params, n)
+		if i >= len(s) || s[i] != ',' {
+			return params, i
+		}
+		i++
+	}
+}
+
+//format formats the string s, which is followed by its arguments.
+func (f *formatter) format(s []rune) Expr {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '~' {
+			f.b.WriteRune(s[i])
+			continue
+		}
+		params, j := formatParams(s, i+1)
+		if j >= len(s) {
+			return Error{"format: The format string ends in the middle of a directive."}
+		}
+		i = j
+		width := params[0]
+		d := unicode.ToLower(s[i])
+		switch d {
+		case 'a', 's', 'w':
+			x, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			print := map[rune]func(Expr) string{'a': displayString, 's': Sprint, 'w': sharedString}[d]
+			f.pad(print(x), width, false)
+		case 'd', 'x', 'o', 'b':
+			x, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			n, ok := x.(Number)
+			if !ok {
+				return Error{"format: The argument for ~" + string(s[i]) + " is not a number."}
+			}
+			radix := map[rune]int{'d': 10, 'x': 16, 'o': 8, 'b': 2}[d]
+			str, err := formatRadix("format", float64(n), radix)
+			if err != nil {
+				return Error{"format: The argument for ~" + string(s[i]) + " is not an integer."}
+			}
+			f.pad(str, width, true)
+		case 'f':
+			x, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			str := displayString(x)
+			if n, ok := x.(Number); ok {
+				str = formatFixed(float64(n), params)
+			}
+			f.pad(str, width, true)
+		case 'c':
+			x, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			c, ok := x.(Character)
+			if !ok {
+				return Error{"format: The argument for ~" + string(s[i]) + " is not a character."}
+			}
+			f.b.WriteRune(rune(c))
+		case '%', 'n':
+			f.b.WriteString("\n")
+		case '&':
+			if f.column() != 0 {
+				f.b.WriteString("\n")
+			}
+		case '_':
+			f.b.WriteString(" ")
+		case '~':
+			f.b.WriteString("~")
+		case 't':
+			if width < 0 {
+				f.b.WriteString("\t")
+			} else if col := f.column(); col < width {
+				f.b.WriteString(strings.Repeat(" ", width-col))
+			}
+		case '?', 'k':
+			x, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			l, err := f.next(s[i])
+			if err != nil {
+				return err
+			}
+			fs, ok := x.(String)
+			if !ok {
+				return Error{"format: The argument for ~" + string(s[i]) + " is not a string."}
+			}
+			ls, ok := l.(ExprList)
+			if !ok {
+				return Error{"format: The second argument for ~" + string(s[i]) + " is not a list."}
+			}
+			args := f.args
+			f.args = ExprListToSlice(ls)
+			if err := f.format(*fs.runes); err != nil {
+				return err
+			}
+			f.args = args
+		default:
+			return Error{"format: Unknown directive ~" + string(s[i]) + "."}
+		}
+	}
+	return nil
+}
+
+//formatFixed formats n for ~F. params holds the width and the number of
+//digits after the point.
+func formatFixed(n float64, params []int) string {
+	if len(params) < 2 || params[1] < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return formatNumber(n)
+	}
+	return strconv.FormatFloat(n, 'f', params[1], 64)
+}
+
+//(format [destination] format-string arg ...) returns the formatted string if
+//the destination is #f or missing, writes it to the current output port if it
+//is #t and to the destination if it is a port.
+func format(e Environment, args ...Expr) Expr {
+	var dest []Expr
+	switch v := args[0].(type) {
+	case String:
+		args = append([]Expr{Boolean(false)}, args...)
+	case Boolean:
+		if v {
+			dest = []Expr{}
+		}
+	case Port:
+		dest = args[:1]
+	default:
+		return Error{"format: Argument 1 is not a string, a boolean or an output port."}
+	}
+	if len(args) < 2 {
+		return Error{"format: Missing format string."}
+	}
+	s, ok := args[1].(String)
+	if !ok {
+		return Error{"format: Argument 2 is not a string."}
+	}
+	f := formatter{args: args[2:]}
+	if err := f.format(*s.runes); err != nil {
+		return err
+	}
+	if len(f.args) != 0 {
+		return Error{"format: Too many arguments for the format string."}
+	}
+	if dest == nil {
+		return NewString(f.b.String())
+	}
+	p, err := outputPortArg(e, "format", dest, 0)
+	if err != nil {
+		return err
+	}
+	if werr := p.writeString(f.b.String()); werr != nil {
+		return Error{werr.Error()}
+	}
+	return Boolean(true)
+}
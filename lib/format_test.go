@@ -0,0 +1,59 @@
+package goscheme
+
+import "testing"
+
+func TestFormatRadix(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(format #f "~x" 255)`, `"ff"`},
+		{`(format #f "~b" -5)`, `"-101"`},
+		{`(format #f "~x" 2.5)`, "format: The argument for ~x is not an integer."},
+		{`(format #f "~o" (/ 1 0))`, "format: The argument for ~o is not an integer."},
+		{`(number->string 255 16)`, `"ff"`},
+		{`(number->string 2.5 16)`, "number->string: Only integers can be written in radix 16."},
+		{`(number->string 1e20 16)`, `"56bc75e2d63100000"`},
+		{`(number->string 2.5)`, `"2.5"`},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//#"..." literals do not depend on the global string-append and format.
+func TestInterpolatedString(t *testing.T) {
+	env := testEnv(t)
+	format, stringAppend := env.Local["format"], env.Local["string-append"]
+	defer func() {
+		env.Local["format"], env.Local["string-append"] = format, stringAppend
+	}()
+	tests := []struct {
+		src, want string
+	}{
+		{`(define x 42) #"x is ${x}."`, `"x is 42."`},
+		{`#"${(list 1 "a")} and ${#\b}"`, `"(1 a) and b"`},
+		{`#"no expressions"`, `"no expressions"`},
+		{`(define format (lambda args "shadowed")) (define string-append (lambda args "shadowed")) #"x is ${x}."`, `"x is 42."`},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//The expansion of a #"..." literal can be written and read back.
+func TestInterpolatedStringWrite(t *testing.T) {
+	written := Sprint(evalString(t, `(define b 'x) (define quoted '#"a${b}c") quoted`))
+	if want := `(%interpolate "a" b "c")`; written != want {
+		t.Fatalf(`'#"a${b}c" is written as %s, want %s`, written, want)
+	}
+	if got := evalString(t, "(equal? quoted (read (open-input-string (with-output-to-string (lambda () (write quoted))))))"); got != Expr(Boolean(true)) {
+		t.Errorf("%s is not read back as the same list.", written)
+	}
+	if got := Sprint(evalString(t, written)); got != `"axc"` {
+		t.Errorf("%s = %s, want \"axc\"", written, got)
+	}
+}
@@ -0,0 +1,666 @@
+package goscheme
+
+import (
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+/*
+Hash tables (SRFI 69, with the SRFI 125 names where they differ) are a Go map
+from hash values to buckets of entries. Keys in a bucket are compared with the
+table's equivalence procedure, so any equivalence can be used as long as the
+hash procedure agrees with it.
+equal?, eqv?, eq?, = and string=? are recognized and handled in Go. For other
+equivalence procedures a hash procedure should be given, otherwise every key
+ends up in the same bucket.
+Tables can be shared between goroutines. The hash and equivalence procedures
+and the procedures given to hash-table-update! and the like may use the table
+themselves, so they are called without holding the table's lock. A change is
+made only if the table has not been changed since the key was looked up,
+otherwise the lookup, and the update procedures, are run again.
+*/
+
+type HashTable struct {
+	*hashTable
+}
+
+type hashTable struct {
+	mu      sync.RWMutex
+	buckets map[uint64][]hashEntry
+	size    int
+	//version is incremented by every change to the table.
+	version uint64
+	//The procedures the table was made with, returned by
+	//hash-table-equivalence-function and hash-table-hash-function.
+	equivProc Expr
+	hashProc  Expr
+	equiv     func(a, b Expr) (bool, Expr)
+	hash      func(x Expr) (uint64, Expr)
+}
+
+type hashEntry struct {
+	key   Expr
+	value Expr
+}
+
+func (h HashTable) isExpr() {}
+func (h HashTable) String() string {
+	return "#<hash-table>"
+}
+
+//hashBudget limits how many parts of a datum are hashed, so that long lists
+//are quick to hash and cyclic ones do not hang.
+const hashBudget = 64
+
+//equalHash hashes x so that datums that are equal? get the same hash.
+func equalHash(x Expr) uint64 {
+	h := fnv.New64a()
+	budget := hashBudget
+	hashInto(h, x, &budget)
+	return h.Sum64()
+}
+
+func hashInto(h hash.Hash64, x Expr, budget *int) {
+	if *budget <= 0 {
+		return
+	}
+	*budget--
+	switch v := x.(type) {
+	case Number:
+		f := float64(v)
+		if f == 0 {
+			//0 and -0 are equal but have different bits.
+			f = 0
+		}
+		h.Write([]byte("n" + strconv.FormatUint(math.Float64bits(f), 16)))
+	case String:
+		h.Write([]byte("s" + string(*v.runes)))
+	case Symbol:
+		h.Write([]byte("y" + v.name))
+	case Character:
+		h.Write([]byte("c" + string(rune(v))))
+	case Boolean:
+		h.Write([]byte("b" + v.String()))
+	case Bytevector:
+		h.Write([]byte("u"))
+		h.Write(v)
+	case ExprList:
+		h.Write([]byte("l"))
+		for cell := &v; cell != nil && cell.car != nil && *budget > 0; cell = cell.cdr {
+			hashInto(h, *cell.car, budget)
+		}
+	case Vector:
+		h.Write([]byte("v"))
+		for _, y := range v {
+			hashInto(h, y, budget)
+		}
+	case homogeneous:
+		h.Write([]byte(v.kind().tag))
+		for i := 0; i < v.Len() && *budget > 0; i++ {
+			hashInto(h, v.ref(i), budget)
+		}
+	}
+}
+
+//eqvHash hashes x so that datums that are eqv? get the same hash.
+func eqvHash(x Expr) uint64 {
+	switch v := x.(type) {
+	case String:
+		return uint64(reflect.ValueOf(v.runes).Pointer())
+	case Vector, Bytevector:
+		return uint64(reflect.ValueOf(v).Pointer())
+	case homogeneous:
+		return uint64(v.base())
+	case ExprList:
+		return uint64(reflect.ValueOf(v.car).Pointer())
+	}
+	return equalHash(x)
+}
+
+//comparator returns the Go functions used by a table made with the given
+//equivalence and hash procedures, either of which may be nil.
+func comparator(e Environment, equivProc, hashProc Proc) (func(a, b Expr) (bool, Expr), func(x Expr) (uint64, Expr)) {
+	equiv := func(a, b Expr) (bool, Expr) { return isEqual(a, b), nil }
+	hash := func(x Expr) (uint64, Expr) { return equalHash(x), nil }
+	b, _ := equivProc.(BuiltIn)
+	switch {
+	case equivProc == nil || builtineqv(b, NewBuiltIn("", 0, 0, equal)):
+	case builtineqv(b, NewBuiltIn("", 0, 0, eqv)):
+		equiv = func(a, b Expr) (bool, Expr) { return isEqv(a, b), nil }
+		hash = func(x Expr) (uint64, Expr) { return eqvHash(x), nil }
+	default:
+		equiv = func(x, y Expr) (bool, Expr) {
+			r := callProc(e, equivProc, x, y)
+			if _, ok := r.(Error); ok {
+				return false, r
+			}
+			return truthy(r), nil
+		}
+		//= and string=? agree with equalHash, and report errors for keys of
+		//the wrong type themselves.
+		if !builtineqv(b, NewBuiltIn("", 0, 0, eq)) && !builtineqv(b, NewBuiltIn("", 0, 0, stringeq)) {
+			hash = func(x Expr) (uint64, Expr) { return 0, nil }
+		}
+	}
+	if hashProc != nil {
+		hash = func(x Expr) (uint64, Expr) {
+			r := callProc(e, hashProc, x)
+			n, ok := r.(Number)
+			if !ok {
+				if _, ok := r.(Error); ok {
+					return 0, r
+				}
+				return 0, Error{"Hash table: The hash function returned " + Sprint(r) + ", which is not a number."}
+			}
+			return uint64(int64(n)), nil
+		}
+	}
+	return equiv, hash
+}
+
+//lookup finds key in the table. It returns the hash of the key, the index
+//of the key in its bucket, or -1 if it is not in the table, the value and the
+//version of the table that was looked at. The lock must not be held, since
+//the hash and equivalence procedures may be Scheme procedures.
+func (h HashTable) lookup(key Expr) (uint64, int, Expr, uint64, Expr) {
+	k, err := h.hash(key)
+	if err != nil {
+		return 0, -1, nil, 0, err
+	}
+	h.mu.RLock()
+	bucket := append([]hashEntry{}, h.buckets[k]...)
+	version := h.version
+	h.mu.RUnlock()
+	for i, entry := range bucket {
+		same, err := h.equiv(entry.key, key)
+		if err != nil {
+			return 0, -1, nil, 0, err
+		}
+		if same {
+			return k, i, entry.value, version, nil
+		}
+	}
+	return k, -1, nil, version, nil
+}
+
+//change calls f with the write lock held, unless the table has been changed
+//since version. It reports whether f was called.
+func (h HashTable) change(version uint64, f func()) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.version != version {
+		return false
+	}
+	h.version++
+	f()
+	return true
+}
+
+//put sets the entry at index i of bucket k, or adds one if i is -1. The
+//caller must hold the write lock.
+func (h HashTable) put(k uint64, i int, key, value Expr) {
+	if i == -1 {
+		h.buckets[k] = append(h.buckets[k], hashEntry{key, value})
+		h.size++
+	} else {
+		h.buckets[k][i].value = value
+	}
+}
+
+//set sets key to value.
+func (h HashTable) set(key, value Expr) Expr {
+	for {
+		k, i, _, version, err := h.lookup(key)
+		if err != nil {
+			return err
+		}
+		if h.change(version, func() { h.put(k, i, key, value) }) {
+			return nil
+		}
+	}
+}
+
+//entries returns a snapshot of the entries in the table, so that procedures
+//can be called on them without holding the lock.
+func (h HashTable) entries() []hashEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ret := make([]hashEntry, 0, h.size)
+	for _, b := range h.buckets {
+		ret = append(ret, b...)
+	}
+	return ret
+}
+
+func tableArg(name string, args []Expr, i int) (HashTable, Expr) {
+	h, ok := args[i].(HashTable)
+	if !ok {
+		return HashTable{}, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a hash table."}
+	}
+	return h, nil
+}
+
+//procArgs checks that the arguments from args[from] onwards are procedures.
+//Missing arguments are returned as nil.
+func procArgs(name string, args []Expr, from, n int) ([]Proc, Expr) {
+	ret := make([]Proc, n)
+	for i := 0; i < n && from+i < len(args); i++ {
+		p, ok := args[from+i].(Proc)
+		if !ok {
+			return nil, Error{name + ": Argument " + strconv.Itoa(from+i+1) + " is not a procedure."}
+		}
+		ret[i] = p
+	}
+	return ret, nil
+}
+
+func newHashTable(e Environment, name string, args []Expr, from int) (HashTable, Expr) {
+	ps, err := procArgs(name, args, from, 2)
+	if err != nil {
+		return HashTable{}, err
+	}
+	equiv, hash := comparator(e, ps[0], ps[1])
+	h := HashTable{&hashTable{buckets: map[uint64][]hashEntry{}, equiv: equiv, hash: hash}}
+	h.equivProc, h.hashProc = Boolean(false), Boolean(false)
+	if ps[0] != nil {
+		h.equivProc = ps[0]
+	}
+	if ps[1] != nil {
+		h.hashProc = ps[1]
+	}
+	return h, nil
+}
+
+//(alist->hash-table alist [equiv [hash]]) keeps the first entry for keys that
+//occur more than once, like assoc would find.
+func alisttohashtable(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"alist->hash-table: Argument 1 is not a list."}
+	}
+	h, err := newHashTable(e, "alist->hash-table", args, 1)
+	if err != nil {
+		return err
+	}
+	for _, pair := range ExprListToSlice(l) {
+		p, ok := pair.(ExprList)
+		if !ok || p.car == nil {
+			return Error{"alist->hash-table: Argument 1 is not an association list."}
+		}
+		value := alistValue(p)
+		_, i, _, _, err := h.lookup(*p.car)
+		if err != nil {
+			return err
+		}
+		if i == -1 {
+			if err := h.set(*p.car, value); err != nil {
+				return err
+			}
+		}
+	}
+	return h
+}
+
+//alistValue returns the cdr of the association list entry p. cons with a
+//non-list cdr makes a cell whose cdr is nil after the value, and the reader
+//keeps the dot in (key . value) as a symbol.
+func alistValue(p ExprList) Expr {
+	if p.cdr == nil {
+		return ExprList{}
+	}
+	rest := *p.cdr
+	if rest.car != nil && *rest.car == Expr(Intern(".")) && rest.cdr != nil && rest.cdr.car != nil &&
+		(rest.cdr.cdr == nil || rest.cdr.cdr.car == nil) {
+		return *rest.cdr.car
+	}
+	if rest.car != nil && rest.cdr == nil {
+		return *rest.car
+	}
+	return rest
+}
+
+//(hash obj [bound]) is the hash function used by equal? tables.
+func hash_(e Environment, args ...Expr) Expr {
+	return boundHash("hash", args, equalHash(args[0]))
+}
+
+func hashbyidentity(e Environment, args ...Expr) Expr {
+	return boundHash("hash-by-identity", args, eqvHash(args[0]))
+}
+
+func stringhash(e Environment, args ...Expr) Expr {
+	if _, ok := args[0].(String); !ok {
+		return Error{"string-hash: Argument 1 is not a string."}
+	}
+	return boundHash("string-hash", args, equalHash(args[0]))
+}
+
+//boundHash reduces h to the range [0, bound) if the optional bound is given.
+func boundHash(name string, args []Expr, h uint64) Expr {
+	//Keep hashes small enough to be exact as a Number.
+	h &= 1<<53 - 1
+	if len(args) == 2 {
+		b, ok := args[1].(Number)
+		if !ok || b < 1 {
+			return Error{name + ": Argument 2 is not a positive number."}
+		}
+		h %= uint64(b)
+	}
+	return Number(h)
+}
+
+func hashtable_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(HashTable)
+	return Boolean(ok)
+}
+
+func hashtabletoalist(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table->alist", args, 0)
+	if err != nil {
+		return err
+	}
+	entries := h.entries()
+	ret := make([]Expr, 0, len(entries))
+	for _, entry := range entries {
+		ret = append(ret, cons(e, entry.key, entry.value))
+	}
+	return SliceToExprList(ret)
+}
+
+func hashtableclear(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-clear!", args, 0)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.buckets = map[uint64][]hashEntry{}
+	h.size = 0
+	h.version++
+	h.mu.Unlock()
+	return h
+}
+
+func hashtablecontains(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-contains?", args, 0)
+	if err != nil {
+		return err
+	}
+	_, i, _, _, err := h.lookup(args[1])
+	if err != nil {
+		return err
+	}
+	return Boolean(i != -1)
+}
+
+func hashtablecopy(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-copy", args, 0)
+	if err != nil {
+		return err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	c := HashTable{&hashTable{buckets: make(map[uint64][]hashEntry, len(h.buckets)), size: h.size,
+		equivProc: h.equivProc, hashProc: h.hashProc, equiv: h.equiv, hash: h.hash}}
+	for k, b := range h.buckets {
+		c.buckets[k] = append([]hashEntry{}, b...)
+	}
+	return c
+}
+
+//(hash-table-delete! table key ...) returns the number of keys that were
+//deleted.
+func hashtabledelete(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-delete!", args, 0)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, key := range args[1:] {
+		for {
+			k, i, _, version, err := h.lookup(key)
+			if err != nil {
+				return err
+			}
+			if i == -1 {
+				break
+			}
+			if h.change(version, func() {
+				b := h.buckets[k]
+				h.buckets[k] = append(b[:i:i], b[i+1:]...)
+				if len(h.buckets[k]) == 0 {
+					delete(h.buckets, k)
+				}
+				h.size--
+			}) {
+				n++
+				break
+			}
+		}
+	}
+	return Number(n)
+}
+
+func hashtableequivfn(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-equivalence-function", args, 0)
+	if err != nil {
+		return err
+	}
+	return h.equivProc
+}
+
+//(hash-table-fold table kons knil) from SRFI 69 and (hash-table-fold kons knil
+//table) from SRFI 125 are both accepted. kons is called with the key, the
+//value and the accumulated value.
+func hashtablefold(e Environment, args ...Expr) Expr {
+	h, ok := args[0].(HashTable)
+	kons, knil := args[1], args[2]
+	if !ok {
+		if h, ok = args[2].(HashTable); !ok {
+			return Error{"hash-table-fold: No hash table given."}
+		}
+		kons, knil = args[0], args[1]
+	}
+	p, ok := kons.(Proc)
+	if !ok {
+		return Error{"hash-table-fold: kons is not a procedure."}
+	}
+	acc := knil
+	for _, entry := range h.entries() {
+		acc = callProc(e, p, entry.key, entry.value, acc)
+		if _, ok := acc.(Error); ok {
+			return acc
+		}
+	}
+	return acc
+}
+
+func hashtablehashfn(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-hash-function", args, 0)
+	if err != nil {
+		return err
+	}
+	return h.hashProc
+}
+
+func hashtablekeys(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-keys", args, 0)
+	if err != nil {
+		return err
+	}
+	entries := h.entries()
+	ret := make([]Expr, 0, len(entries))
+	for _, entry := range entries {
+		ret = append(ret, entry.key)
+	}
+	return SliceToExprList(ret)
+}
+
+//(hash-table-ref table key [failure [success]]) calls the thunk failure if key
+//is not in the table, and success with the value if it is.
+func hashtableref(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-ref", args, 0)
+	if err != nil {
+		return err
+	}
+	ps, err := procArgs("hash-table-ref", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	_, i, value, _, err := h.lookup(args[1])
+	switch {
+	case err != nil:
+		return err
+	case i == -1 && ps[0] != nil:
+		return callProc(e, ps[0])
+	case i == -1:
+		return Error{"hash-table-ref: Key " + Sprint(args[1]) + " is not in the table."}
+	case ps[1] != nil:
+		return callProc(e, ps[1], value)
+	}
+	return value
+}
+
+func hashtablerefdefault(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-ref/default", args, 0)
+	if err != nil {
+		return err
+	}
+	_, i, value, _, err := h.lookup(args[1])
+	if err != nil {
+		return err
+	}
+	if i == -1 {
+		return args[2]
+	}
+	return value
+}
+
+//(hash-table-set! table key value ...) sets any number of keys.
+func hashtableset(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-set!", args, 0)
+	if err != nil {
+		return err
+	}
+	if len(args)%2 != 1 {
+		return Error{"hash-table-set!: Every key needs a value."}
+	}
+	for i := 1; i < len(args); i += 2 {
+		if err := h.set(args[i], args[i+1]); err != nil {
+			return err
+		}
+	}
+	return h
+}
+
+func hashtablesize(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-size", args, 0)
+	if err != nil {
+		return err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return Number(h.size)
+}
+
+//(hash-table-update! table key updater [failure [success]]) sets key to the
+//result of calling updater on its value. If key is not in the table the
+//value is the result of calling the thunk failure.
+func hashtableupdate(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-update!", args, 0)
+	if err != nil {
+		return err
+	}
+	ps, err := procArgs("hash-table-update!", args, 2, 3)
+	if err != nil {
+		return err
+	}
+	return h.update(e, "hash-table-update!", args[1], ps[0], func() Expr {
+		if ps[1] == nil {
+			return Error{"hash-table-update!: Key " + Sprint(args[1]) + " is not in the table."}
+		}
+		return callProc(e, ps[1])
+	}, ps[2])
+}
+
+func hashtableupdatedefault(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-update!/default", args, 0)
+	if err != nil {
+		return err
+	}
+	ps, err := procArgs("hash-table-update!/default", args, 2, 1)
+	if err != nil {
+		return err
+	}
+	return h.update(e, "hash-table-update!/default", args[1], ps[0], func() Expr { return args[3] }, nil)
+}
+
+//update does the work of hash-table-update!. If another goroutine changes the
+//table before the new value is set, the update is done again.
+func (h HashTable) update(e Environment, name string, key Expr, updater Proc, failure func() Expr, success Proc) Expr {
+	for {
+		k, i, value, version, err := h.lookup(key)
+		if err != nil {
+			return err
+		}
+		if i == -1 {
+			value = failure()
+		} else if success != nil {
+			value = callProc(e, success, value)
+		}
+		if _, ok := value.(Error); ok {
+			return value
+		}
+		value = callProc(e, updater, value)
+		if _, ok := value.(Error); ok {
+			return value
+		}
+		if h.change(version, func() { h.put(k, i, key, value) }) {
+			return value
+		}
+	}
+}
+
+func hashtablevalues(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-values", args, 0)
+	if err != nil {
+		return err
+	}
+	entries := h.entries()
+	ret := make([]Expr, 0, len(entries))
+	for _, entry := range entries {
+		ret = append(ret, entry.value)
+	}
+	return SliceToExprList(ret)
+}
+
+//(hash-table-walk table proc) calls proc with every key and value.
+func hashtablewalk(e Environment, args ...Expr) Expr {
+	h, err := tableArg("hash-table-walk", args, 0)
+	if err != nil {
+		return err
+	}
+	p, ok := args[1].(Proc)
+	if !ok {
+		return Error{"hash-table-walk: Argument 2 is not a procedure."}
+	}
+	for _, entry := range h.entries() {
+		if r, ok := callProc(e, p, entry.key, entry.value).(Error); ok {
+			return r
+		}
+	}
+	return Boolean(true)
+}
+
+//(make-hash-table [equiv [hash]]) makes a table using equiv to compare keys,
+//equal? by default, and hash to hash them.
+func makehashtable(e Environment, args ...Expr) Expr {
+	h, err := newHashTable(e, "make-hash-table", args, 0)
+	if err != nil {
+		return err
+	}
+	return h
+}
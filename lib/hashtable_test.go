@@ -0,0 +1,43 @@
+package goscheme
+
+import "testing"
+
+func TestAlistToHashTable(t *testing.T) {
+	tests := []struct {
+		alist, key, want string
+	}{
+		{"'((a . 1))", "'a", "1"},
+		{"(list (cons 'a 1))", "'a", "1"},
+		{"'((a 1))", "'a", "(1)"},
+		{"'((a . (1 2)))", "'a", "(1 2)"},
+		{"'((a 1) (a 2))", "'a", "(1)"},
+		{"'((a))", "'a", "()"},
+	}
+	for _, test := range tests {
+		r := evalString(t, "(hash-table-ref (alist->hash-table "+test.alist+") "+test.key+")")
+		if got := Sprint(r); got != test.want {
+			t.Errorf("%s: %s is %s, want %s", test.alist, test.key, got, test.want)
+		}
+	}
+}
+
+func TestHashTableAlistRoundTrip(t *testing.T) {
+	//The reader keeps the dot in '((a . 1)) as a symbol, so dotted entries are
+	//made with cons to compare them with equal?.
+	for _, alist := range []string{"(list (cons 'a 1))", "'((a 1))", "'((a 1 2))", "(list (cons 'a '(b)))", "'((a))"} {
+		r := evalString(t, "(equal? "+alist+" (hash-table->alist (alist->hash-table "+alist+")))")
+		if r != Expr(Boolean(true)) {
+			t.Errorf("%s does not survive alist->hash-table and hash-table->alist.", alist)
+		}
+	}
+}
+
+func TestHashTableCallbacks(t *testing.T) {
+	r := evalString(t, `(define h (make-hash-table))
+		(hash-table-set! h 'a 1)
+		(hash-table-update! h 'a (lambda (v) (+ v (hash-table-size h))))
+		(hash-table-ref h 'a (lambda () 0) (lambda (v) (+ v (hash-table-ref/default h 'a 0))))`)
+	if got := Sprint(r); got != "4" {
+		t.Errorf("Using a table from its own callbacks returned %s, want 4.", got)
+	}
+}
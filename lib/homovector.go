@@ -0,0 +1,477 @@
+package goscheme
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Homogeneous numeric vectors from SRFI 4 and SRFI 160. Their elements are
+stored unboxed in a Go slice of the element type, instead of as Numbers in a
+Vector. A u8vector is a Bytevector.
+Every kind of vector gets the same set of procedures, named after its tag:
+make-f64vector, f64vector, f64vector?, f64vector-length, f64vector-ref,
+f64vector-set!, f64vector->list, list->f64vector, f64vector->vector,
+vector->f64vector, f64vector-copy, f64vector-copy!, f64vector-append,
+f64vector-fill!, f64vector-map, f64vector-for-each and f64vector-fold.
+Mapping or folding with +, -, * or / is done without calling back into Scheme.
+*/
+
+//homogeneous is implemented by every kind of homogeneous vector.
+type homogeneous interface {
+	Expr
+	kind() *numKind
+	Len() int
+	ref(i int) Number
+	//set stores n, which must already have been checked by the kind.
+	set(i int, n Number)
+	//copyRange returns a new vector with the elements from start to end.
+	copyRange(start, end int) homogeneous
+	//copyFrom copies from, which has the same kind, to index at.
+	copyFrom(at int, from homogeneous)
+	base() uintptr
+}
+
+//numKind describes the elements of one kind of homogeneous vector.
+type numKind struct {
+	tag     string
+	integer bool
+	//min and lim are the smallest element and the first one too large.
+	min, lim float64
+	new      func(k *numKind, n int) homogeneous
+}
+
+type numElem interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64 | ~float32 | ~float64
+}
+
+//NumVector is a homogeneous vector of any kind other than u8.
+type NumVector[T numElem] struct {
+	k *numKind
+	s []T
+}
+
+func makeNumVector[T numElem](k *numKind, n int) homogeneous {
+	return NumVector[T]{k, make([]T, n)}
+}
+
+func makeBytevectorOf(k *numKind, n int) homogeneous {
+	return make(Bytevector, n)
+}
+
+var u8Kind = &numKind{"u8", true, 0, 1 << 8, makeBytevectorOf}
+
+var numKinds = []*numKind{
+	{"s8", true, math.MinInt8, 1 << 7, makeNumVector[int8]},
+	u8Kind,
+	{"s16", true, math.MinInt16, 1 << 15, makeNumVector[int16]},
+	{"u16", true, 0, 1 << 16, makeNumVector[uint16]},
+	{"s32", true, math.MinInt32, 1 << 31, makeNumVector[int32]},
+	{"u32", true, 0, 1 << 32, makeNumVector[uint32]},
+	{"s64", true, math.MinInt64, 1 << 63, makeNumVector[int64]},
+	{"u64", true, 0, 1 << 64, makeNumVector[uint64]},
+	{"f32", false, math.Inf(-1), math.Inf(1), makeNumVector[float32]},
+	{"f64", false, math.Inf(-1), math.Inf(1), makeNumVector[float64]},
+}
+
+func (v NumVector[T]) isExpr() {}
+
+func (v NumVector[T]) String() string {
+	return homogeneousString(v)
+}
+
+func (v NumVector[T]) kind() *numKind      { return v.k }
+func (v NumVector[T]) Len() int            { return len(v.s) }
+func (v NumVector[T]) ref(i int) Number    { return Number(v.s[i]) }
+func (v NumVector[T]) set(i int, n Number) { v.s[i] = T(n) }
+func (v NumVector[T]) base() uintptr       { return reflect.ValueOf(v.s).Pointer() }
+
+func (v NumVector[T]) copyRange(start, end int) homogeneous {
+	return NumVector[T]{v.k, append([]T{}, v.s[start:end]...)}
+}
+
+func (v NumVector[T]) copyFrom(at int, from homogeneous) {
+	copy(v.s[at:], from.(NumVector[T]).s)
+}
+
+func (b Bytevector) kind() *numKind      { return u8Kind }
+func (b Bytevector) Len() int            { return len(b) }
+func (b Bytevector) ref(i int) Number    { return Number(b[i]) }
+func (b Bytevector) set(i int, n Number) { b[i] = byte(n) }
+func (b Bytevector) base() uintptr       { return reflect.ValueOf(b).Pointer() }
+
+func (b Bytevector) copyRange(start, end int) homogeneous {
+	return Bytevector(append([]byte{}, b[start:end]...))
+}
+
+func (b Bytevector) copyFrom(at int, from homogeneous) {
+	copy(b[at:], from.(Bytevector))
+}
+
+//homogeneousString returns the #f64(...) syntax for v. f32 elements are
+//printed with the precision of a float32, so that 0.1 is not printed as
+//0.10000000149011612.
+func homogeneousString(v homogeneous) string {
+	var b strings.Builder
+	b.WriteString("#" + v.kind().tag + "(")
+	for i := 0; i < v.Len(); i++ {
+		if i != 0 {
+			b.WriteString(" ")
+		}
+		f := float64(v.ref(i))
+		if v.kind().tag == "f32" && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			b.WriteString(strconv.FormatFloat(f, 'f', -1, 32))
+		} else {
+			b.WriteString(formatNumber(f))
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (k *numKind) name() string {
+	return k.tag + "vector"
+}
+
+//check converts x to an element, failing if it is not a number that fits.
+func (k *numKind) check(name string, x Expr, what string) (Number, Expr) {
+	n, ok := x.(Number)
+	if !ok || k.integer && (float64(n) != math.Trunc(float64(n)) || float64(n) < k.min || float64(n) >= k.lim) {
+		return 0, Error{name + ": " + what + " is not a valid " + k.tag + " value."}
+	}
+	return n, nil
+}
+
+//fromSlice returns a new vector of the kind holding the elements of s.
+func (k *numKind) fromSlice(name string, s []Expr) Expr {
+	v := k.new(k, len(s))
+	for i, x := range s {
+		n, err := k.check(name, x, "Element "+strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+		v.set(i, n)
+	}
+	return v
+}
+
+func (k *numKind) arg(name string, args []Expr, i int) (homogeneous, Expr) {
+	v, ok := args[i].(homogeneous)
+	if !ok || v.kind() != k {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a " + k.name() + "."}
+	}
+	return v, nil
+}
+
+//rangeArg returns the vector in args[i] and the optional start and end
+//arguments after it.
+func (k *numKind) rangeArg(name string, args []Expr, i int) (homogeneous, int, int, Expr) {
+	v, err := k.arg(name, args, i)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	start, end, err := rangeArgs(name, args, i+1, v.Len())
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return v, start, end, nil
+}
+
+//rows returns the rows of the elements of the vectors from args[from] on,
+//like rows does for lists.
+func (k *numKind) rows(name string, args []Expr, from int) ([][]float64, Expr) {
+	n := -1
+	vs := make([]homogeneous, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		v, err := k.arg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || v.Len() < n {
+			n = v.Len()
+		}
+		vs = append(vs, v)
+	}
+	ret := make([][]float64, n)
+	for i := range ret {
+		ret[i] = make([]float64, len(vs))
+		for j, v := range vs {
+			ret[i][j] = float64(v.ref(i))
+		}
+	}
+	return ret, nil
+}
+
+//elementwise returns a Go function applying the procedure p to numbers. The
+//arithmetic builtins are done directly, anything else is called normally.
+func elementwise(e Environment, p Proc) func(xs []float64) Expr {
+	var op func(a, b float64) float64
+	var unary func(a float64) float64
+	if b, ok := p.(BuiltIn); ok && len(b.partialArgs) == 0 {
+		switch {
+		case builtineqv(b, NewBuiltIn("", 0, 0, add)):
+			op = func(a, b float64) float64 { return a + b }
+		case builtineqv(b, NewBuiltIn("", 0, 0, mul)):
+			op = func(a, b float64) float64 { return a * b }
+		case builtineqv(b, NewBuiltIn("", 0, 0, sub)):
+			op = func(a, b float64) float64 { return a - b }
+			unary = func(a float64) float64 { return -a }
+		case builtineqv(b, NewBuiltIn("", 0, 0, div)):
+			op = func(a, b float64) float64 { return a / b }
+			unary = func(a float64) float64 { return 1 / a }
+		}
+	}
+	if op != nil {
+		return func(xs []float64) Expr {
+			if len(xs) == 1 && unary != nil {
+				return Number(unary(xs[0]))
+			}
+			acc := xs[0]
+			for _, x := range xs[1:] {
+				acc = op(acc, x)
+			}
+			return Number(acc)
+		}
+	}
+	return func(xs []float64) Expr {
+		args := make([]Expr, len(xs))
+		for i, x := range xs {
+			args[i] = Number(x)
+		}
+		return callProc(e, p, args...)
+	}
+}
+
+//procs returns the procedures for the kind.
+func (k *numKind) procs() []BuiltIn {
+	t := k.name()
+	return []BuiltIn{
+		NewBuiltIn("make-"+t, 1, 2, func(e Environment, args ...Expr) Expr {
+			n, err := countArg("make-"+t, args, 0)
+			if err != nil {
+				return err
+			}
+			v := k.new(k, n)
+			if len(args) == 2 {
+				x, err := k.check("make-"+t, args[1], "Argument 2")
+				if err != nil {
+					return err
+				}
+				for i := 0; i < n; i++ {
+					v.set(i, x)
+				}
+			}
+			return v
+		}),
+		NewBuiltIn(t, 0, -1, func(e Environment, args ...Expr) Expr {
+			return k.fromSlice(t, args)
+		}),
+		NewBuiltIn(t+"?", 1, 1, func(e Environment, args ...Expr) Expr {
+			v, ok := args[0].(homogeneous)
+			return Boolean(ok && v.kind() == k)
+		}),
+		NewBuiltIn(t+"-length", 1, 1, func(e Environment, args ...Expr) Expr {
+			v, err := k.arg(t+"-length", args, 0)
+			if err != nil {
+				return err
+			}
+			return Number(v.Len())
+		}),
+		NewBuiltIn(t+"-ref", 2, 2, func(e Environment, args ...Expr) Expr {
+			v, err := k.arg(t+"-ref", args, 0)
+			if err != nil {
+				return err
+			}
+			i, err := indexArg(t+"-ref", args, 1, v.Len()-1)
+			if err != nil {
+				return err
+			}
+			return v.ref(i)
+		}),
+		NewBuiltIn(t+"-set!", 3, 3, func(e Environment, args ...Expr) Expr {
+			v, err := k.arg(t+"-set!", args, 0)
+			if err != nil {
+				return err
+			}
+			i, err := indexArg(t+"-set!", args, 1, v.Len()-1)
+			if err != nil {
+				return err
+			}
+			x, err := k.check(t+"-set!", args[2], "Argument 3")
+			if err != nil {
+				return err
+			}
+			v.set(i, x)
+			return v
+		}),
+		NewBuiltIn(t+"->list", 1, 3, func(e Environment, args ...Expr) Expr {
+			v, start, end, err := k.rangeArg(t+"->list", args, 0)
+			if err != nil {
+				return err
+			}
+			ret := make([]Expr, 0, end-start)
+			for i := start; i < end; i++ {
+				ret = append(ret, v.ref(i))
+			}
+			return SliceToExprList(ret)
+		}),
+		NewBuiltIn("list->"+t, 1, 1, func(e Environment, args ...Expr) Expr {
+			l, err := listArg("list->"+t, args, 0)
+			if err != nil {
+				return err
+			}
+			return k.fromSlice("list->"+t, l)
+		}),
+		NewBuiltIn(t+"->vector", 1, 3, func(e Environment, args ...Expr) Expr {
+			v, start, end, err := k.rangeArg(t+"->vector", args, 0)
+			if err != nil {
+				return err
+			}
+			ret := make(Vector, 0, end-start)
+			for i := start; i < end; i++ {
+				ret = append(ret, v.ref(i))
+			}
+			return ret
+		}),
+		NewBuiltIn("vector->"+t, 1, 3, func(e Environment, args ...Expr) Expr {
+			v, err := vectorRangeArg("vector->"+t, args, 0)
+			if err != nil {
+				return err
+			}
+			return k.fromSlice("vector->"+t, v)
+		}),
+		NewBuiltIn(t+"-copy", 1, 3, func(e Environment, args ...Expr) Expr {
+			v, start, end, err := k.rangeArg(t+"-copy", args, 0)
+			if err != nil {
+				return err
+			}
+			return v.copyRange(start, end)
+		}),
+		NewBuiltIn(t+"-copy!", 3, 5, func(e Environment, args ...Expr) Expr {
+			to, err := k.arg(t+"-copy!", args, 0)
+			if err != nil {
+				return err
+			}
+			at, err := indexArg(t+"-copy!", args, 1, to.Len())
+			if err != nil {
+				return err
+			}
+			from, start, end, err := k.rangeArg(t+"-copy!", args, 2)
+			if err != nil {
+				return err
+			}
+			if at+end-start > to.Len() {
+				return Error{t + "-copy!: Not enough room in destination " + t + "."}
+			}
+			//Copying the range first makes overlapping copies work.
+			to.copyFrom(at, from.copyRange(start, end))
+			return to
+		}),
+		NewBuiltIn(t+"-append", 0, -1, func(e Environment, args ...Expr) Expr {
+			n := 0
+			for i := range args {
+				v, err := k.arg(t+"-append", args, i)
+				if err != nil {
+					return err
+				}
+				n += v.Len()
+			}
+			ret := k.new(k, n)
+			at := 0
+			for _, x := range args {
+				ret.copyFrom(at, x.(homogeneous))
+				at += x.(homogeneous).Len()
+			}
+			return ret
+		}),
+		NewBuiltIn(t+"-fill!", 2, 4, func(e Environment, args ...Expr) Expr {
+			v, err := k.arg(t+"-fill!", args, 0)
+			if err != nil {
+				return err
+			}
+			x, err := k.check(t+"-fill!", args[1], "Argument 2")
+			if err != nil {
+				return err
+			}
+			start, end, err := rangeArgs(t+"-fill!", args, 2, v.Len())
+			if err != nil {
+				return err
+			}
+			for i := start; i < end; i++ {
+				v.set(i, x)
+			}
+			return v
+		}),
+		NewBuiltIn(t+"-map", 2, -1, func(e Environment, args ...Expr) Expr {
+			f, err := procArg(t+"-map", args, 0)
+			if err != nil {
+				return err
+			}
+			rs, err := k.rows(t+"-map", args, 1)
+			if err != nil {
+				return err
+			}
+			fn := elementwise(e, f)
+			ret := k.new(k, len(rs))
+			for i, r := range rs {
+				x, err := k.check(t+"-map", fn(r), "The result")
+				if err != nil {
+					return err
+				}
+				ret.set(i, x)
+			}
+			return ret
+		}),
+		NewBuiltIn(t+"-for-each", 2, -1, func(e Environment, args ...Expr) Expr {
+			f, err := procArg(t+"-for-each", args, 0)
+			if err != nil {
+				return err
+			}
+			rs, err := k.rows(t+"-for-each", args, 1)
+			if err != nil {
+				return err
+			}
+			fn := elementwise(e, f)
+			for _, r := range rs {
+				if v := fn(r); isError(v) {
+					return v
+				}
+			}
+			return Boolean(true)
+		}),
+		//(f64vector-fold kons state vector ...) calls (kons state element ...)
+		//like vector-fold.
+		NewBuiltIn(t+"-fold", 3, -1, func(e Environment, args ...Expr) Expr {
+			kons, err := procArg(t+"-fold", args, 0)
+			if err != nil {
+				return err
+			}
+			rs, err := k.rows(t+"-fold", args, 2)
+			if err != nil {
+				return err
+			}
+			fn := elementwise(e, kons)
+			acc := args[1]
+			for _, r := range rs {
+				n, ok := acc.(Number)
+				if !ok {
+					//The state is not a number, so it has to be passed to
+					//kons as it is.
+					xs := make([]Expr, len(r))
+					for i, x := range r {
+						xs[i] = Number(x)
+					}
+					acc = callProc(e, kons, append([]Expr{acc}, xs...)...)
+				} else {
+					acc = fn(append([]float64{float64(n)}, r...))
+				}
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		}),
+	}
+}
@@ -0,0 +1,65 @@
+package goscheme
+
+import (
+	"strconv"
+	"testing"
+)
+
+//Every integer kind accepts exactly the values from its min up to but not
+//including its lim.
+func TestHomogeneousRange(t *testing.T) {
+	for _, k := range numKinds {
+		if !k.integer {
+			continue
+		}
+		ok := []float64{k.min, 0}
+		bad := []float64{k.lim, 0.5}
+		//Below 2^53 the neighbours of the limits are exact.
+		if k.lim <= 1<<53 {
+			ok = append(ok, k.lim-1)
+			bad = append(bad, k.min-1)
+		}
+		for _, n := range ok {
+			if _, err := k.check(k.name(), Number(n), "Element 0"); err != nil {
+				t.Errorf("%s rejects %v: %s", k.name(), n, Sprint(err))
+			}
+		}
+		for _, n := range bad {
+			want := k.name() + ": Element 0 is not a valid " + k.tag + " value."
+			if got := Sprint(evalString(t, "("+k.name()+" "+strconv.FormatFloat(n, 'f', -1, 64)+")")); got != want {
+				t.Errorf("(%s %v) = %s, want %s", k.name(), n, got, want)
+			}
+		}
+	}
+}
+
+func TestHomogeneousVectors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(f32vector 0.1 -2)", "#f32(0.1 -2)"},
+		{"(f64vector-ref (f64vector 1.5 (/ 1. 0)) 1)", "+inf.0"},
+		{"(u8vector 1 2)", "#u8(1 2)"},
+		{"(bytevector? (u8vector 1))", "#t"},
+		{"(f64vector-fold + 0 #f64(1 2 3))", "6"},
+		{"(s32vector-map * #s32(1 2 3) #s32(4 5))", "#s32(4 10)"},
+		{"(s8vector-map + #s8(100) #s8(100))", "s8vector-map: The result is not a valid s8 value."},
+		{"(define s16 (make-s16vector 2 7)) (s16vector-set! s16 1 -300) s16", "#s16(7 -300)"},
+		{"(s16vector-set! s16 0 40000)", "s16vector-set!: Argument 3 is not a valid s16 value."},
+		{"(s16vector-ref s16 2)", "s16vector-ref: Index 2 is out of range."},
+		{"(u16vector-ref s16 0)", "u16vector-ref: Argument 1 is not a u16vector."},
+		{"(vector->f64vector #(1 a))", "vector->f64vector: Element 1 is not a valid f64 value."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+	if _, err := readOne(t, "#s8(1 200)"); err == nil || err.Error() != "#s8: Element 1 is not a valid s8 value." {
+		t.Errorf("Reading #s8(1 200) gives the error %v", err)
+	}
+	v, err := readOne(t, "#f64(1 2.5)")
+	if f, ok := v.(NumVector[float64]); err != nil || !ok || len(f.s) != 2 || f.s[0] != 1 || f.s[1] != 2.5 {
+		t.Errorf("#f64(1 2.5) is read as %#v, %v", v, err)
+	}
+}
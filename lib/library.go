@@ -0,0 +1,711 @@
+package goscheme
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Libraries (R7RS define-library and import)
+A library has its own environment, so the names it defines do not collide
+with those of other libraries or of the program. The environment is made of
+two parts: the library's own definitions, whose parent holds its imports,
+whose parent in turn is the standard environment. Procedures defined in a
+library look names up there rather than in the environment they are called
+from, as everything else in this interpreter does. The exception is
+current-output-port and the other variables in dynamicVariables, which are
+rebound for the extent of a call and so are taken from the caller. They are
+never bound by an import into a library.
+Importing a variable from a library defined with define-library binds an
+importedVar, so the importer sees the changes the library makes to the
+variable. The standard libraries are not changed by the interpreter, so their
+values are simply copied.
+The standard environment is split into the libraries in standardLibraries,
+which export its bindings. Other libraries are defined with define-library,
+or are found on library-path: (foo bar) is looked for in foo/bar.sld and
+foo/bar.scm under each directory of the path.
+An import set is a library name, or one of (only set name ...), (except set
+name ...), (prefix set prefix) and (rename set (name new-name) ...).
+*/
+
+type library struct {
+	env Environment
+	//exports maps the exported names to the names in env.
+	exports map[string]string
+	//standard is true for the libraries in standardLibraries.
+	standard bool
+}
+
+//binding is a name imported from a library.
+type binding struct {
+	value  Expr
+	syntax transformer
+	//name is the name in the library, before any renaming.
+	name string
+	//scope is the library's environment, or nil if the value is copied.
+	scope *Environment
+}
+
+//importedVar is bound to a variable imported from a library. It is looked up
+//in the environment of the library every time it is used.
+type importedVar struct {
+	scope *Environment
+	name  string
+}
+
+func (v importedVar) isExpr() {}
+
+//standardScope holds the bindings of the standard environment, which the
+//names a library neither defines nor imports are looked up in.
+var standardScope *Environment
+
+//libraries holds every library that has been defined, by name, and the ones
+//being loaded from a file.
+var libraries = struct {
+	sync.Mutex
+	m       map[string]*library
+	loading map[string]bool
+}{m: map[string]*library{}, loading: map[string]bool{}}
+
+//dynamicVariables are rebound for the extent of a call, as by
+//with-output-to-string, so they are left to be found in the caller's
+//environment rather than bound by an import into a library.
+var dynamicVariables = map[string]bool{
+	"current-input-port":  true,
+	"current-output-port": true,
+	"current-readtable":   true,
+	"library-path":        true,
+}
+
+//libraryScope returns the environment e for a call to a procedure made in the
+//environment env, which is in a library. Its parent is where the procedure was
+//defined instead of the caller, but the dynamic variables are still taken
+//from the caller.
+func libraryScope(env Environment, e Environment) Environment {
+	caller := e.Parent
+	e.Parent, e.lexical = env.Parent, true
+	if caller == nil {
+		return e
+	}
+	for n := range dynamicVariables {
+		if scope := caller.find(n); scope != nil && e.Local[n] == nil {
+			e.Local[n] = scope[n]
+		}
+	}
+	return e
+}
+
+//features are the feature identifiers cond-expand tests for.
+var features = []string{"r7rs", "goscheme", "full-unicode", "srfi-1", "srfi-4",
+	"srfi-6", "srfi-14", "srfi-28", "srfi-48", "srfi-69", "srfi-130",
+	"srfi-132", "srfi-133", runtime.GOOS, runtime.GOARCH}
+
+//standardLibraries lists the names each built in library exports from the
+//standard environment.
+var standardLibraries = map[string]string{
+	"scheme base": `* + - / < <= = > >= abs and append apply assoc assq assv begin
+		boolean? bytevector bytevector-append bytevector-copy bytevector-copy!
+		bytevector-length bytevector-u8-ref bytevector-u8-set! bytevector?
+		caar cadr car cdar cddr cdr ceiling char->integer char-ready? char<=?
+		char<? char=? char>=? char>? char? close-input-port close-output-port
+		close-port complex? cond cons current-input-port current-output-port
+		eof-object eof-object? eq? equal? eqv? error even? expt features floor
+		for-each get-output-bytevector get-output-string input-port?
+		integer->char integer? length let list list->string list->vector
+		list-copy list-ref list-tail list? make-bytevector make-list
+		make-vector map max member memq memv min modulo negative? newline not
+		null? number->string number? odd? open-input-bytevector
+		open-input-string open-output-bytevector open-output-string or
+		output-port? pair? peek-char peek-u8 positive? procedure?
+		read-bytevector read-bytevector! read-char read-line read-string
+		read-u8 remainder reverse round string string->list string->number
+		string->symbol string->utf8 string->vector string-append string-copy
+		string-copy! string-fill! string-for-each string-length string-map
+		string-ref string-set! string<=? string<? string=? string>=? string>?
+		string? substring symbol->string symbol=? symbol? truncate u8-ready?
+		utf8->string vector vector->list vector->string vector-append
+		vector-copy vector-copy! vector-fill! vector-for-each vector-length
+		vector-map vector-ref vector-set! vector? write-bytevector write-char
+		write-string write-u8 zero?`,
+	"scheme char": `char-alphabetic? char-ci<=? char-ci<? char-ci=? char-ci>=? char-ci>?
+		char-downcase char-foldcase char-lower-case? char-numeric? char-upcase
+		char-upper-case? char-whitespace? digit-value string-ci<=? string-ci<?
+		string-ci=? string-ci>=? string-ci>? string-downcase string-foldcase
+		string-upcase`,
+	"scheme complex": `angle imag-part magnitude make-polar make-rectangular real-part`,
+	"scheme cxr": `caaaar caaadr caaar caadar caaddr caadr cadaar cadadr cadar caddar
+		cadddr caddr cdaaar cdaadr cdaar cdadar cdaddr cdadr cddaar cddadr
+		cddar cdddar cddddr cdddr`,
+	"scheme eval":            `environment eval null-environment`,
+	"scheme file":            `open-input-file open-output-file`,
+	"scheme inexact":         `acos asin atan cos exp log sin sqrt tan`,
+	"scheme load":            `load`,
+	"scheme process-context": `exit`,
+	"scheme read":            `read`,
+	"scheme repl":            `interaction-environment`,
+	"scheme write":           `display write write-shared write-simple`,
+	"goscheme base": `byte? bytes->chars char->bytes char-general-category error?
+		generate-uninterned-symbol library-path string->uninterned-symbol
+		subvector`,
+	"goscheme channels": `-> <- chan sleep`,
+	"goscheme lists":    `aslist fold-left join some? sort split`,
+	"goscheme ports": `call-with-output-string close file-size flush make-custom-input-port
+		make-custom-output-port make-pipe open-file port->list port-buffering
+		port-fold port-for-each-line port-position read-all read-bytes
+		set-port-buffering! set-port-position! slurpfile truncate-file
+		with-output-to-string`,
+	"goscheme procedures": `procedure-arity procedure-documentation procedure-name
+		procedure-source`,
+	"goscheme reader": `current-readtable define-dispatch-macro define-reader-macro
+		make-readtable readtable?`,
+	"goscheme regexp": `regexp regexp-match regexp-match-positions regexp-replace
+		regexp-replace-all regexp-search-all regexp-split regexp?`,
+	"srfi 1": `alist-cons alist-copy alist-delete alist-delete! any append
+		append! append-map append-map! append-reverse append-reverse! assoc assq
+		assv break break! caar cadr car car+cdr cdar cddr cdr circular-list
+		circular-list? concatenate concatenate! cons cons* count delete delete!
+		delete-duplicates delete-duplicates! dotted-list? drop drop-right
+		drop-right! drop-while eighth every fifth filter filter! filter-map find
+		find-tail first fold fold-right for-each fourth iota last last-pair
+		length length+ list list-copy list-index list-ref list-tabulate list=
+		lset-adjoin lset-diff+intersection lset-diff+intersection!
+		lset-difference lset-difference! lset-intersection lset-intersection!
+		lset-union lset-union! lset-xor lset-xor! lset<= lset= map map-in-order
+		member memq memv ninth not-pair? null-list? pair-fold pair-fold-right
+		pair-for-each pair? partition partition! proper-list? reduce
+		reduce-right remove remove! reverse reverse! second seventh sixth span
+		span! split-at split-at! take take! take-right take-while take-while!
+		tenth third unfold unfold-right unzip1 unzip2 unzip3 unzip4 unzip5 xcons
+		zip`,
+	"srfi 4": `f32vector f32vector->list f32vector->vector f32vector-append
+		f32vector-copy f32vector-copy! f32vector-fill! f32vector-fold
+		f32vector-for-each f32vector-length f32vector-map f32vector-ref
+		f32vector-set! f32vector? f64vector f64vector->list f64vector->vector
+		f64vector-append f64vector-copy f64vector-copy! f64vector-fill!
+		f64vector-fold f64vector-for-each f64vector-length f64vector-map
+		f64vector-ref f64vector-set! f64vector? list->f32vector
+		list->f64vector list->s16vector list->s32vector list->s64vector
+		list->s8vector list->u16vector list->u32vector list->u64vector
+		list->u8vector make-f32vector make-f64vector make-s16vector
+		make-s32vector make-s64vector make-s8vector make-u16vector
+		make-u32vector make-u64vector make-u8vector s16vector s16vector->list
+		s16vector->vector s16vector-append s16vector-copy s16vector-copy!
+		s16vector-fill! s16vector-fold s16vector-for-each s16vector-length
+		s16vector-map s16vector-ref s16vector-set! s16vector? s32vector
+		s32vector->list s32vector->vector s32vector-append s32vector-copy
+		s32vector-copy! s32vector-fill! s32vector-fold s32vector-for-each
+		s32vector-length s32vector-map s32vector-ref s32vector-set! s32vector?
+		s64vector s64vector->list s64vector->vector s64vector-append
+		s64vector-copy s64vector-copy! s64vector-fill! s64vector-fold
+		s64vector-for-each s64vector-length s64vector-map s64vector-ref
+		s64vector-set! s64vector? s8vector s8vector->list s8vector->vector
+		s8vector-append s8vector-copy s8vector-copy! s8vector-fill!
+		s8vector-fold s8vector-for-each s8vector-length s8vector-map
+		s8vector-ref s8vector-set! s8vector? u16vector u16vector->list
+		u16vector->vector u16vector-append u16vector-copy u16vector-copy!
+		u16vector-fill! u16vector-fold u16vector-for-each u16vector-length
+		u16vector-map u16vector-ref u16vector-set! u16vector? u32vector
+		u32vector->list u32vector->vector u32vector-append u32vector-copy
+		u32vector-copy! u32vector-fill! u32vector-fold u32vector-for-each
+		u32vector-length u32vector-map u32vector-ref u32vector-set! u32vector?
+		u64vector u64vector->list u64vector->vector u64vector-append
+		u64vector-copy u64vector-copy! u64vector-fill! u64vector-fold
+		u64vector-for-each u64vector-length u64vector-map u64vector-ref
+		u64vector-set! u64vector? u8vector u8vector->list u8vector->vector
+		u8vector-append u8vector-copy u8vector-copy! u8vector-fill!
+		u8vector-fold u8vector-for-each u8vector-length u8vector-map
+		u8vector-ref u8vector-set! u8vector? vector->f32vector
+		vector->f64vector vector->s16vector vector->s32vector
+		vector->s64vector vector->s8vector vector->u16vector vector->u32vector
+		vector->u64vector vector->u8vector`,
+	"srfi 14": `char-set char-set->list char-set->string char-set-adjoin
+		char-set-complement char-set-contains? char-set-count char-set-delete
+		char-set-difference char-set-filter char-set-fold char-set-for-each
+		char-set-intersection char-set-size char-set-union char-set-xor
+		char-set:ascii char-set:blank char-set:digit char-set:empty
+		char-set:full char-set:graphic char-set:hex-digit char-set:iso-control
+		char-set:letter char-set:letter+digit char-set:lower-case
+		char-set:printing char-set:punctuation char-set:symbol
+		char-set:title-case char-set:upper-case char-set:whitespace char-set<=
+		char-set= char-set? list->char-set string->char-set
+		ucs-range->char-set`,
+	"srfi 28": `format`,
+	"srfi 48": `format`,
+	"srfi 69": `alist->hash-table hash hash-by-identity hash-table->alist
+		hash-table-clear! hash-table-contains? hash-table-copy
+		hash-table-delete! hash-table-equivalence-function hash-table-exists?
+		hash-table-fold hash-table-hash-function hash-table-keys
+		hash-table-ref hash-table-ref/default hash-table-set! hash-table-size
+		hash-table-update! hash-table-update!/default hash-table-values
+		hash-table-walk hash-table? make-hash-table string-hash`,
+	"srfi 130": `string-contains string-cursor->index string-cursor-back
+		string-cursor-diff string-cursor-end string-cursor-forward
+		string-cursor-next string-cursor-prev string-cursor-ref
+		string-cursor-start string-cursor<=? string-cursor<? string-cursor=?
+		string-cursor>=? string-cursor>? string-cursor? string-for-each-cursor
+		string-index string-index->cursor string-index-right string-join
+		string-pad string-pad-right string-prefix? string-replace
+		string-reverse string-split string-suffix? string-trim
+		string-trim-both string-trim-right`,
+	"srfi 132": `list-delete-neighbor-dups list-merge list-sort list-sorted?
+		list-stable-sort vector-delete-neighbor-dups vector-merge vector-sort
+		vector-sort! vector-sorted? vector-stable-sort vector-stable-sort!`,
+	"srfi 133": `list->vector make-vector reverse-list->vector reverse-vector->list
+		string->vector vector vector->list vector->string vector-any
+		vector-append vector-binary-search vector-concatenate vector-copy
+		vector-copy! vector-count vector-cumulate vector-empty? vector-every
+		vector-fill! vector-fold vector-fold-right vector-for-each
+		vector-index vector-index-right vector-length vector-map vector-map!
+		vector-partition vector-ref vector-reverse! vector-reverse-copy
+		vector-set! vector-skip vector-skip-right vector-swap! vector-unfold
+		vector-unfold-right vector= vector?`,
+}
+
+//registerStandardLibraries defines the libraries in standardLibraries with the
+//bindings of e.
+func registerStandardLibraries(e Environment) {
+	env := e.copy()
+	standardScope = &env
+	libraries.Lock()
+	defer libraries.Unlock()
+	for name, names := range standardLibraries {
+		lib := &library{env, map[string]string{}, true}
+		for _, n := range strings.Fields(names) {
+			if _, ok := lib.lookup(n); !ok {
+				panic("Error while loading standard library: (" + name + ") exports " + n + ", which is not defined.")
+			}
+			lib.exports[n] = n
+		}
+		libraries.m["("+name+")"] = lib
+	}
+}
+
+//lookup finds what name is bound to in the library.
+func (l *library) lookup(name string) (binding, bool) {
+	if s := l.env.LocalSyntax[Intern(name)]; s != nil {
+		return binding{syntax: s, name: name}, true
+	}
+	if scope := l.env.find(name); scope != nil {
+		b := binding{value: scope[name], name: name}
+		if !l.standard {
+			b.scope = &l.env
+		}
+		return b, true
+	}
+	return binding{}, false
+}
+
+//bindings returns the exports of the library.
+func (l *library) bindings() map[string]binding {
+	bs := map[string]binding{}
+	for ext, name := range l.exports {
+		if b, ok := l.lookup(name); ok {
+			bs[ext] = b
+		}
+	}
+	return bs
+}
+
+//libraryKey checks that x is a library name, a list of symbols and
+//non-negative integers, and returns it as written along with its parts.
+func libraryKey(x Expr) (string, []string, bool) {
+	l, ok := x.(ExprList)
+	if !ok || l.Length() == 0 {
+		return "", nil, false
+	}
+	var parts []string
+	for _, p := range ExprListToSlice(l) {
+		switch v := p.(type) {
+		case Symbol:
+			parts = append(parts, v.name)
+		case Number:
+			if v < 0 || float64(v) != float64(int(v)) {
+				return "", nil, false
+			}
+			parts = append(parts, strconv.Itoa(int(v)))
+		default:
+			return "", nil, false
+		}
+	}
+	return Sprint(l), parts, true
+}
+
+//libraryPath returns the directories bound to library-path in e.
+func libraryPath(e Environment) []string {
+	if e.find("library-path") == nil {
+		return []string{"."}
+	}
+	return pathVariable(e, "library-path")
+}
+
+//libraryFile returns the file on the library path that defines the library
+//with the given parts, or "" if there is none.
+func libraryFile(parts []string, e Environment) string {
+	for _, dir := range libraryPath(e) {
+		base := filepath.Join(append([]string{dir}, parts...)...)
+		for _, ext := range []string{".sld", ".scm"} {
+			if fi, err := os.Stat(base + ext); err == nil && !fi.IsDir() {
+				return base + ext
+			}
+		}
+	}
+	return ""
+}
+
+//findLibrary returns the library named x, loading it from the library path if
+//it has not been defined yet.
+func findLibrary(name string, x Expr, e Environment) (*library, Expr) {
+	key, parts, ok := libraryKey(x)
+	if !ok {
+		return nil, Error{name + ": Invalid library name " + Sprint(x) + "."}
+	}
+	libraries.Lock()
+	lib, loading := libraries.m[key], libraries.loading[key]
+	libraries.Unlock()
+	if lib != nil {
+		return lib, nil
+	}
+	if loading {
+		return nil, Error{name + ": Library " + key + " imports itself."}
+	}
+	path := libraryFile(parts, e)
+	if path == "" {
+		return nil, Error{name + ": Library " + key + " not found in " + strings.Join(libraryPath(e), ", ") + "."}
+	}
+	libraries.Lock()
+	libraries.loading[key] = true
+	libraries.Unlock()
+	r := evalFile(path, GlobalEnv)
+	libraries.Lock()
+	delete(libraries.loading, key)
+	lib = libraries.m[key]
+	libraries.Unlock()
+	if isError(r) {
+		return nil, r
+	}
+	if lib == nil {
+		return nil, Error{name + ": " + path + " does not define the library " + key + "."}
+	}
+	return lib, nil
+}
+
+//importSet returns the bindings of an import set.
+func importSet(name string, set Expr, e Environment) (map[string]binding, Expr) {
+	l, ok := set.(ExprList)
+	if !ok || l.Length() == 0 {
+		return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+	}
+	s := ExprListToSlice(l)
+	op, _ := s[0].(Symbol)
+	modified := false
+	if op.symbol != nil && len(s) > 1 {
+		switch op.name {
+		case "only", "except", "prefix", "rename":
+			_, modified = s[1].(ExprList)
+		}
+	}
+	if !modified {
+		lib, err := findLibrary(name, set, e)
+		if err != nil {
+			return nil, err
+		}
+		return lib.bindings(), nil
+	}
+	bs, err := importSet(name, s[1], e)
+	if err != nil {
+		return nil, err
+	}
+	switch op.name {
+	case "only":
+		only := map[string]binding{}
+		for _, x := range s[2:] {
+			id, ok := x.(Symbol)
+			if !ok {
+				return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+			}
+			b, ok := bs[id.name]
+			if !ok {
+				return nil, Error{name + ": " + Sprint(set) + " names " + id.name + ", which is not imported."}
+			}
+			only[id.name] = b
+		}
+		return only, nil
+	case "except":
+		for _, x := range s[2:] {
+			id, ok := x.(Symbol)
+			if !ok {
+				return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+			}
+			if _, ok := bs[id.name]; !ok {
+				return nil, Error{name + ": " + Sprint(set) + " names " + id.name + ", which is not imported."}
+			}
+			delete(bs, id.name)
+		}
+		return bs, nil
+	case "prefix":
+		p, ok := s[len(s)-1].(Symbol)
+		if !ok || len(s) != 3 {
+			return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+		}
+		prefixed := map[string]binding{}
+		for n, b := range bs {
+			prefixed[p.name+n] = b
+		}
+		return prefixed, nil
+	}
+	renames := map[string]string{}
+	for _, x := range s[2:] {
+		r, _ := x.(ExprList)
+		pair := ExprListToSlice(r)
+		if len(pair) != 2 {
+			return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+		}
+		from, ok1 := pair[0].(Symbol)
+		to, ok2 := pair[1].(Symbol)
+		if !ok1 || !ok2 {
+			return nil, Error{name + ": Invalid import set " + Sprint(set) + "."}
+		}
+		if _, ok := bs[from.name]; !ok {
+			return nil, Error{name + ": " + Sprint(set) + " names " + from.name + ", which is not imported."}
+		}
+		renames[from.name] = to.name
+	}
+	renamed := map[string]binding{}
+	for n, b := range bs {
+		if _, ok := renames[n]; !ok {
+			renamed[n] = b
+		}
+	}
+	for from, to := range renames {
+		renamed[to] = bs[from]
+	}
+	return renamed, nil
+}
+
+//importSets binds the names imported by the import sets in e. In a library
+//the values are bound in the library's imports, the parent of e.
+func importSets(name string, e Environment, inLibrary bool, sets []Expr) Expr {
+	values := e.Local
+	if inLibrary {
+		values = e.Parent.Local
+	}
+	for _, set := range sets {
+		bs, err := importSet(name, set, e)
+		if err != nil {
+			return err
+		}
+		for n, b := range bs {
+			switch {
+			case b.syntax != nil:
+				e.LocalSyntax[Intern(n)] = b.syntax
+			case inLibrary && n == b.name && dynamicVariables[n]:
+			case b.scope != nil:
+				values[n] = importedVar{b.scope, b.name}
+			default:
+				values[n] = b.value
+			}
+		}
+	}
+	return nil
+}
+
+//defineLibrary evaluates (define-library name declaration ...) in e.
+func defineLibrary(el []Expr, e Environment) Expr {
+	if len(el) < 2 {
+		return Error{"define-library: Must be of form '(define-library <name> <declaration> ...)'."}
+	}
+	key, _, ok := libraryKey(el[1])
+	if !ok {
+		return Error{"define-library: Invalid library name " + Sprint(el[1]) + "."}
+	}
+	imports := Environment{map[string]Expr{}, map[Symbol]transformer{}, standardScope, true}
+	lib := &library{Environment{map[string]Expr{}, map[Symbol]transformer{}, &imports, true}, map[string]string{}, false}
+	if err := lib.declare(el[2:]); err != nil {
+		return err
+	}
+	for _, n := range lib.exports {
+		if _, ok := lib.lookup(n); !ok {
+			return Error{"define-library: " + key + " exports " + n + ", which is not defined."}
+		}
+	}
+	libraries.Lock()
+	libraries.m[key] = lib
+	libraries.Unlock()
+	return Intern("")
+}
+
+//declare processes the declarations of a define-library.
+func (l *library) declare(decls []Expr) Expr {
+	for _, d := range decls {
+		dl, ok := d.(ExprList)
+		if !ok || dl.Length() == 0 {
+			return Error{"define-library: Invalid declaration " + Sprint(d) + "."}
+		}
+		s := ExprListToSlice(dl)
+		op, _ := s[0].(Symbol)
+		if op.symbol == nil {
+			return Error{"define-library: Invalid declaration " + Sprint(d) + "."}
+		}
+		var err Expr
+		switch op.name {
+		case "export":
+			err = l.export(s[1:])
+		case "import":
+			err = importSets("define-library", l.env, true, s[1:])
+		case "begin":
+			err = evalSequence(s[1:], l.env)
+		case "include", "include-ci":
+			var forms []Expr
+			if forms, err = includeFiles("define-library", s[1:], l.env, op.name == "include-ci"); err == nil {
+				err = evalSequence(forms, l.env)
+			}
+		case "include-library-declarations":
+			var forms []Expr
+			if forms, err = includeFiles("define-library", s[1:], l.env, false); err == nil {
+				err = l.declare(forms)
+			}
+		case "cond-expand":
+			var body []Expr
+			if body, err = condExpand("define-library", s[1:], l.env); err == nil {
+				err = l.declare(body)
+			}
+		default:
+			err = Error{"define-library: Unknown declaration " + Sprint(d) + "."}
+		}
+		if isError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//export adds the export specs of an export declaration, which are names or
+//(rename name exported-name).
+func (l *library) export(specs []Expr) Expr {
+	for _, spec := range specs {
+		if id, ok := spec.(Symbol); ok {
+			l.exports[id.name] = id.name
+			continue
+		}
+		r, ok := spec.(ExprList)
+		s := ExprListToSlice(r)
+		if ok && len(s) == 3 && s[0] == Expr(Intern("rename")) {
+			from, ok1 := s[1].(Symbol)
+			to, ok2 := s[2].(Symbol)
+			if ok1 && ok2 {
+				l.exports[to.name] = from.name
+				continue
+			}
+		}
+		return Error{"define-library: Invalid export " + Sprint(spec) + "."}
+	}
+	return nil
+}
+
+//evalSequence evaluates forms in order and returns the value of the last
+//one, stopping at the first error.
+func evalSequence(forms []Expr, e Environment) Expr {
+	var r Expr = Intern("")
+	for _, f := range forms {
+		if r = Eval(f, e); isError(r) {
+			break
+		}
+	}
+	return r
+}
+
+//condExpand returns the body of the first cond-expand clause whose feature
+//requirement is met.
+func condExpand(name string, clauses []Expr, e Environment) ([]Expr, Expr) {
+	for _, c := range clauses {
+		l, ok := c.(ExprList)
+		if !ok || l.Length() == 0 {
+			return nil, Error{name + ": Invalid cond-expand clause " + Sprint(c) + "."}
+		}
+		s := ExprListToSlice(l)
+		met, err := featureMet(name, s[0], e)
+		if err != nil {
+			return nil, err
+		}
+		if met {
+			return s[1:], nil
+		}
+	}
+	return nil, nil
+}
+
+//featureMet tests a cond-expand feature requirement.
+func featureMet(name string, req Expr, e Environment) (bool, Expr) {
+	if id, ok := req.(Symbol); ok {
+		if id.name == "else" {
+			return true, nil
+		}
+		for _, f := range features {
+			if id.name == f {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	l, ok := req.(ExprList)
+	s := ExprListToSlice(l)
+	var op Symbol
+	if ok && len(s) > 0 {
+		op, _ = s[0].(Symbol)
+	}
+	if op.symbol == nil {
+		return false, Error{name + ": Invalid feature requirement " + Sprint(req) + "."}
+	}
+	switch op.name {
+	case "and", "or":
+		for _, r := range s[1:] {
+			met, err := featureMet(name, r, e)
+			if err != nil {
+				return false, err
+			}
+			if met == (op.name == "or") {
+				return met, nil
+			}
+		}
+		return op.name == "and", nil
+	case "not":
+		if len(s) == 2 {
+			met, err := featureMet(name, s[1], e)
+			return !met, err
+		}
+	case "library":
+		if len(s) == 2 {
+			key, parts, ok := libraryKey(s[1])
+			if ok {
+				libraries.Lock()
+				lib := libraries.m[key]
+				libraries.Unlock()
+				return lib != nil || libraryFile(parts, e) != "", nil
+			}
+		}
+	}
+	return false, Error{name + ": Invalid feature requirement " + Sprint(req) + "."}
+}
+
+//(features) returns the feature identifiers that cond-expand recognizes.
+func features_(e Environment, args ...Expr) Expr {
+	l := make([]Expr, len(features))
+	for i, f := range features {
+		l[i] = Intern(f)
+	}
+	return SliceToExprList(l)
+}
+
+//(environment import-set ...) returns an environment with the bindings of the
+//import sets, for use with eval.
+func environment(e Environment, args ...Expr) Expr {
+	env := Environment{map[string]Expr{}, map[Symbol]transformer{}, nil, false}
+	if err := importSets("environment", env, false, args); err != nil {
+		return err
+	}
+	return env
+}
@@ -0,0 +1,94 @@
+package goscheme
+
+import (
+	"strings"
+	"testing"
+)
+
+//An imported variable is looked up in the library, and the procedures of a
+//library see its own definitions rather than the importer's, except for the
+//dynamic variables.
+func TestLibraryScope(t *testing.T) {
+	evalString(t, `
+(define-library (test counter)
+  (export counter bump! helper-user greet)
+  (import (scheme base) (scheme write))
+  (begin
+    (define counter 0)
+    (define bump! (lambda () (set! counter (+ counter 1))))
+    (define helper (lambda () 'library))
+    (define helper-user (lambda () (helper)))
+    (define greet (lambda () (display "hi")))))
+(import (test counter))
+(define helper (lambda () 'program))`)
+	evalString(t, "(bump!) (bump!)")
+	if got := evalString(t, "counter"); got != Expr(Number(2)) {
+		t.Errorf("counter = %s after the library changed it to 2.", Sprint(got))
+	}
+	if got := evalString(t, "(helper-user)"); got != Expr(Intern("library")) {
+		t.Errorf("A library procedure calls the helper of the program: %s", Sprint(got))
+	}
+	if got := Sprint(evalString(t, "(with-output-to-string greet)")); got != `"hi"` {
+		t.Errorf("with-output-to-string does not capture the output of a library procedure: %s", got)
+	}
+	want := "set!: counter is imported from a library and cannot be assigned."
+	if got := Sprint(evalString(t, "(set! counter 5)")); got != want {
+		t.Errorf("(set! counter 5) = %s, want %s", got, want)
+	}
+	if got := evalString(t, "counter"); got != Expr(Number(2)) {
+		t.Errorf("counter = %s after a failed set!", Sprint(got))
+	}
+}
+
+//Import sets select and rename the exports of a library, whose declarations
+//can come from other files and depend on the features of the interpreter.
+func TestImportSets(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"body.scm":  "(define included 'from-file)",
+		"decls.scm": "(export shout) (begin (define shout (lambda (s) (string-upcase s))))",
+	})
+	r := evalString(t, `
+(define-library (test shapes)
+  (export area perimeter (rename internal-name public-name) included which)
+  (import (scheme base))
+  (include "`+dir+`/body.scm")
+  (include-library-declarations "`+dir+`/decls.scm")
+  (cond-expand
+    (goscheme (begin (define which 'goscheme)))
+    (else (begin (define which 'other))))
+  (begin
+    (define area (lambda (w h) (* w h)))
+    (define perimeter (lambda (w h) (* 2 (+ w h))))
+    (define internal-name 'renamed)))`)
+	if isError(r) {
+		t.Fatal(Sprint(r))
+	}
+	evalString(t, "(import (only (test shapes) area))")
+	if got := Sprint(evalString(t, "(area 2 3)")); got != "6" {
+		t.Errorf("(area 2 3) = %s after importing only area", got)
+	}
+	if got := evalString(t, "perimeter"); got != nil {
+		t.Errorf("perimeter is bound to %s, but was not imported", Sprint(got))
+	}
+	evalString(t, "(import (prefix (except (test shapes) area) s:))")
+	if got := Sprint(evalString(t, `(list (s:perimeter 1 2) s:public-name s:included (s:shout "hi") s:which)`)); got != `(6 renamed from-file "HI" goscheme)` {
+		t.Errorf("The prefixed imports are %s", got)
+	}
+	if got := evalString(t, "s:area"); got != nil {
+		t.Errorf("s:area is bound to %s, but area was excepted", Sprint(got))
+	}
+	evalString(t, "(import (rename (test shapes) (area rect-area)))")
+	if got := Sprint(evalString(t, "(rect-area 4 5)")); got != "20" {
+		t.Errorf("(rect-area 4 5) = %s after renaming area", got)
+	}
+	for src, want := range map[string]string{
+		"(import (only (test shapes) nope))":                 "import: (only (test shapes) nope) names nope, which is not imported.",
+		"(define-library (test bad) (export missing))":       "define-library: (test bad) exports missing, which is not defined.",
+		"(define-library (test bad) (frobnicate))":           "define-library: Unknown declaration (frobnicate).",
+		"(define-library (test bad) (include \"/missing\"))": "define-library: ",
+	} {
+		if got := Sprint(evalString(t, src)); !strings.HasPrefix(got, want) {
+			t.Errorf("%s = %s, want %s", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,2039 @@
+package goscheme
+
+import (
+	"math"
+	"strconv"
+)
+
+/*
+The list library from SRFI 1. A pair made by consing onto something that is
+not a list is a two element list whose last cell has no cdr. Such dotted lists
+are recognized by dotted-list?, but every other procedure treats them like
+proper lists, and the procedures SRFI 1 defines on pairs work on the cells of
+lists.
+Procedures that return two values in SRFI 1, like partition and span, return a
+list of the two values.
+The linear update variants such as reverse! are the same procedures as the
+pure ones, since they are allowed to but do not have to reuse their argument.
+Circular lists are accepted where SRFI 1 allows them: procedures taking several
+lists stop at the end of the shortest finite one, and procedures that stop
+before the end of their list, like take and find, walk only as far as they
+need to. The other procedures return an error for a circular list instead of
+looping forever.
+*/
+
+//cells returns the cells of l, so that l.car of each is an element and the
+//cell itself is the tail of l starting there.
+func cells(l ExprList) []*ExprList {
+	ret := []*ExprList{}
+	for it := &l; it != nil && it.car != nil; it = it.cdr {
+		ret = append(ret, it)
+	}
+	return ret
+}
+
+//prepend returns the list of the elements of s followed by the elements of
+//tail. The cells of tail are shared.
+func prepend(s []Expr, tail ExprList) ExprList {
+	for i := len(s) - 1; i >= 0; i-- {
+		x, t := s[i], tail
+		tail = ExprList{&x, &t}
+	}
+	return tail
+}
+
+//listLength returns the length of l, or -1 if l is circular.
+func listLength(l ExprList) int {
+	n := 0
+	slow, fast := &l, &l
+	for fast != nil && fast.car != nil {
+		fast = fast.cdr
+		n++
+		if fast == nil || fast.car == nil {
+			break
+		}
+		fast = fast.cdr
+		n++
+		slow = slow.cdr
+		if fast != nil && fast.car != nil && fast.car == slow.car {
+			return -1
+		}
+	}
+	return n
+}
+
+//prefix returns the first n elements of l, which may be circular but must have
+//at least n elements.
+func prefix(l ExprList, n int) []Expr {
+	ret := make([]Expr, n)
+	it := &l
+	for i := range ret {
+		ret[i] = *it.car
+		it = it.cdr
+	}
+	return ret
+}
+
+//walk calls f with the cells of l in order, until f returns false or an error
+//or the list ends. If l is circular, the walk also ends when it gets back to a
+//cell it has already been at, and walk returns true.
+func walk(l ExprList, f func(c *ExprList) (bool, Expr)) (bool, Expr) {
+	var seen map[*Expr]bool
+	if listLength(l) < 0 {
+		seen = map[*Expr]bool{}
+	}
+	for c := &l; c != nil && c.car != nil; c = c.cdr {
+		if seen != nil {
+			if seen[c.car] {
+				return true, nil
+			}
+			seen[c.car] = true
+		}
+		if ok, err := f(c); err != nil || !ok {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+//cellsArg returns the cells of the list in args[i], which must not be
+//circular.
+func cellsArg(name string, args []Expr, i int) ([]*ExprList, Expr) {
+	l, ok := args[i].(ExprList)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a list."}
+	}
+	if listLength(l) < 0 {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is a circular list."}
+	}
+	return cells(l), nil
+}
+
+//callPred calls the predicate p, returning whether the result is true.
+func callPred(e Environment, p Proc, args ...Expr) (bool, Expr) {
+	r := callProc(e, p, args...)
+	if _, ok := r.(Error); ok {
+		return false, r
+	}
+	return truthy(r), nil
+}
+
+//listsArgs checks that every argument from args[from] onwards is a list and
+//returns their elements.
+func listsArgs(name string, args []Expr, from int) ([][]Expr, Expr) {
+	ret := make([][]Expr, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		l, err := listArg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+//clistsArgs is like listsArgs, except that the lists may be circular as long
+//as one of them is finite. As many elements are returned from each list as
+//the shortest finite list has.
+func clistsArgs(name string, args []Expr, from int) ([][]Expr, Expr) {
+	n, err := shortestArg(name, args, from)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([][]Expr, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		ret = append(ret, prefix(args[i].(ExprList), n))
+	}
+	return ret, nil
+}
+
+//shortestArg checks that every argument from args[from] onwards is a list and
+//that at least one of them is finite, and returns the length of the shortest
+//finite one.
+func shortestArg(name string, args []Expr, from int) (int, Expr) {
+	n := -1
+	for i := from; i < len(args); i++ {
+		l, ok := args[i].(ExprList)
+		if !ok {
+			return 0, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a list."}
+		}
+		if m := listLength(l); m >= 0 && (n < 0 || m < n) {
+			n = m
+		}
+	}
+	if n < 0 && len(args) > from {
+		return 0, Error{name + ": At least one of the lists must be finite."}
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, nil
+}
+
+//rows returns the lists of the i-th elements of every list in ls, as many as
+//there are elements in the shortest list.
+func rows(ls [][]Expr) [][]Expr {
+	if len(ls) == 0 {
+		return nil
+	}
+	n := len(ls[0])
+	for _, l := range ls {
+		if len(l) < n {
+			n = len(l)
+		}
+	}
+	ret := make([][]Expr, n)
+	for i := range ret {
+		ret[i] = make([]Expr, len(ls))
+		for j, l := range ls {
+			ret[i][j] = l[i]
+		}
+	}
+	return ret
+}
+
+//procAndRows reads a procedure followed by one or more lists.
+func procAndRows(name string, args []Expr) (Proc, [][]Expr, Expr) {
+	p, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	ls, err := clistsArgs(name, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, rows(ls), nil
+}
+
+//countArg reads a non-negative integer.
+func countArg(name string, args []Expr, i int) (int, Expr) {
+	n, ok := args[i].(Number)
+	if !ok || float64(n) != float64(int(n)) || n < 0 {
+		return 0, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a non-negative integer."}
+	}
+	return int(n), nil
+}
+
+//equivArg returns the equivalence in args[i], defaulting to equal?, along
+//with a hash function that agrees with it.
+func equivArg(e Environment, name string, args []Expr, i int) (func(a, b Expr) (bool, Expr), func(x Expr) (uint64, Expr), Expr) {
+	var p Proc
+	if len(args) > i {
+		var err Expr
+		if p, err = procArg(name, args, i); err != nil {
+			return nil, nil, err
+		}
+	}
+	equiv, hash := comparator(e, p, nil)
+	return equiv, hash, nil
+}
+
+//exprSet holds elements that are distinct according to an equivalence.
+type exprSet struct {
+	equiv   func(a, b Expr) (bool, Expr)
+	hash    func(x Expr) (uint64, Expr)
+	buckets map[uint64][]Expr
+}
+
+func newExprSet(equiv func(a, b Expr) (bool, Expr), hash func(x Expr) (uint64, Expr)) *exprSet {
+	return &exprSet{equiv, hash, map[uint64][]Expr{}}
+}
+
+//contains reports whether an element equivalent to x is in the set.
+func (s *exprSet) contains(x Expr) (bool, Expr) {
+	h, err := s.hash(x)
+	if err != nil {
+		return false, err
+	}
+	for _, y := range s.buckets[h] {
+		eq, err := s.equiv(y, x)
+		if err != nil || eq {
+			return eq, err
+		}
+	}
+	return false, nil
+}
+
+//add adds x to the set, returning false if an equivalent element was already
+//in it.
+func (s *exprSet) add(x Expr) (bool, Expr) {
+	found, err := s.contains(x)
+	if err != nil || found {
+		return false, err
+	}
+	h, _ := s.hash(x)
+	s.buckets[h] = append(s.buckets[h], x)
+	return true, nil
+}
+
+//setOf returns the set of the elements of l.
+func setOf(equiv func(a, b Expr) (bool, Expr), hash func(x Expr) (uint64, Expr), l []Expr) (*exprSet, Expr) {
+	s := newExprSet(equiv, hash)
+	for _, x := range l {
+		if _, err := s.add(x); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+//keep returns the elements of l for which f returns want.
+func keep(l []Expr, want bool, f func(x Expr) (bool, Expr)) ([]Expr, Expr) {
+	ret := []Expr{}
+	for _, x := range l {
+		ok, err := f(x)
+		if err != nil {
+			return nil, err
+		}
+		if ok == want {
+			ret = append(ret, x)
+		}
+	}
+	return ret, nil
+}
+
+//**alist** An association list.
+//Returns a copy of alist where the entries are copied as well, so that they
+//can be modified without affecting alist.
+func alistcopy(e Environment, args ...Expr) Expr {
+	l, err := listArg("alist-copy", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := make([]Expr, len(l))
+	for i, x := range l {
+		entry, ok := x.(ExprList)
+		if !ok || listLength(entry) < 0 {
+			return Error{"alist-copy: Argument 1 is not an association list."}
+		}
+		ret[i] = SliceToExprList(ExprListToSlice(entry))
+	}
+	return SliceToExprList(ret)
+}
+
+//**key** The key of the new entry.
+//**value** The value of the new entry.
+//**alist** An association list.
+//Returns alist with the entry (key . value) added to the front.
+//Example: `(alist-cons 'a 1 '((b 2))) => ((a 1) (b 2))`
+func alistcons(e Environment, args ...Expr) Expr {
+	l, ok := args[2].(ExprList)
+	if !ok {
+		return Error{"alist-cons: Argument 3 is not a list."}
+	}
+	entry := cons(e, args[0], args[1])
+	return ExprList{&entry, &l}
+}
+
+//**key** The key of the entries to delete.
+//**alist** An association list.
+//**=** (optional) The equivalence used to compare keys, equal? by default.
+//Returns alist without the entries whose key is key.
+//Example: `(alist-delete 'a '((a 1) (b 2) (a 3))) => ((b 2))`
+func alistdelete(e Environment, args ...Expr) Expr {
+	l, err := listArg("alist-delete", args, 1)
+	if err != nil {
+		return err
+	}
+	equiv, _, err := equivArg(e, "alist-delete", args, 2)
+	if err != nil {
+		return err
+	}
+	ret, err := keep(l, false, func(x Expr) (bool, Expr) {
+		entry, ok := x.(ExprList)
+		if !ok || entry.car == nil {
+			return false, Error{"alist-delete: Argument 2 is not an association list."}
+		}
+		return equiv(args[0], *entry.car)
+	})
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//**pred** A predicate taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Applies pred to the first elements of the lists, then to the second elements
+//and so on. Returns the first true value pred returns, or #f if it never does.
+//Examples:
+//`(any number? '(a b 1)) => #t`
+//`(any < '(3 2 1) '(1 1 1)) => #f`
+func any_(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndRows("any", args)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if v := callProc(e, pred, r...); truthy(v) {
+			return v
+		}
+	}
+	return Boolean(false)
+}
+
+//**x** A list to append to.
+//**y** An element to append to the list. Can be another list.
+//**z** The function is variadic so that any further arguments will be appended.
+//The last argument is not copied, it becomes the tail of the result.
+//Examples:
+//`(append '(1 2 3) 4) => (1 2 3 4)`
+//`(append '(1 2 3) '(4 5 6)) => (1 2 3 4 5 6)`
+//`(append '(1 2 3) 4 '(5 6)) => (1 2 3 4 5 6)`
+func append_(e Environment, args ...Expr) Expr {
+	if len(args) == 0 {
+		return ExprList{}
+	}
+	tail, ok := args[len(args)-1].(ExprList)
+	if !ok {
+		tail = SliceToExprList(args[len(args)-1:])
+	}
+	head := []Expr{}
+	for i, x := range args[:len(args)-1] {
+		if l, ok := x.(ExprList); ok {
+			if listLength(l) < 0 {
+				return Error{"append: Argument " + strconv.Itoa(i+1) + " is a circular list."}
+			}
+			head = append(head, ExprListToSlice(l)...)
+		} else {
+			head = append(head, x)
+		}
+	}
+	return prepend(head, tail)
+}
+
+//**f** A function returning a list, taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Maps f over the lists and appends the results together.
+//Example: `(append-map (lambda (x) (list x (- x))) '(1 3)) => (1 -1 3 -3)`
+func appendmap(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndRows("append-map", args)
+	if err != nil {
+		return err
+	}
+	ret := []Expr{}
+	for _, r := range rs {
+		v := callProc(e, f, r...)
+		l, ok := v.(ExprList)
+		if !ok {
+			if _, ok := v.(Error); ok {
+				return v
+			}
+			return Error{"append-map: The function returned " + Sprint(v) + ", which is not a list."}
+		}
+		if listLength(l) < 0 {
+			return Error{"append-map: The function returned a circular list."}
+		}
+		ret = append(ret, ExprListToSlice(l)...)
+	}
+	return SliceToExprList(ret)
+}
+
+//**rev-head** A list which is reversed.
+//**tail** A list to append to the reversed rev-head.
+//Example: `(append-reverse '(3 2 1) '(4 5)) => (1 2 3 4 5)`
+func appendreverse(e Environment, args ...Expr) Expr {
+	l, err := listArg("append-reverse", args, 0)
+	if err != nil {
+		return err
+	}
+	tail, ok := args[1].(ExprList)
+	if !ok {
+		return Error{"append-reverse: Argument 2 is not a list."}
+	}
+	for _, x := range l {
+		x, t := x, tail
+		tail = ExprList{&x, &t}
+	}
+	return tail
+}
+
+//assq, assv and assoc find the first entry in alist whose key is equivalent
+//to key.
+func assoc_(e Environment, name string, equiv func(a, b Expr) (bool, Expr), key Expr, alist Expr) Expr {
+	l, ok := alist.(ExprList)
+	if !ok {
+		return Error{name + ": Argument 2 is not a list."}
+	}
+	if listLength(l) < 0 {
+		return Error{name + ": Argument 2 is a circular list."}
+	}
+	for _, x := range ExprListToSlice(l) {
+		entry, ok := x.(ExprList)
+		if !ok || entry.car == nil {
+			return Error{name + ": Argument 2 is not an association list."}
+		}
+		eq, err := equiv(key, *entry.car)
+		if err != nil {
+			return err
+		}
+		if eq {
+			return entry
+		}
+	}
+	return Boolean(false)
+}
+
+//**obj** The key to search for.
+//**alist** An association list, a list of lists whose first elements are keys.
+//**=** (optional) The equivalence used to compare keys, equal? by default.
+//Returns the first entry of alist whose key is obj, or #f if there is none.
+//Example: `(assoc "b" '(("a" 1) ("b" 2))) => ("b" 2)`
+func assoc(e Environment, args ...Expr) Expr {
+	equiv, _, err := equivArg(e, "assoc", args, 2)
+	if err != nil {
+		return err
+	}
+	return assoc_(e, "assoc", equiv, args[0], args[1])
+}
+
+//**obj** The key to search for.
+//**alist** An association list.
+//See assoc. This function uses eq? instead of equal?.
+func assq(e Environment, args ...Expr) Expr {
+	return assoc_(e, "assq", func(a, b Expr) (bool, Expr) { return isEqv(a, b), nil }, args[0], args[1])
+}
+
+//**obj** The key to search for.
+//**alist** An association list.
+//See assoc. This function uses eqv? instead of equal?.
+func assv(e Environment, args ...Expr) Expr {
+	return assoc_(e, "assv", func(a, b Expr) (bool, Expr) { return isEqv(a, b), nil }, args[0], args[1])
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Splits li before the first element which satisfies pred, returning a list of
+//the two parts.
+//Example: `(break even? '(1 3 4 5)) => ((1 3) (4 5))`
+func break_(e Environment, args ...Expr) Expr {
+	return span_(e, "break", false, args)
+}
+
+//**pair** A non-empty list.
+//Returns a list of the car and the cdr of pair.
+//Example: `(car+cdr '(1 2 3)) => (1 (2 3))`
+func carcdr(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok || l.car == nil {
+		return Error{"car+cdr: Argument 1 is not a pair."}
+	}
+	return SliceToExprList([]Expr{*l.car, cdr(e, l)})
+}
+
+//**elts** (variadic) One or more elements.
+//Returns a circular list of the elements, whose last cell continues with the
+//first one.
+//Example: `(circular-list 1 2) => #0=(1 2 . #0#)`
+func circularlist(e Environment, args ...Expr) Expr {
+	l := SliceToExprList(args)
+	cs := cells(l)
+	*cs[len(cs)-1].cdr = l
+	return l
+}
+
+//**li** A list of lists to concatenate.
+//Concatenates all lists in li into one list.
+//Example: `(concatenate '((1 2 3) (4 5 6))) => (1 2 3 4 5 6)`
+func concatenate(e Environment, args ...Expr) Expr {
+	l, err := listArg("concatenate", args, 0)
+	if err != nil {
+		return err
+	}
+	return append_(e, l...)
+}
+
+//**elts** (variadic) Elements to put in front of the last argument.
+//**tail** A list.
+//Like list, except that the last argument becomes the tail of the result.
+//Example: `(cons* 1 2 '(3 4)) => (1 2 3 4)`
+func consstar(e Environment, args ...Expr) Expr {
+	return append_(e, SliceToExprList(args[:len(args)-1]), args[len(args)-1])
+}
+
+//**pred** A predicate taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Returns the number of times pred returns true.
+//Example: `(count even? '(1 2 4)) => 2`
+func count(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndRows("count", args)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, r := range rs {
+		ok, err := callPred(e, pred, r...)
+		if err != nil {
+			return err
+		}
+		if ok {
+			n++
+		}
+	}
+	return Number(n)
+}
+
+//**obj** The object to delete.
+//**li** A list.
+//**=** (optional) The equivalence used to compare elements, equal? by default.
+//Returns li without the elements that are equal to obj.
+//Example: `(delete 2 '(1 2 3 2)) => (1 3)`
+func delete_(e Environment, args ...Expr) Expr {
+	l, err := listArg("delete", args, 1)
+	if err != nil {
+		return err
+	}
+	equiv, _, err := equivArg(e, "delete", args, 2)
+	if err != nil {
+		return err
+	}
+	ret, err := keep(l, false, func(x Expr) (bool, Expr) { return equiv(args[0], x) })
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//**li** A list.
+//**=** (optional) The equivalence used to compare elements, equal? by default.
+//Returns li with only the first of every group of equal elements.
+//Example: `(delete-duplicates '(a b a c b)) => (a b c)`
+func deleteduplicates(e Environment, args ...Expr) Expr {
+	l, err := listArg("delete-duplicates", args, 0)
+	if err != nil {
+		return err
+	}
+	equiv, hash, err := equivArg(e, "delete-duplicates", args, 1)
+	if err != nil {
+		return err
+	}
+	s := newExprSet(equiv, hash)
+	ret, err := keep(l, true, s.add)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//**x** An object to check.
+//Returns #t if x is a dotted list, which is a pair made by consing onto
+//something that is not a list, or any object that is not a list at all.
+//Examples:
+//`(dotted-list? (cons 1 2)) => #t`
+//`(dotted-list? '(1 2)) => #f`
+func dottedlist_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Boolean(true)
+	}
+	if listLength(l) < 0 {
+		return Boolean(false)
+	}
+	cs := cells(l)
+	return Boolean(len(cs) > 0 && cs[len(cs)-1].cdr == nil)
+}
+
+//**li** A list.
+//**k** The number of elements to drop.
+//Returns the tail of li after its first k elements.
+//Example: `(drop '(1 2 3 4) 2) => (3 4)`
+func drop(e Environment, args ...Expr) Expr {
+	return listtail_("drop", args)
+}
+
+//**li** A list.
+//**k** The number of elements to drop from the end of li.
+//Returns all but the last k elements of li.
+//Example: `(drop-right '(1 2 3 4) 1) => (1 2 3)`
+func dropright(e Environment, args ...Expr) Expr {
+	l, err := listArg("drop-right", args, 0)
+	if err != nil {
+		return err
+	}
+	k, err := indexArg("drop-right", args, 1, len(l))
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(l[:len(l)-k])
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Returns the tail of li starting at the first element which does not satisfy
+//pred.
+//Example: `(drop-while even? '(2 4 5 6)) => (5 6)`
+func dropwhile(e Environment, args ...Expr) Expr {
+	r := span_(e, "drop-while", true, args)
+	if l, ok := r.(ExprList); ok {
+		return *l.cdr.car
+	}
+	return r
+}
+
+//**pred** A predicate taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Applies pred to the first elements of the lists, then to the second elements
+//and so on. Returns #f as soon as pred does, otherwise the value pred returned
+//last, or #t if the lists are empty.
+//Examples:
+//`(every number? '(1 2 3)) => #t`
+//`(every < '(1 2) '(2 1)) => #f`
+func every(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndRows("every", args)
+	if err != nil {
+		return err
+	}
+	var v Expr = Boolean(true)
+	for _, r := range rs {
+		if v = callProc(e, pred, r...); !truthy(v) {
+			return v
+		}
+	}
+	return v
+}
+
+//**pred** A predicate which is sought after in the list.
+//**li** A list to filter.
+//Returns all elements of li which satisfy pred.
+//Example: `(filter number? '(1 2 a b c 3 d 4 e)) => (1 2 3 4)`
+func filter(e Environment, args ...Expr) Expr {
+	return filter_(e, "filter", true, args)
+}
+
+func filter_(e Environment, name string, want bool, args []Expr) Expr {
+	pred, err := procArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg(name, args, 1)
+	if err != nil {
+		return err
+	}
+	ret, err := keep(l, want, func(x Expr) (bool, Expr) { return callPred(e, pred, x) })
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//**f** A function taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Maps f over the lists and keeps the results which are not #f.
+//Example: `(filter-map (lambda (x) (and (number? x) (* x x))) '(a 1 b 3)) => (1 9)`
+func filtermap(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndRows("filter-map", args)
+	if err != nil {
+		return err
+	}
+	ret := []Expr{}
+	for _, r := range rs {
+		v := callProc(e, f, r...)
+		if _, ok := v.(Error); ok {
+			return v
+		}
+		if truthy(v) {
+			ret = append(ret, v)
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Returns the first element of li which satisfies pred, or #f if there is none.
+//Example: `(find even? '(1 3 4 5)) => 4`
+func find(e Environment, args ...Expr) Expr {
+	r := findtail(e, args...)
+	if l, ok := r.(ExprList); ok {
+		return *l.car
+	}
+	return r
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Returns the tail of li starting at the first element which satisfies pred, or
+//#f if there is none.
+//Example: `(find-tail even? '(1 3 4 5)) => (4 5)`
+func findtail(e Environment, args ...Expr) Expr {
+	pred, err := procArg("find-tail", args, 0)
+	if err != nil {
+		return err
+	}
+	l, ok := args[1].(ExprList)
+	if !ok {
+		return Error{"find-tail: Argument 2 is not a list."}
+	}
+	var found *ExprList
+	_, err = walk(l, func(c *ExprList) (bool, Expr) {
+		ok, err := callPred(e, pred, *c.car)
+		if ok {
+			found = c
+		}
+		return !ok, err
+	})
+	if err != nil {
+		return err
+	}
+	if found != nil {
+		return *found
+	}
+	return Boolean(false)
+}
+
+//**kons** Function by which to fold the lists. It is called with an element of
+//every list followed by the accumulator.
+//**knil** The accumulator's starting value.
+//**lis** (variadic) One or more lists to fold.
+//Folds left over the lists. For an explanation that makes sense, see https://en.wikipedia.org/wiki/Fold_(higher-order_function)
+//fold-left is the same function.
+//Examples:
+//`(fold + 0 '(1 2 3)) => 6`
+//`(fold cons '() '(1 2 3)) => (3 2 1)`
+func fold(e Environment, args ...Expr) Expr {
+	kons, rs, err := foldArgs("fold", args)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for _, r := range rs {
+		if acc = callProc(e, kons, append(r, acc)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+//**kons** Function by which to fold the lists. It is called with an element of
+//every list followed by the accumulator.
+//**knil** The accumulator's starting value.
+//**lis** (variadic) One or more lists to fold.
+//Folds right over the lists. For an explanation that makes sense, see https://en.wikipedia.org/wiki/Fold_(higher-order_function)
+//Example: `(fold-right cons '() '(1 2 3)) => (1 2 3)`
+func foldright(e Environment, args ...Expr) Expr {
+	kons, rs, err := foldArgs("fold-right", args)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for i := len(rs) - 1; i >= 0; i-- {
+		if acc = callProc(e, kons, append(rs[i], acc)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+func foldArgs(name string, args []Expr) (Proc, [][]Expr, Expr) {
+	kons, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	ls, err := clistsArgs(name, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kons, rows(ls), nil
+}
+
+func isError(x Expr) bool {
+	_, ok := x.(Error)
+	return ok
+}
+
+//**f** A function taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Applies f to the elements of the lists in order, for its side effects.
+//Example: `(for-each display '(1 2 3))` prints 123
+func foreach(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndRows("for-each", args)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if v := callProc(e, f, r...); isError(v) {
+			return v
+		}
+	}
+	return Boolean(true)
+}
+
+//**n** The number of elements.
+//**start** (optional) The first element, 0 by default.
+//**step** (optional) The difference between elements, 1 by default.
+//Returns a list of n numbers counting from start.
+//Examples:
+//`(iota 5) => (0 1 2 3 4)`
+//`(iota 3 1 2) => (1 3 5)`
+func iota_(e Environment, args ...Expr) Expr {
+	n, err := countArg("iota", args, 0)
+	if err != nil {
+		return err
+	}
+	start, step := Number(0), Number(1)
+	for i, p := range []*Number{&start, &step} {
+		if len(args) > i+1 {
+			v, ok := args[i+1].(Number)
+			if !ok {
+				return Error{"iota: Argument " + strconv.Itoa(i+2) + " is not a number."}
+			}
+			*p = v
+		}
+	}
+	ret := make([]Expr, n)
+	for i := range ret {
+		ret[i] = start + Number(i)*step
+	}
+	return SliceToExprList(ret)
+}
+
+//**sep** The separator to insert between the lists.
+//**lis** Any number of lists to join together with the separator.
+//Variadic function which joins all its arguments with the separator inserted between them.
+//Example: `(join 'a '(1 2 3) '(4 5 6) '(7 8 9)) => (1 2 3 a 4 5 6 a 7 8 9)`
+func join(e Environment, args ...Expr) Expr {
+	ls, err := listsArgs("join", args, 1)
+	if err != nil {
+		return err
+	}
+	ret := []Expr{}
+	for i, l := range ls {
+		if i > 0 {
+			if sep, ok := args[0].(ExprList); ok {
+				if listLength(sep) < 0 {
+					return Error{"join: Argument 1 is a circular list."}
+				}
+				ret = append(ret, ExprListToSlice(sep)...)
+			} else {
+				ret = append(ret, args[0])
+			}
+		}
+		ret = append(ret, l...)
+	}
+	return SliceToExprList(ret)
+}
+
+//**li** A list to return the last element from.
+//Returns the last element of li.
+//Example: `(last '(1 2 3)) => 3`
+func last(e Environment, args ...Expr) Expr {
+	r := lastpair(e, args...)
+	if l, ok := r.(ExprList); ok {
+		return *l.car
+	}
+	return r
+}
+
+//**li** A non-empty list.
+//Returns the last cell of li, the list containing only its last element.
+//Example: `(last-pair '(1 2 3)) => (3)`
+func lastpair(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok || l.car == nil {
+		return Error{"last-pair: Argument 1 is not a non-empty list."}
+	}
+	cs, err := cellsArg("last-pair", args, 0)
+	if err != nil {
+		return err
+	}
+	return *cs[len(cs)-1]
+}
+
+//**x** A list to return the length of.
+//Returns the length of x. It is an error if x is circular.
+//Example: `(length '(1 2 3)) => 3`
+func length(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"length: Argument 1 is not a list."}
+	}
+	n := listLength(l)
+	if n < 0 {
+		return Error{"length: Argument 1 is a circular list."}
+	}
+	return Number(n)
+}
+
+//**x** A list.
+//Returns the length of x, or #f if x is circular.
+func lengthplus(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"length+: Argument 1 is not a list."}
+	}
+	if n := listLength(l); n >= 0 {
+		return Number(n)
+	}
+	return Boolean(false)
+}
+
+//**li** A list.
+//Returns a copy of li.
+func listcopy(e Environment, args ...Expr) Expr {
+	l, err := listArg("list-copy", args, 0)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(l)
+}
+
+//**pred** A predicate taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Returns the index of the first elements satisfying pred, or #f.
+//Example: `(list-index even? '(3 1 4 1 5)) => 2`
+func listindex(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndRows("list-index", args)
+	if err != nil {
+		return err
+	}
+	for i, r := range rs {
+		ok, err := callPred(e, pred, r...)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return Number(i)
+		}
+	}
+	return Boolean(false)
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Any number of lists.
+//Returns #t if the lists have the same length and their elements are pairwise
+//equivalent.
+//Example: `(list= eq? '(a b) '(a b)) => #t`
+func listeq(e Environment, args ...Expr) Expr {
+	eq, err := procArg("list=", args, 0)
+	if err != nil {
+		return err
+	}
+	ls, err := listsArgs("list=", args, 1)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(ls); i++ {
+		if len(ls[i]) != len(ls[i-1]) {
+			return Boolean(false)
+		}
+		for j := range ls[i] {
+			ok, err := callPred(e, eq, ls[i-1][j], ls[i][j])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return Boolean(false)
+			}
+		}
+	}
+	return Boolean(true)
+}
+
+//**li** List to get an element from.
+//**k** Index of the element to get (0-based).
+//Returns the *k*th element of li.
+//Example: `(list-ref '(1 2 3) 1) => 2`
+func listref(e Environment, args ...Expr) Expr {
+	r := listtail_("list-ref", args)
+	if l, ok := r.(ExprList); ok {
+		if l.car == nil {
+			return Error{"list-ref: Index " + Sprint(args[1]) + " is out of range."}
+		}
+		return *l.car
+	}
+	return r
+}
+
+//nth returns a procedure returning the element at index n of a list, for
+//first, second and so on.
+func nth(name string, n int) func(e Environment, args ...Expr) Expr {
+	return func(e Environment, args ...Expr) Expr {
+		l, ok := args[0].(ExprList)
+		if !ok {
+			return Error{name + ": Argument 1 is not a list."}
+		}
+		if m := listLength(l); m >= 0 && n >= m {
+			return Error{name + ": The list has fewer than " + strconv.Itoa(n+1) + " elements."}
+		}
+		return *dropCells(l, n).car
+	}
+}
+
+//**li** A list to get the tail from.
+//**k** The index to start the tail at (0-based).
+//Takes the remaining elements of li starting at position k.
+//Examples:
+//`(list-tail '(1 2 3 4) 0) => (1 2 3 4)`
+//`(list-tail '(1 2 3 4) 1) => (2 3 4)`
+//`(list-tail '(1 2 3 4) 4) => ()`
+func listtail(e Environment, args ...Expr) Expr {
+	return listtail_("list-tail", args)
+}
+
+func listtail_(name string, args []Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{name + ": Argument 1 is not a list."}
+	}
+	n := listLength(l)
+	if n < 0 {
+		n = math.MaxInt
+	}
+	k, err := indexArg(name, args, 1, n)
+	if err != nil {
+		return err
+	}
+	return dropCells(l, k)
+}
+
+//dropCells returns the tail of l after its first k cells. l may be circular
+//but must have at least k elements.
+func dropCells(l ExprList, k int) ExprList {
+	it := &l
+	for i := 0; i < k && it != nil; i++ {
+		it = it.cdr
+	}
+	if it == nil {
+		return ExprList{}
+	}
+	return *it
+}
+
+//**n** The length of the list.
+//**f** A function called with every index.
+//Returns the list of the results of calling f with 0, 1 and so on up to n - 1.
+//Example: `(list-tabulate 3 (lambda (i) (* i i))) => (0 1 4)`
+func listtabulate(e Environment, args ...Expr) Expr {
+	n, err := countArg("list-tabulate", args, 0)
+	if err != nil {
+		return err
+	}
+	f, err := procArg("list-tabulate", args, 1)
+	if err != nil {
+		return err
+	}
+	ret := make([]Expr, n)
+	for i := range ret {
+		if ret[i] = callProc(e, f, Number(i)); isError(ret[i]) {
+			return ret[i]
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//lsetArgs reads the equivalence and the lists passed to an lset procedure.
+func lsetArgs(e Environment, name string, args []Expr) (func(a, b Expr) (bool, Expr), func(x Expr) (uint64, Expr), [][]Expr, Expr) {
+	equiv, hash, err := equivArg(e, name, args, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ls, err := listsArgs(name, args, 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return equiv, hash, ls, nil
+}
+
+//**=** An equivalence.
+//**li** A list used as a set.
+//**elts** (variadic) Elements to add to li.
+//Adds the elements that are not already in li to the front of it.
+//Example: `(lset-adjoin eq? '(a b) 'c 'a) => (c a b)`
+func lsetadjoin(e Environment, args ...Expr) Expr {
+	equiv, hash, err := equivArg(e, "lset-adjoin", args, 0)
+	if err != nil {
+		return err
+	}
+	l, ok := args[1].(ExprList)
+	if !ok {
+		return Error{"lset-adjoin: Argument 2 is not a list."}
+	}
+	if listLength(l) < 0 {
+		return Error{"lset-adjoin: Argument 2 is a circular list."}
+	}
+	s, err := setOf(equiv, hash, ExprListToSlice(l))
+	if err != nil {
+		return err
+	}
+	for _, x := range args[2:] {
+		added, err := s.add(x)
+		if err != nil {
+			return err
+		}
+		if added {
+			x, t := x, l
+			l = ExprList{&x, &t}
+		}
+	}
+	return l
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns the elements of the first list which are not in any of the others.
+//Example: `(lset-difference eq? '(a b c) '(b) '(c d)) => (a)`
+func lsetdifference(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset-difference", args)
+	if err != nil {
+		return err
+	}
+	if len(ls) == 0 {
+		return Error{"lset-difference: Expected at least one list."}
+	}
+	s, err := setOf(equiv, hash, concat(ls[1:]))
+	if err != nil {
+		return err
+	}
+	ret, err := keep(ls[0], false, s.contains)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns a list of two lists, the elements of the first list which are not in
+//any of the others and the ones which are in at least one of them.
+//lset-diff+intersection! is the same function.
+//Example: `(lset-diff+intersection eq? '(a b c d) '(b) '(d e)) => ((a c) (b d))`
+func lsetdiffintersection(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset-diff+intersection", args)
+	if err != nil {
+		return err
+	}
+	if len(ls) == 0 {
+		return Error{"lset-diff+intersection: Expected at least one list."}
+	}
+	s, err := setOf(equiv, hash, concat(ls[1:]))
+	if err != nil {
+		return err
+	}
+	diff, err := keep(ls[0], false, s.contains)
+	if err != nil {
+		return err
+	}
+	in, err := keep(ls[0], true, s.contains)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList([]Expr{SliceToExprList(diff), SliceToExprList(in)})
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns the elements of the first list which are in all of the others.
+//Example: `(lset-intersection eq? '(a b c) '(b c d) '(c b)) => (b c)`
+func lsetintersection(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset-intersection", args)
+	if err != nil {
+		return err
+	}
+	if len(ls) == 0 {
+		return Error{"lset-intersection: Expected at least one list."}
+	}
+	ret := ls[0]
+	for _, l := range ls[1:] {
+		s, err := setOf(equiv, hash, l)
+		if err != nil {
+			return err
+		}
+		if ret, err = keep(ret, true, s.contains); err != nil {
+			return err
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns #t if every list is a subset of the one after it.
+//Example: `(lset<= eq? '(a) '(a b a) '(a b c c)) => #t`
+func lsetle(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset<=", args)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(ls); i++ {
+		ok, err := subset(equiv, hash, ls[i-1], ls[i])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns #t if all lists contain the same elements.
+//Example: `(lset= eq? '(b a) '(a b a)) => #t`
+func lseteq(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset=", args)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(ls); i++ {
+		ok, err := subset(equiv, hash, ls[i-1], ls[i])
+		if err != nil {
+			return err
+		}
+		if ok {
+			ok, err = subset(equiv, hash, ls[i], ls[i-1])
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+//subset reports whether every element of a is in b.
+func subset(equiv func(a, b Expr) (bool, Expr), hash func(x Expr) (uint64, Expr), a, b []Expr) (bool, Expr) {
+	s, err := setOf(equiv, hash, b)
+	if err != nil {
+		return false, err
+	}
+	missing, err := keep(a, false, s.contains)
+	return len(missing) == 0, err
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns the first list with the elements of the others that are not already
+//in it added to the front.
+//Example: `(lset-union eq? '(a b) '(b c) '(d)) => (d c a b)`
+func lsetunion(e Environment, args ...Expr) Expr {
+	if len(args) == 1 {
+		return ExprList{}
+	}
+	l, ok := args[1].(ExprList)
+	if !ok {
+		return Error{"lset-union: Argument 2 is not a list."}
+	}
+	ls, err := listsArgs("lset-union", args, 2)
+	if err != nil {
+		return err
+	}
+	return lsetadjoin(e, append([]Expr{args[0], l}, concat(ls)...)...)
+}
+
+//**=** An equivalence.
+//**lis** (variadic) Lists used as sets.
+//Returns the elements which are in an odd number of the lists.
+//Example: `(lset-xor eq? '(a b c) '(b c d)) => (d a)`
+func lsetxor(e Environment, args ...Expr) Expr {
+	equiv, hash, ls, err := lsetArgs(e, "lset-xor", args)
+	if err != nil {
+		return err
+	}
+	ret := []Expr{}
+	for _, l := range ls {
+		a, err := setOf(equiv, hash, ret)
+		if err != nil {
+			return err
+		}
+		b, err := setOf(equiv, hash, l)
+		if err != nil {
+			return err
+		}
+		onlyB, err := keep(l, false, a.contains)
+		if err != nil {
+			return err
+		}
+		onlyA, err := keep(ret, false, b.contains)
+		if err != nil {
+			return err
+		}
+		ret = append(onlyB, onlyA...)
+	}
+	return SliceToExprList(ret)
+}
+
+func concat(ls [][]Expr) []Expr {
+	ret := []Expr{}
+	for _, l := range ls {
+		ret = append(ret, l...)
+	}
+	return ret
+}
+
+//**n** The length of the list.
+//**fill** (optional) The element to fill the list with.
+//Returns a list of n elements.
+//Example: `(make-list 3 'a) => (a a a)`
+func makelist(e Environment, args ...Expr) Expr {
+	n, err := countArg("make-list", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := make([]Expr, n)
+	if len(args) == 2 {
+		for i := range ret {
+			ret[i] = args[1]
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//**f** A function to apply to the lists. The arity of the function must be equal to the number of lists.
+//**lis** (variadic) any number of lists to apply the function to.
+//Returns a list of the same length as the shortest argument containing the result of applying f to each element of the lists.
+//The lists are mapped over in order, so map is also map-in-order.
+//Examples:
+//`(map number? '(1 a #t)) => (#t #f #f)`
+//`(map + '(1 2 3) '(4 5 6 7) '(1 1)) => (6 8)`
+func map_(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndRows("map", args)
+	if err != nil {
+		return err
+	}
+	ret := make([]Expr, len(rs))
+	for i, r := range rs {
+		if ret[i] = callProc(e, f, r...); isError(ret[i]) {
+			return ret[i]
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//member, memq and memv return the first tail of li whose first element is
+//equivalent to obj.
+func member_(name string, equiv func(a, b Expr) (bool, Expr), obj, li Expr) Expr {
+	l, ok := li.(ExprList)
+	if !ok {
+		return Error{name + ": Argument 2 is not a list."}
+	}
+	var found *ExprList
+	_, err := walk(l, func(c *ExprList) (bool, Expr) {
+		eq, err := equiv(obj, *c.car)
+		if eq {
+			found = c
+		}
+		return !eq, err
+	})
+	if err != nil {
+		return err
+	}
+	if found != nil {
+		return *found
+	}
+	return Boolean(false)
+}
+
+//**obj** Object to search for in li.
+//**li** List to search for obj.
+//**=** (optional) The equivalence used to compare elements, equal? by default.
+//Searches for an object equal to obj in list li. If it is found, the object and the remainder of the list after it is returned. If the object is not found, returns #f.
+//Examples:
+//`(member 2 '(1 2 3 4)) => (2 3 4)`
+//`(member 5 '(1 2 3 4)) => #f`
+func member(e Environment, args ...Expr) Expr {
+	equiv, _, err := equivArg(e, "member", args, 2)
+	if err != nil {
+		return err
+	}
+	return member_("member", equiv, args[0], args[1])
+}
+
+//**obj** Object to search for in li.
+//**li** List to search for obj.
+//See member. This function uses eq? instead of equal?.
+//Example: `(memq 'a '(3 4 a b)) => (a b)`
+func memq(e Environment, args ...Expr) Expr {
+	return member_("memq", func(a, b Expr) (bool, Expr) { return isEqv(a, b), nil }, args[0], args[1])
+}
+
+//**obj** Object to search for in li.
+//**li** List to search for obj.
+//See member. This function uses eqv? instead of equal?.
+func memv(e Environment, args ...Expr) Expr {
+	return member_("memv", func(a, b Expr) (bool, Expr) { return isEqv(a, b), nil }, args[0], args[1])
+}
+
+//**x** An object to check.
+//Returns #t if x is not a pair, that is if it is the empty list or not a list.
+//Examples:
+//`(not-pair? '()) => #t`
+//`(not-pair? '(1)) => #f`
+func notpair_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	return Boolean(!ok || l.car == nil)
+}
+
+//**x** An object to check.
+//Returns #t if x is an empty list, otherwise returns #f.
+//Examples:
+//`(null? '()) => #t`
+//`(null? 1) => #f`
+func null_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	return Boolean(ok && l.car == nil)
+}
+
+//**x** A list to check.
+//Like null?, but it is an error if x is not a list.
+func nulllist_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"null-list?: Argument 1 is not a list."}
+	}
+	return Boolean(l.car == nil)
+}
+
+//**kons** Function by which to fold the lists. It is called with a tail of
+//every list followed by the accumulator.
+//**knil** The accumulator's starting value.
+//**lis** (variadic) One or more lists to fold.
+//Like fold, but kons is called with the tails of the lists instead of their
+//elements.
+//Example: `(pair-fold cons '() '(a b c)) => ((c) (b c) (a b c))`
+func pairfold(e Environment, args ...Expr) Expr {
+	kons, rs, err := pairFoldArgs("pair-fold", args)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for _, r := range rs {
+		if acc = callProc(e, kons, append(r, acc)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+//**kons** Function by which to fold the lists. It is called with a tail of
+//every list followed by the accumulator.
+//**knil** The accumulator's starting value.
+//**lis** (variadic) One or more lists to fold.
+//Like fold-right, but kons is called with the tails of the lists instead of
+//their elements.
+//Example: `(pair-fold-right cons '() '(a b c)) => ((a b c) (b c) (c))`
+func pairfoldright(e Environment, args ...Expr) Expr {
+	kons, rs, err := pairFoldArgs("pair-fold-right", args)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for i := len(rs) - 1; i >= 0; i-- {
+		if acc = callProc(e, kons, append(rs[i], acc)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+func pairFoldArgs(name string, args []Expr) (Proc, [][]Expr, Expr) {
+	kons, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts, err := tailsArgs(name, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kons, rows(ts), nil
+}
+
+//**f** A function taking as many arguments as there are lists.
+//**lis** (variadic) One or more lists.
+//Like for-each, but f is called with the tails of the lists instead of their
+//elements.
+//Example: `(pair-for-each display '(1 2 3))` prints (1 2 3)(2 3)(3)
+func pairforeach(e Environment, args ...Expr) Expr {
+	f, err := procArg("pair-for-each", args, 0)
+	if err != nil {
+		return err
+	}
+	ts, err := tailsArgs("pair-for-each", args, 1)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows(ts) {
+		if v := callProc(e, f, r...); isError(v) {
+			return v
+		}
+	}
+	return Boolean(true)
+}
+
+//tailsArgs is like clistsArgs, but returns the non-empty tails of the lists,
+//starting with the lists themselves, instead of their elements.
+func tailsArgs(name string, args []Expr, from int) ([][]Expr, Expr) {
+	n, err := shortestArg(name, args, from)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([][]Expr, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		ts := make([]Expr, n)
+		it := args[i].(ExprList)
+		for j := range ts {
+			ts[j] = it
+			it = dropCells(it, 1)
+		}
+		ret = append(ret, ts)
+	}
+	return ret, nil
+}
+
+//**pred** A predicate.
+//**li** A list to partition.
+//Returns a list of two lists, the elements of li which satisfy pred and the
+//ones which do not.
+//Example: `(partition symbol? '(one 2 3 four)) => ((one four) (2 3))`
+func partition(e Environment, args ...Expr) Expr {
+	pred, err := procArg("partition", args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg("partition", args, 1)
+	if err != nil {
+		return err
+	}
+	in, out := []Expr{}, []Expr{}
+	for _, x := range l {
+		ok, err := callPred(e, pred, x)
+		if err != nil {
+			return err
+		}
+		if ok {
+			in = append(in, x)
+		} else {
+			out = append(out, x)
+		}
+	}
+	return SliceToExprList([]Expr{SliceToExprList(in), SliceToExprList(out)})
+}
+
+//**x** An object to check.
+//Returns #t if x is a list which is not circular.
+func properlist_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	return Boolean(ok && listLength(l) >= 0)
+}
+
+//**x** An object to check.
+//Returns #t if x is a circular list.
+func circularlist_(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	return Boolean(ok && listLength(l) < 0)
+}
+
+//**f** A function taking an element and the accumulator.
+//**ridentity** The value returned if li is empty.
+//**li** A list to reduce.
+//Like fold, but the first element of li is the starting value of the
+//accumulator.
+//Example: `(reduce max 0 '(3 9 2)) => 9`
+func reduce(e Environment, args ...Expr) Expr {
+	f, l, err := reduceArgs("reduce", args)
+	if err != nil {
+		return err
+	}
+	if len(l) == 0 {
+		return args[1]
+	}
+	acc := l[0]
+	for _, x := range l[1:] {
+		if acc = callProc(e, f, x, acc); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+//**f** A function taking an element and the accumulator.
+//**ridentity** The value returned if li is empty.
+//**li** A list to reduce.
+//Like fold-right, but the last element of li is the starting value of the
+//accumulator.
+//Example: `(reduce-right append '() '((1 2) (3) (4 5))) => (1 2 3 4 5)`
+func reduceright(e Environment, args ...Expr) Expr {
+	f, l, err := reduceArgs("reduce-right", args)
+	if err != nil {
+		return err
+	}
+	if len(l) == 0 {
+		return args[1]
+	}
+	acc := l[len(l)-1]
+	for i := len(l) - 2; i >= 0; i-- {
+		if acc = callProc(e, f, l[i], acc); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+func reduceArgs(name string, args []Expr) (Proc, []Expr, Expr) {
+	f, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := listArg(name, args, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, l, nil
+}
+
+//**pred** A predicate. Any element of li which satisfies it will be removed from the list.
+//**li** The list from which the elements should be removed.
+//Returns li with all elements which satisfy pred removed.
+//Example: `(remove number? '(a 1 2 b c 3 4 d)) => (a b c d)`
+func remove(e Environment, args ...Expr) Expr {
+	return filter_(e, "remove", false, args)
+}
+
+//**li** A list which will be reversed.
+//Returns the list with its elements in reverse order.
+//Example: `(reverse '(1 2 3)) => (3 2 1)`
+func reverse(e Environment, args ...Expr) Expr {
+	if _, err := listArg("reverse", args, 0); err != nil {
+		return err
+	}
+	return appendreverse(e, args[0], ExprList{})
+}
+
+//**pred** A predicate against which to check the elements of li.
+//**li** A list to check.
+//Returns #t if any element of li satisfies pred, #f otherwise.
+//Examples:
+//`(some? number? '(a b c)) => #f`
+//`(some? number? '(a b 1)) => #t`
+func some_(e Environment, args ...Expr) Expr {
+	r := findtail(e, args...)
+	if isError(r) {
+		return r
+	}
+	return Boolean(truthy(r))
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Splits li before the first element which does not satisfy pred, returning a
+//list of the two parts.
+//Example: `(span even? '(2 4 5 6)) => ((2 4) (5 6))`
+func span(e Environment, args ...Expr) Expr {
+	return span_(e, "span", true, args)
+}
+
+//span_ splits the list in args[1] before the first element for which the
+//predicate in args[0] does not return want.
+func span_(e Environment, name string, want bool, args []Expr) Expr {
+	pred, err := procArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	l, ok := args[1].(ExprList)
+	if !ok {
+		return Error{name + ": Argument 2 is not a list."}
+	}
+	head, tail := []Expr{}, ExprList{}
+	around, err := walk(l, func(c *ExprList) (bool, Expr) {
+		ok, err := callPred(e, pred, *c.car)
+		if ok != want {
+			tail = *c
+			return false, err
+		}
+		head = append(head, *c.car)
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+	if around {
+		return Error{name + ": Argument 2 is a circular list which never ends the span."}
+	}
+	return SliceToExprList([]Expr{SliceToExprList(head), tail})
+}
+
+//**pred** The predicate to split the list on.
+//**li** The list to split.
+//Splits a list on any member satisfying pred.
+//Returns a list of sublists that are contained between those members.
+//Example: `(split symbol? '(1 2 3 a 4 5 6 a 7 8 9)) => ((1 2 3) (4 5 6) (7 8 9))`
+func split(e Environment, args ...Expr) Expr {
+	pred, err := procArg("split", args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg("split", args, 1)
+	if err != nil {
+		return err
+	}
+	ret, part := []Expr{}, []Expr{}
+	for _, x := range l {
+		ok, err := callPred(e, pred, x)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ret, part = append(ret, SliceToExprList(part)), []Expr{}
+		} else {
+			part = append(part, x)
+		}
+	}
+	if len(part) > 0 {
+		ret = append(ret, SliceToExprList(part))
+	}
+	return SliceToExprList(ret)
+}
+
+//**li** A list.
+//**k** The index to split li at.
+//Returns a list of the first k elements of li and the rest of it.
+//Example: `(split-at '(a b c d) 1) => ((a) (b c d))`
+func splitat(e Environment, args ...Expr) Expr {
+	r := listtail_("split-at", args)
+	if isError(r) {
+		return r
+	}
+	head := prefix(args[0].(ExprList), int(args[1].(Number)))
+	return SliceToExprList([]Expr{SliceToExprList(head), r})
+}
+
+//**li** A list.
+//**k** The number of elements to take from li.
+//Returns the first k elements of li.
+//Examples:
+//`(take '(1 2 3) 0) => ()`
+//`(take '(1 2 3) 2) => (1 2)`
+//`(take '(1 2 3) 4) => Error`
+func take(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"take: Argument 1 is not a list."}
+	}
+	k, err := countArg("take", args, 1)
+	if err != nil {
+		return err
+	}
+	if n := listLength(l); n >= 0 && k > n {
+		return Error{"take: Attempt to take more than length of list."}
+	}
+	return SliceToExprList(prefix(l, k))
+}
+
+//**li** A list.
+//**k** The number of elements to take from the end of li.
+//Returns the last k elements of li.
+//Example: `(take-right '(1 2 3 4) 2) => (3 4)`
+func takeright(e Environment, args ...Expr) Expr {
+	cs, err := cellsArg("take-right", args, 0)
+	if err != nil {
+		return err
+	}
+	k, err := indexArg("take-right", args, 1, len(cs))
+	if err != nil {
+		return err
+	}
+	if k == 0 {
+		return ExprList{}
+	}
+	return *cs[len(cs)-k]
+}
+
+//**pred** A predicate.
+//**li** A list.
+//Returns the elements at the start of li which satisfy pred.
+//Example: `(take-while even? '(2 4 5 6)) => (2 4)`
+func takewhile(e Environment, args ...Expr) Expr {
+	r := span_(e, "take-while", true, args)
+	if l, ok := r.(ExprList); ok {
+		return *l.car
+	}
+	return r
+}
+
+//**stop** A predicate telling when to stop, called with the seed.
+//**mapper** A function returning the element for a seed.
+//**successor** A function returning the next seed.
+//**seed** The first seed.
+//**tail-gen** (optional) A function returning the tail of the list for the
+//last seed, () by default.
+//Builds a list from a seed.
+//Example: `(unfold (lambda (x) (> x 5)) (lambda (x) (* x x)) (lambda (x) (+ x 1)) 1) => (1 4 9 16 25)`
+func unfold(e Environment, args ...Expr) Expr {
+	ps, err := procArgs("unfold", args, 0, 3)
+	if err != nil {
+		return err
+	}
+	ret := []Expr{}
+	seed := args[3]
+	for {
+		stop, err := callPred(e, ps[0], seed)
+		if err != nil {
+			return err
+		}
+		if stop {
+			break
+		}
+		x := callProc(e, ps[1], seed)
+		if isError(x) {
+			return x
+		}
+		ret = append(ret, x)
+		if seed = callProc(e, ps[2], seed); isError(seed) {
+			return seed
+		}
+	}
+	var tail Expr = ExprList{}
+	if len(args) > 4 {
+		gen, err := procArg("unfold", args, 4)
+		if err != nil {
+			return err
+		}
+		if tail = callProc(e, gen, seed); isError(tail) {
+			return tail
+		}
+	}
+	return append_(e, SliceToExprList(ret), tail)
+}
+
+//**stop** A predicate telling when to stop, called with the seed.
+//**mapper** A function returning the element for a seed.
+//**successor** A function returning the next seed.
+//**seed** The first seed.
+//**tail** (optional) The list to build on, () by default.
+//Builds a list from a seed, from right to left.
+//Example: `(unfold-right zero? (lambda (x) (* x x)) (lambda (x) (- x 1)) 5) => (1 4 9 16 25)`
+func unfoldright(e Environment, args ...Expr) Expr {
+	ps, err := procArgs("unfold-right", args, 0, 3)
+	if err != nil {
+		return err
+	}
+	tail := ExprList{}
+	if len(args) > 4 {
+		var ok bool
+		if tail, ok = args[4].(ExprList); !ok {
+			return Error{"unfold-right: Argument 5 is not a list."}
+		}
+	}
+	seed := args[3]
+	for {
+		stop, err := callPred(e, ps[0], seed)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return tail
+		}
+		x := callProc(e, ps[1], seed)
+		if isError(x) {
+			return x
+		}
+		t := tail
+		tail = ExprList{&x, &t}
+		if seed = callProc(e, ps[2], seed); isError(seed) {
+			return seed
+		}
+	}
+}
+
+//**li** A list of lists.
+//Returns the list of the first elements of the lists in li.
+//Example: `(unzip1 '((1 a) (2 b))) => (1 2)`
+func unzip1(e Environment, args ...Expr) Expr {
+	ls, err := unzip_("unzip1", 1, args)
+	if err != nil {
+		return err
+	}
+	return ls[0]
+}
+
+//**li** A list of lists.
+//Returns a list of two lists, the first and the second elements of the lists
+//in li.
+//Example: `(unzip2 '((1 a) (2 b))) => ((1 2) (a b))`
+func unzip2(e Environment, args ...Expr) Expr {
+	return unzipn("unzip2", 2, args)
+}
+
+//**li** A list of lists.
+//Returns a list of three lists, the first, second and third elements of the
+//lists in li.
+//Example: `(unzip3 '((1 a x) (2 b y))) => ((1 2) (a b) (x y))`
+func unzip3(e Environment, args ...Expr) Expr {
+	return unzipn("unzip3", 3, args)
+}
+
+//**li** A list of lists.
+//Returns a list of four lists, the first four elements of the lists in li.
+//Example: `(unzip4 '((1 a x #t) (2 b y #f))) => ((1 2) (a b) (x y) (#t #f))`
+func unzip4(e Environment, args ...Expr) Expr {
+	return unzipn("unzip4", 4, args)
+}
+
+//**li** A list of lists.
+//Returns a list of five lists, the first five elements of the lists in li.
+//Example: `(unzip5 '((1 2 3 4 5) (6 7 8 9 10))) => ((1 6) (2 7) (3 8) (4 9) (5 10))`
+func unzip5(e Environment, args ...Expr) Expr {
+	return unzipn("unzip5", 5, args)
+}
+
+func unzipn(name string, n int, args []Expr) Expr {
+	ls, err := unzip_(name, n, args)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ls)
+}
+
+//unzip_ returns the lists of the first n elements of every list in args[0].
+func unzip_(name string, n int, args []Expr) ([]Expr, Expr) {
+	l, err := listArg(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([][]Expr, n)
+	for _, x := range l {
+		xl, ok := x.(ExprList)
+		if !ok {
+			return nil, Error{name + ": Every element of the list must be a list."}
+		}
+		if m := listLength(xl); m >= 0 && m < n {
+			return nil, Error{name + ": Every element of the list must have at least " + strconv.Itoa(n) + " elements."}
+		}
+		xs := prefix(xl, n)
+		for i := range cols {
+			cols[i] = append(cols[i], xs[i])
+		}
+	}
+	ret := make([]Expr, n)
+	for i, c := range cols {
+		ret[i] = SliceToExprList(c)
+	}
+	return ret, nil
+}
+
+//**a** The element to put in the cdr.
+//**b** The element to put in the car.
+//Returns (cons b a).
+func xcons(e Environment, args ...Expr) Expr {
+	return cons(e, args[1], args[0])
+}
+
+//**lis** This is a variadic function that takes any number of lists.
+//Returns the result of zipping the given lists.
+//Examples:
+//`(zip '(1 2 3) '(a b c)) => ((1 a) (2 b) (3 c))`
+//`(zip '(1 a one) '(2 b two) '(3 c three)) => ((1 2 3) (a b c) (one two three))`
+func zip(e Environment, args ...Expr) Expr {
+	ls, err := clistsArgs("zip", args, 0)
+	if err != nil {
+		return err
+	}
+	rs := rows(ls)
+	ret := make([]Expr, len(rs))
+	for i, r := range rs {
+		ret[i] = SliceToExprList(r)
+	}
+	return SliceToExprList(ret)
+}
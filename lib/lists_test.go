@@ -0,0 +1,99 @@
+package goscheme
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPairProcedures(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(car+cdr '(1 2 3))`, "(1 (2 3))"},
+		{`(car+cdr '())`, "car+cdr: Argument 1 is not a pair."},
+		{`(circular-list 1 2)`, "#0=(1 2 . #0#)"},
+		{`(circular-list? (circular-list 1))`, "#t"},
+		{`(dotted-list? (cons 1 2))`, "#t"},
+		{`(dotted-list? 'a)`, "#t"},
+		{`(dotted-list? '(1 2))`, "#f"},
+		{`(dotted-list? '())`, "#f"},
+		{`(dotted-list? (circular-list 1 2))`, "#f"},
+		{`(reverse (cons 1 2))`, "(2 1)"},
+		{`(not-pair? '())`, "#t"},
+		{`(not-pair? 1)`, "#t"},
+		{`(not-pair? '(1))`, "#f"},
+		{`(pair-fold cons '() '(a b c))`, "((c) (b c) (a b c))"},
+		{`(pair-fold (lambda (a b acc) (cons (append a b) acc)) '() '(1 2) '(3 4 5))`, "((2 4 5) (1 2 3 4 5))"},
+		{`(pair-fold-right cons '() '(a b c))`, "((a b c) (b c) (c))"},
+		{`(define tails '()) (pair-for-each (lambda (l) (set! tails (cons l tails))) '(1 2)) tails`, "((2) (1 2))"},
+		{`(unzip1 '((1 a) (2 b)))`, "(1 2)"},
+		{`(unzip2 '((1 a) (2 b)))`, "((1 2) (a b))"},
+		{`(unzip3 '((1 a x) (2 b y)))`, "((1 2) (a b) (x y))"},
+		{`(unzip4 '((1 a x #t)))`, "((1) (a) (x) (#t))"},
+		{`(unzip5 '())`, "(() () () () ())"},
+		{`(unzip2 '((1)))`, "unzip2: Every element of the list must have at least 2 elements."},
+		{`(lset-diff+intersection eq? '(a b c d) '(b) '(d e))`, "((a c) (b d))"},
+		{`(lset-diff+intersection! eq? '(a b))`, "((a b) ())"},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//docs/lists.md is generated from the doc comments in lists.go by
+//examples/docgen.scm.
+func TestListDocs(t *testing.T) {
+	testEnv(t)
+	want, err := os.ReadFile("docs/lists.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := evalString(t, `(load "examples/docgen.scm") (docgen-go "lib/lists.go" "lib/standard_env.go")`)
+	if s, ok := got.(String); !ok || unwrapString(s) != string(want) {
+		t.Errorf("docs/lists.md is not what docgen-go generates from lib/lists.go:\n%s", Sprint(got))
+	}
+}
+
+//Circular lists are accepted where one of the lists is finite or the
+//procedure stops before the end, and are an error elsewhere.
+func TestCircularLists(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(define c (circular-list 1 2)) (take c 3)`, "(1 2 1)"},
+		{`(drop c 5)`, "#0=(2 1 . #0#)"},
+		{`(list-ref c 7)`, "2"},
+		{`(third c)`, "1"},
+		{`(split-at c 3)`, "((1 2 1) #0=(2 1 . #0#))"},
+		{`(map + (list 1 2) (circular-list 10))`, "(11 12)"},
+		{`(for-each + '(1) c)`, "#t"},
+		{`(fold + 0 '(1 2 3) c)`, "10"},
+		{`(zip '(a b c) c)`, "((a 1) (b 2) (c 1))"},
+		{`(any > '(1 1 3) c)`, "#t"},
+		{`(every < '(0 1) c)`, "#t"},
+		{`(list-index = '(2 2) c)`, "1"},
+		{`(pair-fold (lambda (a b acc) (+ acc 1)) 0 '(1 2 3) c)`, "3"},
+		{`(unzip2 (list c c))`, "((1 1) (2 2))"},
+		{`(memq 2 c)`, "#0=(2 1 . #0#)"},
+		{`(memq 3 c)`, "#f"},
+		{`(find even? c)`, "2"},
+		{`(find-tail zero? c)`, "#f"},
+		{`(take-while odd? c)`, "(1)"},
+		{`(take-while number? c)`, "take-while: Argument 2 is a circular list which never ends the span."},
+		{`(map + c)`, "map: At least one of the lists must be finite."},
+		{`(any even? c c)`, "any: At least one of the lists must be finite."},
+		{`(length c)`, "length: Argument 1 is a circular list."},
+		{`(list-copy c)`, "list-copy: Argument 1 is a circular list."},
+		{`(reverse c)`, "reverse: Argument 1 is a circular list."},
+		{`(append c '(1))`, "append: Argument 1 is a circular list."},
+		{`(last-pair c)`, "last-pair: Argument 1 is a circular list."},
+		{`(list-sort < c)`, "list-sort: Argument 2 is a circular list."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package goscheme
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Loading files
+load, include and require find a file by trying, in order, the directory of
+the file being loaded, the working directory and each directory of
+load-path. ".scm" is added to names that do not already
+end with it. An absolute name is only tried as it is.
+load-path and library-path start out with the directories in the
+GOSCHEME_PATH environment variable, and the interpreter's -L flag adds to
+them. load prints the name of each file it reads and the value of each
+expression in it, unless load-verbose is #f, which the -q flag sets. Loading
+stops at the first error, which load returns.
+(provide feature) records that a feature has been loaded, and (require
+feature [file]) loads the file, which defaults to the name of the feature,
+unless the feature has already been provided. The file must provide the
+feature.
+*/
+
+//loadDirs holds the directories of the files being loaded, innermost last,
+//so that files can be found relative to the file that loads them.
+var loadDirs struct {
+	sync.Mutex
+	dirs []string
+}
+
+//provided holds the features given to provide.
+var provided = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+//environmentPath returns the directories in GOSCHEME_PATH.
+func environmentPath() []Expr {
+	var dirs []Expr
+	for _, d := range filepath.SplitList(os.Getenv("GOSCHEME_PATH")) {
+		if d != "" {
+			dirs = append(dirs, NewString(d))
+		}
+	}
+	return dirs
+}
+
+//AddLoadPath adds dirs to the front of load-path and library-path in
+//GlobalEnv.
+func AddLoadPath(dirs ...string) {
+	var l []Expr
+	for _, d := range dirs {
+		l = append(l, NewString(d))
+	}
+	for _, name := range []string{"load-path", "library-path"} {
+		if scope := GlobalEnv.find(name); scope != nil {
+			old, _ := scope[name].(ExprList)
+			scope[name] = SliceToExprList(append(append([]Expr{}, l...), ExprListToSlice(old)...))
+		}
+	}
+}
+
+//pathVariable returns the strings in the list bound to name in e.
+func pathVariable(e Environment, name string) []string {
+	scope := e.find(name)
+	if scope == nil {
+		return nil
+	}
+	l, _ := scope[name].(ExprList)
+	var dirs []string
+	for _, d := range ExprListToSlice(l) {
+		if s, ok := d.(String); ok {
+			dirs = append(dirs, unwrapString(s))
+		}
+	}
+	return dirs
+}
+
+//callerDir returns the directory of the file being loaded, or "." outside of
+//any file.
+func callerDir() string {
+	loadDirs.Lock()
+	defer loadDirs.Unlock()
+	if len(loadDirs.dirs) == 0 {
+		return "."
+	}
+	return loadDirs.dirs[len(loadDirs.dirs)-1]
+}
+
+//findFile looks for the file called name as described above. If it is not
+//found, the paths that were tried are returned instead.
+func findFile(name string, e Environment) (string, []string) {
+	names := []string{name}
+	if !strings.HasSuffix(name, ".scm") {
+		names = append(names, name+".scm")
+	}
+	dirs := []string{""}
+	if !filepath.IsAbs(name) {
+		dirs = append([]string{callerDir(), "."}, pathVariable(e, "load-path")...)
+	}
+	var tried []string
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		for _, n := range names {
+			path := filepath.Join(dir, n)
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				return path, nil
+			}
+			tried = append(tried, path)
+		}
+	}
+	return "", tried
+}
+
+//fileArg returns the file named by args[i], which is a string or a symbol,
+//as found by findFile.
+func fileArg(e Environment, name string, args []Expr, i int) (string, Expr) {
+	var s string
+	switch v := args[i].(type) {
+	case String:
+		s = unwrapString(v)
+	case Symbol:
+		s = v.name
+	default:
+		return "", Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a string."}
+	}
+	path, tried := findFile(s, e)
+	if path == "" {
+		return "", Error{name + ": " + s + " not found, tried " + strings.Join(tried, ", ") + "."}
+	}
+	return path, nil
+}
+
+//verbose returns the value of load-verbose in e.
+func verbose(e Environment) bool {
+	if scope := e.find("load-verbose"); scope != nil {
+		if b, ok := scope["load-verbose"].(Boolean); ok {
+			return bool(b)
+		}
+	}
+	return true
+}
+
+//evalFile evaluates the file at path in e, with its own scope for
+//current-readtable.
+func evalFile(path string, e Environment) Expr {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Error{err.Error()}
+	}
+	v := verbose(e)
+	if v {
+		Println("Reading file " + path + "...")
+	}
+	loadDirs.Lock()
+	loadDirs.dirs = append(loadDirs.dirs, filepath.Dir(path))
+	loadDirs.Unlock()
+	defer func() {
+		loadDirs.Lock()
+		loadDirs.dirs = loadDirs.dirs[:len(loadDirs.dirs)-1]
+		loadDirs.Unlock()
+	}()
+	p := newInputPort(bytes.NewReader(in), nopCloser{})
+	return withReadtableScope(e, func() Expr {
+		return evalReader(p, e, v)
+	})
+}
+
+//includeFiles reads every datum in the files named by names. ci is true for
+//include-ci, which reads the files as if they started with #!fold-case.
+func includeFiles(name string, names []Expr, e Environment, ci bool) ([]Expr, Expr) {
+	var forms []Expr
+	for i := range names {
+		if _, ok := names[i].(String); !ok {
+			return nil, Error{name + ": " + Sprint(names[i]) + " is not a file name."}
+		}
+		path, err := fileArg(e, name, names, i)
+		if err != nil {
+			return nil, err
+		}
+		in, rerr := ioutil.ReadFile(path)
+		if rerr != nil {
+			return nil, Error{name + ": " + rerr.Error()}
+		}
+		p := newInputPort(bytes.NewReader(in), nopCloser{})
+		p.foldCase = ci
+		for {
+			d, err := readDatum(p, e)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err.(Error)
+			}
+			forms = append(forms, d)
+		}
+	}
+	return forms, nil
+}
+
+//(load file) evaluates the file in the global environment.
+func load(e Environment, args ...Expr) Expr {
+	path, err := fileArg(e, "load", args, 0)
+	if err != nil {
+		return err
+	}
+	return evalFile(path, GlobalEnv)
+}
+
+//featureName returns the name of the feature x, a symbol or a string.
+func featureName(name string, x Expr) (string, Expr) {
+	switch v := x.(type) {
+	case Symbol:
+		return v.name, nil
+	case String:
+		return unwrapString(v), nil
+	}
+	return "", Error{name + ": Argument 1 is not a symbol or a string."}
+}
+
+//(provide feature) records that feature has been loaded.
+func provide(e Environment, args ...Expr) Expr {
+	f, err := featureName("provide", args[0])
+	if err != nil {
+		return err
+	}
+	provided.Lock()
+	provided.m[f] = true
+	provided.Unlock()
+	return Boolean(true)
+}
+
+//(require feature [file]) loads file, or the file named after the feature,
+//unless feature has already been provided. It returns #t if it loaded the
+//file and #f if it did not need to. An error while loading the file is
+//returned, and the feature is not provided.
+func require(e Environment, args ...Expr) Expr {
+	f, err := featureName("require", args[0])
+	if err != nil {
+		return err
+	}
+	provided.Lock()
+	done := provided.m[f]
+	provided.Unlock()
+	if done {
+		return Boolean(false)
+	}
+	file, i := []Expr{NewString(f)}, 0
+	if len(args) > 1 {
+		file, i = args, 1
+	}
+	path, err := fileArg(e, "require", file, i)
+	if err != nil {
+		return err
+	}
+	if r := evalFile(path, GlobalEnv); isError(r) {
+		//The file may have provided the feature before failing.
+		provided.Lock()
+		delete(provided.m, f)
+		provided.Unlock()
+		return r
+	}
+	provided.Lock()
+	done = provided.m[f]
+	provided.Unlock()
+	if !done {
+		return Error{"require: " + path + " does not provide " + f + "."}
+	}
+	return Boolean(true)
+}
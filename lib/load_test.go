@@ -0,0 +1,80 @@
+package goscheme
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//writeFiles writes the files to a new directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goscheme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.scm":    "(define before-error 1) (car 5) (define after-error 1)",
+		"nested.scm": `(load "bad")`,
+		"good.scm":   "(define loaded-good #t)",
+	})
+	tests := []struct {
+		src, want string
+	}{
+		{`(load "` + dir + `/good.scm")`, "#t"},
+		{`(load "` + dir + `/good")`, "#t"},
+		{`(load "` + dir + `/bad.scm")`, "car: "},
+		{`(load "` + dir + `/nested.scm")`, "car: "},
+		{`(load "` + dir + `/missing")`, "load: " + dir + "/missing not found, tried " + dir + "/missing, " + dir + "/missing.scm"},
+		{`(load 5)`, "load: Argument 1 is not a string."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s returned %s, want %s", test.src, got, test.want)
+		}
+	}
+	if r := evalString(t, "before-error"); r != Expr(Number(1)) {
+		t.Errorf("The definitions before an error are not kept.")
+	}
+	if r := evalString(t, "after-error"); r != nil {
+		t.Errorf("Loading goes on after an error.")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"once.scm":       "(provide 'once) (define once-count (+ once-count 1))",
+		"forgets.scm":    "(define forgot #t)",
+		"fails.scm":      "(provide 'fails) (car 5)",
+		"named-file.scm": "(provide 'other-name)",
+	})
+	tests := []struct {
+		src, want string
+	}{
+		{"(define once-count 0) (require 'once \"" + dir + "/once\") once-count", "1"},
+		{"(require 'once \"" + dir + "/once\")", "#f"},
+		{"once-count", "1"},
+		{"(require 'forgets \"" + dir + "/forgets.scm\")", "require: " + dir + "/forgets.scm does not provide forgets."},
+		{"(require 'fails \"" + dir + "/fails\")", "car: "},
+		{"(require 'fails \"" + dir + "/fails\")", "car: "},
+		{"(require 'other-name \"" + dir + "/named-file\")", "#t"},
+		{"(require 'nowhere)", "require: nowhere not found, tried "},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s returned %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package goscheme
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+)
+
+/*
+Output ports buffer what is written to them. How long the output stays in the
+buffer depends on the buffering mode of the port:
+	block - Until the buffer is full, the port is flushed or the program exits.
+	        This is the default.
+	line  - Until a newline is written. current-output-port uses this mode.
+	none  - Not at all, every write is flushed immediately.
+Each write holds a lock on the port, so output written from different
+goroutines is never torn apart.
+*/
+
+type bufferMode int
+
+const (
+	bufferBlock bufferMode = iota
+	bufferLine
+	bufferNone
+)
+
+var bufferModeNames = map[bufferMode]Symbol{
+	bufferBlock: Intern("block"),
+	bufferLine:  Intern("line"),
+	bufferNone:  Intern("none"),
+}
+
+//outputPorts holds the buffers of every open output port that is flushed on
+//exit, by the lock of the port. String and bytevector output ports are left
+//out since nothing is lost if they are not flushed, and so are pipes, since
+//flushing one blocks until the other end reads it. The ports themselves are
+//not held, so that a port that is dropped without being closed can be
+//garbage collected. It is flushed when it is.
+var outputPorts = struct {
+	sync.Mutex
+	m map[*sync.Mutex]*bufio.Writer
+}{m: map[*sync.Mutex]*bufio.Writer{}}
+
+var exitHooks []func()
+
+//errPortClosed is returned when writing to an output port that has been
+//closed.
+var errPortClosed = errors.New("The output port is closed.")
+
+//stdout is the port behind current-output-port. It is shared by every
+//environment and by Println, so that everything written to standard output
+//goes through the same buffer. Closing it leaves os.Stdout open, so that
+//Println can still write to it.
+var stdout = newLineOutputPort()
+
+func newLineOutputPort() Port {
+	p := newOutputPort(os.Stdout, nopCloser{})
+	p.buffering = bufferLine
+	return p
+}
+
+func registerOutputPort(p *port) {
+	outputPorts.Lock()
+	outputPorts.m[p.mu] = p.w
+	outputPorts.Unlock()
+	runtime.SetFinalizer(p, func(p *port) {
+		Port{p}.flush()
+		unregisterOutputPort(p)
+	})
+}
+
+func unregisterOutputPort(p *port) {
+	outputPorts.Lock()
+	delete(outputPorts.m, p.mu)
+	outputPorts.Unlock()
+}
+
+//FlushOutput flushes every open output port.
+func FlushOutput() {
+	outputPorts.Lock()
+	ports := make(map[*sync.Mutex]*bufio.Writer, len(outputPorts.m))
+	for mu, w := range outputPorts.m {
+		ports[mu] = w
+	}
+	outputPorts.Unlock()
+	for mu, w := range ports {
+		mu.Lock()
+		w.Flush()
+		mu.Unlock()
+	}
+}
+
+//OnExit registers f to be called by Exit after the output ports have been
+//flushed.
+func OnExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+//Exit flushes every open output port, runs the functions registered with
+//OnExit and exits the program with the given status code.
+func Exit(code int) {
+	FlushOutput()
+	for _, f := range exitHooks {
+		f()
+	}
+	os.Exit(code)
+}
+
+//Println writes s and a newline to standard output through the same port as
+//current-output-port, so that it is not mixed up with output from Scheme code.
+//If Scheme code has closed that port, s is written to os.Stdout directly.
+func Println(s string) {
+	if stdout.writeString(s+"\n") != nil {
+		os.Stdout.WriteString(s + "\n")
+	}
+}
+
+//flush writes out everything buffered in the port.
+func (p Port) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return nil
+	}
+	return p.w.Flush()
+}
+
+//writeString writes s to the port, flushing it if its buffering mode asks for
+//it.
+func (p Port) writeString(s string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return errPortClosed
+	}
+	if _, err := p.w.WriteString(s); err != nil {
+		return err
+	}
+	return p.flushAfter(bytes.ContainsRune([]byte(s), '\n'))
+}
+
+//writeBytes is like writeString for binary output.
+func (p Port) writeBytes(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w == nil {
+		return errPortClosed
+	}
+	if _, err := p.w.Write(b); err != nil {
+		return err
+	}
+	return p.flushAfter(bytes.IndexByte(b, '\n') != -1)
+}
+
+//flushAfter flushes the port after a write, which contained a newline if
+//newline is true. The port's lock must be held.
+func (p Port) flushAfter(newline bool) error {
+	if p.buffering == bufferNone || (p.buffering == bufferLine && newline) {
+		return p.w.Flush()
+	}
+	return nil
+}
+
+//(exit [obj]) flushes all output ports and exits. The exit status is 0 if obj
+//is #t or not given, 1 if it is #f and obj itself if it is a number.
+func exit(e Environment, args ...Expr) Expr {
+	code := 0
+	if len(args) == 1 {
+		switch v := args[0].(type) {
+		case Boolean:
+			if !v {
+				code = 1
+			}
+		case Number:
+			code = int(v)
+		default:
+			return Error{"exit: Argument 1 is not a boolean or a number."}
+		}
+	}
+	Exit(code)
+	return nil
+}
+
+func portbuffering(e Environment, args ...Expr) Expr {
+	p, err := outputPortArg(e, "port-buffering", args, 0)
+	if err != nil {
+		return err
+	}
+	return bufferModeNames[p.buffering]
+}
+
+//(set-port-buffering! port mode) where mode is one of the symbols none, line
+//or block. Switching to a mode that buffers less flushes the port.
+func setportbuffering(e Environment, args ...Expr) Expr {
+	p, err := outputPortArg(e, "set-port-buffering!", args, 0)
+	if err != nil {
+		return err
+	}
+	for m, name := range bufferModeNames {
+		if args[1] == Expr(name) {
+			p.mu.Lock()
+			p.buffering = m
+			p.w.Flush()
+			p.mu.Unlock()
+			return Boolean(true)
+		}
+	}
+	return Error{"set-port-buffering!: Argument 2 is not one of none, line or block."}
+}
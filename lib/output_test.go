@@ -0,0 +1,79 @@
+package goscheme
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func registered(mu *sync.Mutex) bool {
+	outputPorts.Lock()
+	defer outputPorts.Unlock()
+	_, ok := outputPorts.m[mu]
+	return ok
+}
+
+//Flushing a pipe nobody reads from blocks, so FlushOutput must not do it.
+func TestPipeIsNotFlushedOnExit(t *testing.T) {
+	p := evalString(t, "(define pipe (make-pipe)) (write 'x (cadr pipe)) (cadr pipe)").(Port)
+	if registered(p.mu) {
+		t.Fatal("The output port of a pipe is flushed on exit.")
+	}
+	done := make(chan bool)
+	go func() {
+		FlushOutput()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("FlushOutput blocks on an unread pipe.")
+	}
+}
+
+func TestDroppedPortIsUnregistered(t *testing.T) {
+	mu := NewOutputPort(io.Discard).mu
+	if !registered(mu) {
+		t.Fatal("An output port is not flushed on exit.")
+	}
+	for i := 0; i < 100 && registered(mu); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if registered(mu) {
+		t.Error("An output port that was dropped is still flushed on exit.")
+	}
+}
+
+//The interpreter still reports errors after current-output-port is closed.
+func TestPrintlnAfterClose(t *testing.T) {
+	env := testEnv(t)
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved, savedStdout := stdout, os.Stdout
+	defer func() {
+		stdout, os.Stdout = saved, savedStdout
+		env.Local["current-output-port"] = saved
+	}()
+	stdout, os.Stdout = NewOutputPort(io.Discard), f
+	env.Local["current-output-port"] = stdout
+	r := evalString(t, "(close-output-port current-output-port) (car '())")
+	if err := stdout.writeString("x"); err != errPortClosed {
+		t.Errorf("Writing to a closed port returned %v, want %v.", err, errPortClosed)
+	}
+	Println("Error: " + Sprint(r))
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Error: car: List has length 0\n"; string(b) != want {
+		t.Errorf("Println wrote %q, want %q.", b, want)
+	}
+}
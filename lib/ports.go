@@ -0,0 +1,488 @@
+package goscheme
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//currentPort looks up the port bound to name, e.g. current-output-port, from
+//the calling environment. Since every call gets an environment whose parent is
+//the caller's, rebinding the name for a procedure call redirects everything it
+//calls as well.
+func currentPort(e Environment, name string) Expr {
+	return e.get(name)
+}
+
+//inputPortArg returns the input port given as args[i], or the current input
+//port if there are not that many arguments.
+func inputPortArg(e Environment, name string, args []Expr, i int) (Port, Expr) {
+	var ep Expr
+	if len(args) > i {
+		ep = args[i]
+	} else {
+		ep = currentPort(e, "current-input-port")
+	}
+	p, ok := ep.(Port)
+	if !ok || p.port == nil || p.r == nil {
+		return Port{}, Error{name + ": Not an input port."}
+	}
+	return p, nil
+}
+
+//outputPortArg returns the output port given as args[i], or the current
+//output port if there are not that many arguments.
+func outputPortArg(e Environment, name string, args []Expr, i int) (Port, Expr) {
+	var ep Expr
+	if len(args) > i {
+		ep = args[i]
+	} else {
+		ep = currentPort(e, "current-output-port")
+	}
+	p, ok := ep.(Port)
+	if !ok || p.port == nil || p.w == nil {
+		return Port{}, Error{name + ": Not an output port."}
+	}
+	return p, nil
+}
+
+//readFailure converts an error from reading a port into the EOF object, or an
+//Error if the read actually failed.
+func readFailure(err error) Expr {
+	if err == io.EOF {
+		return EOFObject{}
+	}
+	return Error{err.Error()}
+}
+
+//ready reports whether reading from an input port will return without
+//blocking, either because input is available or because the port has ended.
+//In-memory readers and regular files never block. Other files, like pipes, are
+//polled briefly if they support deadlines, and are otherwise assumed not to be
+//ready since there is no way to tell without blocking.
+func (p Port) ready() bool {
+	if p.r.Buffered() > 0 {
+		return true
+	}
+	switch src := p.src.(type) {
+	case interface{ Len() int }:
+		return true
+	case *os.File:
+		if fi, err := src.Stat(); err == nil && fi.Mode().IsRegular() {
+			return true
+		}
+		if src.SetReadDeadline(time.Now().Add(time.Millisecond)) != nil {
+			return false
+		}
+		_, err := p.r.Peek(1)
+		src.SetReadDeadline(time.Time{})
+		return !errors.Is(err, os.ErrDeadlineExceeded)
+	}
+	return false
+}
+
+//newBufferOutputPort creates a port that writes to a bytes.Buffer, used for
+//both string and bytevector output ports.
+func newBufferOutputPort() Port {
+	buf := &bytes.Buffer{}
+	p := newOutputPort(buf, nopCloser{})
+	p.out = buf
+	return p
+}
+
+//outputBuffer flushes the port and returns what has been written to it so far.
+func outputBuffer(name string, arg Expr) (*bytes.Buffer, Expr) {
+	p, ok := arg.(Port)
+	if !ok || p.out == nil {
+		return nil, Error{name + ": Argument 1 is not a string or bytevector output port."}
+	}
+	p.flush()
+	return p.out, nil
+}
+
+//(call-with-output-string proc) calls proc with a new string output port and
+//returns what was written to it.
+func callwithoutputstring(e Environment, args ...Expr) Expr {
+	proc, ok := args[0].(Proc)
+	if !ok {
+		return Error{"call-with-output-string: Argument 1 is not a procedure."}
+	}
+	p := newBufferOutputPort()
+	if r, ok := callProc(e, proc, p).(Error); ok {
+		return r
+	}
+	p.flush()
+	return NewString(p.out.String())
+}
+
+func eofobject(e Environment, args ...Expr) Expr {
+	return EOFObject{}
+}
+
+func eofobject_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(EOFObject)
+	return Boolean(ok)
+}
+
+func getoutputbytevector(e Environment, args ...Expr) Expr {
+	buf, err := outputBuffer("get-output-bytevector", args[0])
+	if err != nil {
+		return err
+	}
+	return Bytevector(append([]byte{}, buf.Bytes()...))
+}
+
+func getoutputstring(e Environment, args ...Expr) Expr {
+	buf, err := outputBuffer("get-output-string", args[0])
+	if err != nil {
+		return err
+	}
+	return NewString(buf.String())
+}
+
+func openinputbytevector(e Environment, args ...Expr) Expr {
+	if b, ok := args[0].(Bytevector); !ok {
+		return Error{"open-input-bytevector: Argument 1 is not a bytevector."}
+	} else {
+		return newInputPort(bytes.NewReader(append([]byte{}, b...)), nopCloser{})
+	}
+}
+
+func openinputstring(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"open-input-string: Argument 1 is not a string."}
+	} else {
+		return newInputPort(strings.NewReader(unwrapString(s)), nopCloser{})
+	}
+}
+
+func openoutputbytevector(e Environment, args ...Expr) Expr {
+	return newBufferOutputPort()
+}
+
+func openoutputstring(e Environment, args ...Expr) Expr {
+	return newBufferOutputPort()
+}
+
+//readerAndPort gets the optional reader procedure in args[i], which defaults
+//to read, and the optional port after it.
+func readerAndPort(e Environment, name string, args []Expr, i int) (Proc, Port, Expr) {
+	var reader Proc = NewBuiltIn("read", 0, 1, read)
+	if len(args) > i {
+		r, ok := args[i].(Proc)
+		if !ok {
+			return nil, Port{}, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a procedure."}
+		}
+		reader = r
+	}
+	p, err := inputPortArg(e, name, args, i+1)
+	return reader, p, err
+}
+
+//portIter calls reader with p until it returns the EOF object, passing each
+//datum to fn. It stops early if either of them returns an Error.
+func portIter(e Environment, reader Proc, p Port, fn func(Expr) Expr) Expr {
+	for {
+		d := callProc(e, reader, p)
+		if _, ok := d.(EOFObject); ok {
+			return nil
+		}
+		if _, ok := d.(Error); ok {
+			return d
+		}
+		if r := fn(d); r != nil {
+			return r
+		}
+	}
+}
+
+func peeku8(e Environment, args ...Expr) Expr {
+	p, err := inputPortArg(e, "peek-u8", args, 0)
+	if err != nil {
+		return err
+	}
+	b, rerr := p.r.Peek(1)
+	if rerr != nil {
+		return readFailure(rerr)
+	}
+	return Number(b[0])
+}
+
+//(port->list [reader [port]]) returns the datums read from port by reader,
+//which defaults to read.
+func porttolist(e Environment, args ...Expr) Expr {
+	reader, p, err := readerAndPort(e, "port->list", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := make([]Expr, 0)
+	err = portIter(e, reader, p, func(d Expr) Expr {
+		ret = append(ret, d)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//(port-fold kons knil [reader [port]]) calls (kons datum acc) for every datum
+//read from port by reader, which defaults to read.
+func portfold(e Environment, args ...Expr) Expr {
+	kons, ok := args[0].(Proc)
+	if !ok {
+		return Error{"port-fold: Argument 1 is not a procedure."}
+	}
+	reader, p, err := readerAndPort(e, "port-fold", args, 2)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	err = portIter(e, reader, p, func(d Expr) Expr {
+		acc = callProc(e, kons, d, acc)
+		if _, ok := acc.(Error); ok {
+			return acc
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return acc
+}
+
+//(port-for-each-line proc [port]) calls proc with every line read from port.
+func portforeachline(e Environment, args ...Expr) Expr {
+	proc, ok := args[0].(Proc)
+	if !ok {
+		return Error{"port-for-each-line: Argument 1 is not a procedure."}
+	}
+	p, err := inputPortArg(e, "port-for-each-line", args, 1)
+	if err != nil {
+		return err
+	}
+	err = portIter(e, NewBuiltIn("read-line", 0, 1, readline), p, func(d Expr) Expr {
+		if r, ok := callProc(e, proc, d).(Error); ok {
+			return r
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return Boolean(true)
+}
+
+func read(e Environment, args ...Expr) Expr {
+	var ep Expr
+	if len(args) == 1 {
+		ep = args[0]
+	} else {
+		ep = currentPort(e, "current-input-port")
+	}
+	p, ok := ep.(Port)
+	if !ok || p.r == nil {
+		return Error{"read: Not an input port."}
+	}
+	d, err := readDatum(p, e)
+	if err != nil {
+		return readFailure(err)
+	}
+	return d
+}
+
+//(read-all [port]) returns the input available on the port as a string. That
+//is everything up to the end of a file or a string port, but only what arrives
+//with the first read from an interactive port or a pipe, waiting for it if
+//nothing has arrived yet. It returns an eof object at the end of the port.
+func readall(e Environment, args ...Expr) Expr {
+	p, err := inputPortArg(e, "read-all", args, 0)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for {
+		if p.r.Buffered() == 0 {
+			if _, rerr := p.r.Peek(1); rerr == io.EOF {
+				break
+			} else if rerr != nil {
+				return Error{rerr.Error()}
+			}
+		}
+		chunk, _ := p.r.Peek(p.r.Buffered())
+		b.Write(chunk)
+		p.r.Discard(len(chunk))
+		if !p.ready() {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return EOFObject{}
+	}
+	return NewString(b.String())
+}
+
+//(read-bytevector k [port]) reads up to k bytes, returning fewer if the port
+//ends first.
+func readbytevector(e Environment, args ...Expr) Expr {
+	k, ok := args[0].(Number)
+	if !ok || k < 0 {
+		return Error{"read-bytevector: Argument 1 is not a non-negative number."}
+	}
+	p, err := inputPortArg(e, "read-bytevector", args, 1)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, int(k))
+	n, rerr := io.ReadFull(p.r, b)
+	if n == 0 && k > 0 {
+		return EOFObject{}
+	}
+	if rerr != nil && rerr != io.ErrUnexpectedEOF {
+		return Error{rerr.Error()}
+	}
+	return Bytevector(b[:n])
+}
+
+//(read-bytevector! bv [port [start [end]]]) reads into bv and returns the
+//number of bytes read.
+func readbytevector_(e Environment, args ...Expr) Expr {
+	bv, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"read-bytevector!: Argument 1 is not a bytevector."}
+	}
+	p, err := inputPortArg(e, "read-bytevector!", args, 1)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeArgs("read-bytevector!", args, 2, len(bv))
+	if err != nil {
+		return err
+	}
+	n, rerr := io.ReadFull(p.r, bv[start:end])
+	if n == 0 && end > start {
+		return EOFObject{}
+	}
+	if rerr != nil && rerr != io.ErrUnexpectedEOF {
+		return Error{rerr.Error()}
+	}
+	return Number(n)
+}
+
+//(read-line [port]) reads up to the next newline, which is not included in
+//the result. A carriage return before the newline is dropped as well.
+func readline(e Environment, args ...Expr) Expr {
+	p, err := inputPortArg(e, "read-line", args, 0)
+	if err != nil {
+		return err
+	}
+	line, rerr := p.r.ReadString('\n')
+	if rerr != nil && (rerr != io.EOF || line == "") {
+		return readFailure(rerr)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return NewString(strings.TrimSuffix(line, "\r"))
+}
+
+//(read-string k [port]) reads up to k characters, returning fewer if the port
+//ends first.
+func readstring(e Environment, args ...Expr) Expr {
+	k, ok := args[0].(Number)
+	if !ok || k < 0 {
+		return Error{"read-string: Argument 1 is not a non-negative number."}
+	}
+	p, err := inputPortArg(e, "read-string", args, 1)
+	if err != nil {
+		return err
+	}
+	r := make([]rune, 0, int(k))
+	for len(r) < int(k) {
+		c, _, rerr := p.r.ReadRune()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return Error{rerr.Error()}
+		}
+		r = append(r, c)
+	}
+	if len(r) == 0 && k > 0 {
+		return EOFObject{}
+	}
+	return runesToString(r)
+}
+
+func readu8(e Environment, args ...Expr) Expr {
+	p, err := inputPortArg(e, "read-u8", args, 0)
+	if err != nil {
+		return err
+	}
+	b, rerr := p.r.ReadByte()
+	if rerr != nil {
+		return readFailure(rerr)
+	}
+	return Number(b)
+}
+
+func u8ready_(e Environment, args ...Expr) Expr {
+	p, err := inputPortArg(e, "u8-ready?", args, 0)
+	if err != nil {
+		return err
+	}
+	return Boolean(p.ready())
+}
+
+//(with-output-to-string thunk) calls thunk with current-output-port bound to a
+//new string output port and returns what was written to it.
+func withoutputtostring(e Environment, args ...Expr) Expr {
+	thunk, ok := args[0].(Proc)
+	if !ok {
+		return Error{"with-output-to-string: Argument 1 is not a procedure."}
+	}
+	p := newBufferOutputPort()
+	nEnv := Environment{map[string]Expr{"current-output-port": p}, map[Symbol]transformer{}, &e, false}
+	if r, ok := thunk.eval(nEnv).(Error); ok {
+		return r
+	}
+	p.flush()
+	return NewString(p.out.String())
+}
+
+//(write-bytevector bv [port [start [end]]])
+func writebytevector(e Environment, args ...Expr) Expr {
+	bv, ok := args[0].(Bytevector)
+	if !ok {
+		return Error{"write-bytevector: Argument 1 is not a bytevector."}
+	}
+	p, err := outputPortArg(e, "write-bytevector", args, 1)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeArgs("write-bytevector", args, 2, len(bv))
+	if err != nil {
+		return err
+	}
+	if werr := p.writeBytes(bv[start:end]); werr != nil {
+		return Error{werr.Error()}
+	}
+	return Boolean(true)
+}
+
+func writeu8(e Environment, args ...Expr) Expr {
+	b, err := byteArg("write-u8", args, 0)
+	if err != nil {
+		return err
+	}
+	p, err := outputPortArg(e, "write-u8", args, 1)
+	if err != nil {
+		return err
+	}
+	if werr := p.writeBytes([]byte{b}); werr != nil {
+		return Error{werr.Error()}
+	}
+	return Boolean(true)
+}
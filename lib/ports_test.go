@@ -0,0 +1,162 @@
+package goscheme
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadAll(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(read-all (open-input-string "abc\ndef"))`, `"abc\ndef"`},
+		{`(read-all (open-input-string ""))`, "#<eof>"},
+		{`(define p (open-input-string "xyz")) (read-char p) (read-all p)`, `"yz"`},
+		{`(read-all p)`, "#<eof>"},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//read-all returns what is buffered from a pipe instead of waiting for the
+//writer to close it.
+func TestReadAllPipe(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	go w.Write([]byte("abc"))
+	p := NewInputPort(r)
+	env := testEnv(t)
+	if c := readchar(env, p); c != Expr(Character('a')) {
+		t.Fatalf("read-char returned %v", Sprint(c))
+	}
+	done := make(chan Expr)
+	go func() { done <- readall(env, p) }()
+	select {
+	case s := <-done:
+		if Sprint(s) != `"bc"` {
+			t.Errorf("read-all returned %s, want \"bc\"", Sprint(s))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("read-all waits for the pipe to be closed.")
+	}
+}
+
+//read-all waits for the first input from a pipe, and returns an eof object
+//once the pipe is closed.
+func TestReadAllWaits(t *testing.T) {
+	env := testEnv(t)
+	r, w := io.Pipe()
+	p := NewInputPort(r)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("hello\n"))
+		w.Close()
+	}()
+	if s := Sprint(readall(env, p)); s != `"hello\n"` {
+		t.Errorf("read-all returned %s, want \"hello\\n\"", s)
+	}
+	if s := Sprint(readall(env, p)); s != "#<eof>" {
+		t.Errorf("read-all at the end of a pipe returned %s, want #<eof>", s)
+	}
+}
+
+//Stdin is read like an os.Pipe, which does not tell whether input is ready.
+func TestReadAllOSPipe(t *testing.T) {
+	env := testEnv(t)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.Write([]byte("hello\n"))
+	w.Close()
+	p := newInputPort(r, r)
+	if s := Sprint(readall(env, p)); s != `"hello\n"` {
+		t.Errorf("read-all returned %s, want \"hello\\n\"", s)
+	}
+	if s := Sprint(readall(env, p)); s != "#<eof>" {
+		t.Errorf("read-all at the end of a pipe returned %s, want #<eof>", s)
+	}
+}
+
+//char-ready? on a pipe tells whether a character can be read without waiting,
+//and at the end of the input it is true because read-char returns at once.
+func TestCharReady(t *testing.T) {
+	env := testEnv(t)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	p := newInputPort(r, r)
+	if charready_(env, p) != Expr(Boolean(false)) {
+		t.Errorf("char-ready? is true on an empty pipe.")
+	}
+	w.Write([]byte("a"))
+	if charready_(env, p) != Expr(Boolean(true)) {
+		t.Errorf("char-ready? is false after a character was written to the pipe.")
+	}
+	if c := Sprint(readchar(env, p)); c != `#\a` {
+		t.Errorf("read-char returned %s, want #\\a", c)
+	}
+	w.Close()
+	if charready_(env, p) != Expr(Boolean(true)) {
+		t.Errorf("char-ready? is false at the end of the pipe.")
+	}
+	if _, ok := readchar(env, p).(EOFObject); !ok {
+		t.Errorf("read-char at the end of the pipe does not return the EOF object.")
+	}
+}
+
+//Every input procedure returns the EOF object at the end of the input, and
+//slurpfile stops there.
+func TestEOFObject(t *testing.T) {
+	for _, src := range []string{"(read-char (open-input-string \"\"))", "(peek-char (open-input-string \"\"))", "(read-line (open-input-string \"\"))",
+		"(read-string 3 (open-input-string \"\"))", "(read (open-input-string \" \"))", "(read-u8 (open-input-bytevector #u8()))", "(eof-object)"} {
+		if got := evalString(t, "(eof-object? "+src+")"); got != Expr(Boolean(true)) {
+			t.Errorf("%s does not return the EOF object", src)
+		}
+	}
+	if got := evalString(t, "(eof-object? #f)"); got != Expr(Boolean(false)) {
+		t.Errorf("#f is an EOF object")
+	}
+	dir := writeFiles(t, map[string]string{"slurp.txt": "ab\nc"})
+	if got := Sprint(evalString(t, `(slurpfile "`+dir+`/slurp.txt")`)); got != `(#\a #\b #\newline #\c)` {
+		t.Errorf("slurpfile returned %s", got)
+	}
+}
+
+//A read procedure that returns "" ends the input instead of being called
+//again and again.
+func TestCustomInputPortEmptyString(t *testing.T) {
+	src := `
+(define chunks '(start "ab" "c"))
+(define p (make-custom-input-port
+  (lambda ()
+    (if (null? (cdr chunks))
+        ""
+        (begin (set! chunks (cdr chunks)) (car chunks))))))`
+	evalString(t, src)
+	done := make(chan Expr)
+	d, err := readOne(t, "(read-line p)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { done <- Eval(d, GlobalEnv) }()
+	select {
+	case got := <-done:
+		if s := Sprint(got); s != `"abc"` {
+			t.Errorf("(read-line p) = %s, want \"abc\"", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reading from a port whose read procedure returns \"\" does not end.")
+	}
+	if got := evalString(t, "(eof-object? (read-char p))"); got != Expr(Boolean(true)) {
+		t.Errorf("The port is not at the end after the read procedure returned \"\".")
+	}
+}
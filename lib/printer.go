@@ -0,0 +1,377 @@
+package goscheme
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+The printer turns datums back into text. The REPL, load, write, display and
+number->string all use it, so a datum looks the same wherever it is printed.
+Datums that have no external representation are printed as #<...>.
+Pairs and vectors that are part of a cycle are printed with datum labels, so
+that printing a cyclic structure terminates.
+*/
+
+type printer struct {
+	b strings.Builder
+	//display prints strings and characters as their contents.
+	display bool
+	//labels holds the pairs and vectors that are printed with a datum label,
+	//keyed by the pointer to their first element. The label number is -1
+	//until the first time they are printed.
+	labels map[*Expr]int
+	next   int
+}
+
+//Sprint returns x the way write prints it.
+func Sprint(x Expr) string {
+	p := printer{labels: sharedNodes(x, false)}
+	p.print(x)
+	return p.b.String()
+}
+
+//displayString returns x the way display prints it.
+func displayString(x Expr) string {
+	p := printer{display: true, labels: sharedNodes(x, false)}
+	p.print(x)
+	return p.b.String()
+}
+
+//sharedString returns x the way write-shared prints it, with a label for
+//everything that occurs more than once.
+func sharedString(x Expr) string {
+	p := printer{labels: sharedNodes(x, true)}
+	p.print(x)
+	return p.b.String()
+}
+
+//simpleString returns x the way write-simple prints it, without any labels.
+func simpleString(x Expr) string {
+	p := printer{}
+	p.print(x)
+	return p.b.String()
+}
+
+//sharedNodes finds the pairs and vectors in x that need a datum label. If all
+//is false only those that are part of a cycle need one, otherwise every one
+//that is reached more than once does.
+func sharedNodes(x Expr, all bool) map[*Expr]int {
+	const visiting, visited = 1, 2
+	labels := map[*Expr]int{}
+	state := map[*Expr]int{}
+	//enter reports whether the node with key k should be walked.
+	enter := func(k *Expr) bool {
+		switch state[k] {
+		case visiting:
+			labels[k] = -1
+			return false
+		case visited:
+			if all {
+				labels[k] = -1
+			}
+			return false
+		}
+		state[k] = visiting
+		return true
+	}
+	var visit func(x Expr)
+	var visitCell func(cell *ExprList)
+	visitCell = func(cell *ExprList) {
+		if cell == nil || cell.car == nil || !enter(cell.car) {
+			return
+		}
+		visit(*cell.car)
+		visitCell(cell.cdr)
+		state[cell.car] = visited
+	}
+	visit = func(x Expr) {
+		switch v := x.(type) {
+		case ExprList:
+			visitCell(&v)
+		case Vector:
+			if len(v) == 0 || !enter(&v[0]) {
+				return
+			}
+			for _, y := range v {
+				visit(y)
+			}
+			state[&v[0]] = visited
+		}
+	}
+	visit(x)
+	return labels
+}
+
+//label prints the datum label for the node with key k if it has one. It
+//returns true if the node has already been printed, so only a reference to it
+//should be.
+func (p *printer) label(k *Expr) bool {
+	n, ok := p.labels[k]
+	if !ok {
+		return false
+	}
+	if n >= 0 {
+		p.b.WriteString("#" + strconv.Itoa(n) + "#")
+		return true
+	}
+	p.labels[k] = p.next
+	p.b.WriteString("#" + strconv.Itoa(p.next) + "=")
+	p.next++
+	return false
+}
+
+func (p *printer) print(x Expr) {
+	switch v := x.(type) {
+	case nil:
+		p.b.WriteString("#<unspecified>")
+	case Number:
+		p.b.WriteString(formatNumber(float64(v)))
+	case Complex:
+		p.b.WriteString(formatNumber(real(v)))
+		if im := imag(v); !math.IsInf(im, 0) && !math.IsNaN(im) && im >= 0 {
+			p.b.WriteString("+")
+		}
+		p.b.WriteString(formatNumber(imag(v)) + "i")
+	case Symbol:
+		if p.display || !needsBars(v.name) {
+			p.b.WriteString(v.name)
+		} else {
+			p.writeQuoted([]rune(v.name), '|')
+		}
+	case String:
+		if p.display {
+			p.b.WriteString(string(*v.runes))
+		} else {
+			p.writeQuoted(*v.runes, '"')
+		}
+	case Character:
+		if p.display {
+			p.b.WriteRune(rune(v))
+		} else {
+			p.b.WriteString(writeCharacter(rune(v)))
+		}
+	case ExprList:
+		if v.car == nil {
+			p.b.WriteString("()")
+			return
+		}
+		if p.label(v.car) {
+			return
+		}
+		p.b.WriteString("(")
+		p.print(*v.car)
+		for cell := v.cdr; cell != nil && cell.car != nil; cell = cell.cdr {
+			//A labelled tail has to be printed as a dotted pair so that
+			//the label can refer to it.
+			if _, ok := p.labels[cell.car]; ok {
+				p.b.WriteString(" . ")
+				p.print(*cell)
+				break
+			}
+			p.b.WriteString(" ")
+			//The reader keeps the dot of a dotted list as the symbol ".",
+			//so it is written bare where it reads back as one.
+			if *cell.car == Expr(Intern(".")) && cell.cdr != nil && cell.cdr.car != nil &&
+				(cell.cdr.cdr == nil || cell.cdr.cdr.car == nil) {
+				p.b.WriteString(".")
+				continue
+			}
+			p.print(*cell.car)
+		}
+		p.b.WriteString(")")
+	case Vector:
+		if len(v) != 0 && p.label(&v[0]) {
+			return
+		}
+		p.b.WriteString("#(")
+		for i, y := range v {
+			if i != 0 {
+				p.b.WriteString(" ")
+			}
+			p.print(y)
+		}
+		p.b.WriteString(")")
+	case Port:
+		p.b.WriteString(portString(v))
+	case Channel:
+		p.b.WriteString("#<channel>")
+	case Environment:
+		p.b.WriteString("#<environment>")
+	case SyntaxRule:
+		p.b.WriteString("#<syntax-rules>")
+	case Func:
+		p.b.WriteString("#<procedure>")
+	case Error:
+		p.b.WriteString(v.s)
+	case EvalBlock:
+		p.print(v.e)
+	default:
+		fmt.Fprint(&p.b, v)
+	}
+}
+
+//writeQuoted writes r between quote characters, escaping them, so that the
+//reader reads it back as the same string or, with '|', the same symbol.
+func (p *printer) writeQuoted(r []rune, quote rune) {
+	p.b.WriteRune(quote)
+	for _, c := range r {
+		switch c {
+		case quote:
+			p.b.WriteString("\\" + string(quote))
+		case '\\':
+			p.b.WriteString("\\\\")
+		case '\a':
+			p.b.WriteString("\\a")
+		case '\b':
+			p.b.WriteString("\\b")
+		case '\t':
+			p.b.WriteString("\\t")
+		case '\n':
+			p.b.WriteString("\\n")
+		case '\r':
+			p.b.WriteString("\\r")
+		default:
+			if unicode.IsPrint(c) {
+				p.b.WriteRune(c)
+			} else {
+				fmt.Fprintf(&p.b, "\\x%x;", c)
+			}
+		}
+	}
+	p.b.WriteRune(quote)
+}
+
+//needsBars reports whether a symbol with the given name has to be written
+//between bars to be read back as the same symbol.
+func needsBars(name string) bool {
+	if name == "" || name == "." || name[0] == '#' {
+		return true
+	}
+	if _, ok := atom(name).(Symbol); !ok {
+		return true
+	}
+	for _, c := range name {
+		if isDelimiter(c) || !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
+var characterNames = map[rune]string{
+	0x00: "null",
+	0x07: "alarm",
+	0x08: "backspace",
+	0x09: "tab",
+	0x0a: "newline",
+	0x0d: "return",
+	0x1b: "escape",
+	0x20: "space",
+	0x7f: "delete",
+}
+
+//writeCharacter returns the #\ syntax for r, using its name if it has one.
+func writeCharacter(r rune) string {
+	if n, ok := characterNames[r]; ok {
+		return "#\\" + n
+	}
+	if !unicode.IsPrint(r) {
+		return fmt.Sprintf("#\\x%x", r)
+	}
+	return "#\\" + string(r)
+}
+
+//formatNumber formats f in radix 10.
+func formatNumber(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	case math.IsNaN(f):
+		return "+nan.0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+//formatRadix formats f in the given radix. Only integers can be formatted in
+//a radix other than 10, for anything else an error for the procedure name is
+//returned.
+func formatRadix(name string, f float64, radix int) (string, Expr) {
+	if radix == 10 {
+		return formatNumber(f), nil
+	}
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return "", Error{name + ": Only integers can be written in radix " + strconv.Itoa(radix) + "."}
+	}
+	i, _ := big.NewFloat(f).Int(nil)
+	return i.Text(radix), nil
+}
+
+func portString(p Port) string {
+	switch {
+	case p.port == nil:
+		return "#<port>"
+	case p.r != nil && p.w != nil:
+		return "#<input-output-port>"
+	case p.r != nil:
+		return "#<input-port>"
+	case p.out != nil:
+		return "#<string-output-port>"
+	}
+	return "#<output-port>"
+}
+
+func display(e Environment, args ...Expr) Expr {
+	return printTo(e, "display", args, displayString)
+}
+
+//printTo prints args[0] using print to the port in args[1], or the current
+//output port.
+func printTo(e Environment, name string, args []Expr, print func(Expr) string) Expr {
+	p, err := outputPortArg(e, name, args, 1)
+	if err != nil {
+		return err
+	}
+	if werr := p.writeString(print(args[0])); werr != nil {
+		return Error{werr.Error()}
+	}
+	return Boolean(true)
+}
+
+func write(e Environment, args ...Expr) Expr {
+	return printTo(e, "write", args, Sprint)
+}
+
+func writeshared(e Environment, args ...Expr) Expr {
+	return printTo(e, "write-shared", args, sharedString)
+}
+
+func writesimple(e Environment, args ...Expr) Expr {
+	return printTo(e, "write-simple", args, simpleString)
+}
+
+//(write-string string [port [start [end]]])
+func writestring(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"write-string: Argument 1 is not a string."}
+	}
+	p, err := outputPortArg(e, "write-string", args, 1)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeArgs("write-string", args, 2, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	if werr := p.writeString(string((*s.runes)[start:end])); werr != nil {
+		return Error{werr.Error()}
+	}
+	return Boolean(true)
+}
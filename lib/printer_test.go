@@ -0,0 +1,94 @@
+package goscheme
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestProcedureString(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(lambda (a b) a)", "#<procedure (a b)>"},
+		{"(lambda (a . rest) a)", "#<procedure (a . rest)>"},
+		{"(lambda args args)", "#<procedure args>"},
+		{"(lambda (|a b|) 1)", "#<procedure (|a b|)>"},
+		{"(define f (lambda (x) x)) f", "#<procedure f (x)>"},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s is written as %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//A symbol written with write is read back as the same symbol.
+func TestWriteSymbol(t *testing.T) {
+	for name, want := range map[string]string{".": "|.|", "...": "...", "a.b": "a.b", "": "||", "1": "|1|"} {
+		s := Intern(name)
+		if got := Sprint(s); got != want {
+			t.Errorf("The symbol %q is written as %s, want %s", name, got, want)
+		}
+		if got := evalString(t, "(quote "+want+")"); got != Expr(s) {
+			t.Errorf("%s is read back as %s", want, Sprint(got))
+		}
+	}
+}
+
+//The dot of a dotted list is read as the symbol ".", which is written bare
+//only where it is read back as the same list.
+func TestWriteDottedList(t *testing.T) {
+	for _, src := range []string{"(1 . 2)", "(a b . c)", "((x) . 2)", "(a |.| b c)", "(a b |.|)"} {
+		if got := Sprint(evalString(t, "'"+src)); got != src {
+			t.Errorf("'%s is written as %s", src, got)
+		}
+	}
+}
+
+//Every type is printed in Scheme syntax by write and without quoting by
+//display.
+func TestPrintTypes(t *testing.T) {
+	tests := []struct {
+		x              Expr
+		write, display string
+	}{
+		{NewString("a\"b\\c\n"), `"a\"b\\c\n"`, "a\"b\\c\n"},
+		{Number(1000000), "1000000", "1000000"},
+		{Number(-0.25), "-0.25", "-0.25"},
+		{Number(math.Inf(-1)), "-inf.0", "-inf.0"},
+		{Complex(complex(1, -2)), "1-2i", "1-2i"},
+		{Character(' '), `#\space`, " "},
+		{Character('λ'), `#\λ`, "λ"},
+		{Intern("a b"), "|a b|", "a b"},
+		{Vector{Number(1), NewString("x")}, `#(1 "x")`, "#(1 x)"},
+		{SliceToExprList([]Expr{Character('a'), SliceToExprList(nil)}), `(#\a ())`, "(a ())"},
+		{Bytevector{1, 255}, "#u8(1 255)", "#u8(1 255)"},
+		{NewOutputPort(io.Discard), "#<output-port>", "#<output-port>"},
+		{newBufferOutputPort(), "#<string-output-port>", "#<string-output-port>"},
+		{Port{}, "#<port>", "#<port>"},
+		{make(Channel), "#<channel>", "#<channel>"},
+		{Environment{}, "#<environment>", "#<environment>"},
+		{EOFObject{}, "#<eof>", "#<eof>"},
+		{nil, "#<unspecified>", "#<unspecified>"},
+	}
+	for _, test := range tests {
+		if got := Sprint(test.x); got != test.write {
+			t.Errorf("%#v is written as %s, want %s", test.x, got, test.write)
+		}
+		if got := displayString(test.x); got != test.display {
+			t.Errorf("%#v is displayed as %s, want %s", test.x, got, test.display)
+		}
+	}
+}
+
+//write, display, write-string and number->string all go through the same
+//printer, so they agree on numbers.
+func TestPrintProcedures(t *testing.T) {
+	got := evalString(t, `(with-output-to-string (lambda () (begin
+  (write 1000000) (display " ") (display 1e21) (display " ")
+  (write-string (number->string 100.5)) (display " ") (write "q\""))))`)
+	if want := `"1000000 1000000000000000000000 100.5 \"q\\\"\""`; Sprint(got) != want {
+		t.Errorf("The output is %s, want %s", Sprint(got), want)
+	}
+}
@@ -0,0 +1,85 @@
+package goscheme
+
+//callProc calls p from Go code, giving it a fresh environment the same way Eval
+//does for a procedure call.
+func callProc(e Environment, p Proc, args ...Expr) Expr {
+	nEnv := Environment{map[string]Expr{}, map[Symbol]transformer{}, &e, false}
+	return p.eval(nEnv, args...)
+}
+
+//arity returns the minimum and maximum number of arguments p still accepts.
+//max is -1 if p is variadic.
+func arity(p Proc) (min, max int) {
+	switch v := p.(type) {
+	case UserProc:
+		n := v.params.Length() - len(v.partialArgs)
+		if v.variadic {
+			min, max = n-1, -1
+		} else {
+			min, max = n, n
+		}
+	case BuiltIn:
+		min, max = v.minParams-len(v.partialArgs), v.maxParams
+		if max != -1 {
+			max -= len(v.partialArgs)
+		}
+	}
+	if min < 0 {
+		min = 0
+	}
+	return min, max
+}
+
+//Returns the number of arguments the procedure takes, or a list of the minimum
+//and maximum number of arguments if it is not fixed. The maximum is #f for
+//variadic procedures.
+func procarity(e Environment, args ...Expr) Expr {
+	p, ok := args[0].(Proc)
+	if !ok {
+		return Error{"procedure-arity: Argument 1 is not a procedure."}
+	}
+	min, max := arity(p)
+	if min == max {
+		return Number(min)
+	}
+	if max == -1 {
+		return SliceToExprList([]Expr{Number(min), Boolean(false)})
+	}
+	return SliceToExprList([]Expr{Number(min), Number(max)})
+}
+
+func procdoc(e Environment, args ...Expr) Expr {
+	p, ok := args[0].(Proc)
+	if !ok {
+		return Error{"procedure-documentation: Argument 1 is not a procedure."}
+	}
+	if u, ok := p.(UserProc); ok && u.doc != "" {
+		return NewString(u.doc)
+	}
+	return Boolean(false)
+}
+
+func procname(e Environment, args ...Expr) Expr {
+	switch v := args[0].(type) {
+	case UserProc:
+		if v.name == "" {
+			return Boolean(false)
+		}
+		return Intern(v.name)
+	case BuiltIn:
+		return Intern(v.name)
+	}
+	return Error{"procedure-name: Argument 1 is not a procedure."}
+}
+
+//Built in procedures have no source, so #f is returned for them.
+func procsource(e Environment, args ...Expr) Expr {
+	p, ok := args[0].(Proc)
+	if !ok {
+		return Error{"procedure-source: Argument 1 is not a procedure."}
+	}
+	if u, ok := p.(UserProc); ok {
+		return u.source
+	}
+	return Boolean(false)
+}
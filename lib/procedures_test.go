@@ -0,0 +1,70 @@
+package goscheme
+
+import "testing"
+
+func TestArity(t *testing.T) {
+	env := testEnv(t)
+	tests := []struct {
+		proc     string
+		min, max int
+	}{
+		{"car", 1, 1},
+		{"+", 0, -1},
+		{"-", 1, -1},
+		{"(lambda (a b c) a)", 3, 3},
+		{"(lambda (a . rest) a)", 1, -1},
+		{"(lambda args args)", 0, -1},
+		{"((lambda (a b c) a) 1)", 2, 2},
+	}
+	for _, test := range tests {
+		p, ok := evalString(t, test.proc).(Proc)
+		if !ok {
+			t.Fatalf("%s is not a procedure", test.proc)
+		}
+		if min, max := arity(p); min != test.min || max != test.max {
+			t.Errorf("The arity of %s is %d to %d, want %d to %d", test.proc, min, max, test.min, test.max)
+		}
+	}
+	if got := Sprint(procarity(env, env.Local["-"])); got != "(1 #f)" {
+		t.Errorf("(procedure-arity -) = %s, want (1 #f)", got)
+	}
+}
+
+//A leading string in a lambda body is its documentation only if more of the
+//body follows it.
+func TestProcedureDocumentation(t *testing.T) {
+	evalString(t, `(define documented (lambda (a b c) "Adds three numbers." (+ a b c)))`)
+	if got := Sprint(evalString(t, "(procedure-documentation documented)")); got != `"Adds three numbers."` {
+		t.Errorf("(procedure-documentation documented) = %s", got)
+	}
+	if got := Sprint(evalString(t, "(documented 1 2 3)")); got != "6" {
+		t.Errorf("The docstring changes the value of the procedure: %s", got)
+	}
+	if got := Sprint(evalString(t, `((lambda () "value"))`)); got != `"value"` {
+		t.Errorf("A body of only a string returns %s", got)
+	}
+	for _, src := range []string{`(procedure-documentation (lambda () "value"))`, "(procedure-documentation car)"} {
+		if got := evalString(t, src); got != Expr(Boolean(false)) {
+			t.Errorf("%s = %s, want #f", src, Sprint(got))
+		}
+	}
+}
+
+func TestProcedureNameAndSource(t *testing.T) {
+	evalString(t, "(define named (lambda (x) (* x x)))")
+	if got := evalString(t, "(procedure-name named)"); got != Expr(Intern("named")) {
+		t.Errorf("(procedure-name named) = %s", Sprint(got))
+	}
+	if got := evalString(t, "(procedure-name car)"); got != Expr(Intern("car")) {
+		t.Errorf("(procedure-name car) = %s", Sprint(got))
+	}
+	if got := evalString(t, "(procedure-name (lambda (x) x))"); got != Expr(Boolean(false)) {
+		t.Errorf("An anonymous procedure is named %s", Sprint(got))
+	}
+	if got := Sprint(evalString(t, "(procedure-source named)")); got != "(lambda (x) (* x x))" {
+		t.Errorf("(procedure-source named) = %s", got)
+	}
+	if got := Sprint(evalString(t, "(procedure-source 5)")); got != "procedure-source: Argument 1 is not a procedure." {
+		t.Errorf("(procedure-source 5) = %s", got)
+	}
+}
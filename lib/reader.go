@@ -0,0 +1,602 @@
+package goscheme
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+The reader turns text into datums. It reads directly from the bufio.Reader of
+a port so that the same code is used for loading files, the REPL and read on
+ports, and so that reader macros can be given the port.
+Syntax errors are returned as an Error, and io.EOF is returned when the input
+ends between two datums.
+*/
+
+//reader holds the state needed while reading a single top level datum.
+type reader struct {
+	*bufio.Reader
+	//port is the port being read, which is what reader macros are given.
+	port Port
+	//env is the environment reader macros are called in, and table the
+	//readtable bound in it.
+	env   Environment
+	table Readtable
+	//labels maps datum labels to what they refer to. A label whose datum is
+	//still being read refers to a placeholder that is patched once it is done.
+	labels map[int]Expr
+}
+
+//placeholder stands in for a datum label that is used before its datum has
+//been read, as in #0=(a . #0#).
+type placeholder struct {
+	n int
+}
+
+func (p placeholder) isExpr() {}
+
+//isDelimiter reports whether r ends a symbol, number or character name.
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("()[]{}\";'`,|", r)
+}
+
+//readError converts errors from the underlying reader into Errors, keeping
+//io.EOF so callers can tell the end of input apart from a failure.
+func readError(err error) error {
+	if err == io.EOF {
+		return err
+	}
+	if _, ok := err.(Error); ok {
+		return err
+	}
+	return Error{err.Error()}
+}
+
+//skipAtmosphere skips whitespace and comments, leaving the first rune of the
+//next datum unread.
+func (r *reader) skipAtmosphere() error {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return readError(err)
+		}
+		switch b[0] {
+		case ';':
+			r.ReadString('\n')
+			continue
+		case '#':
+			b, _ = r.Peek(2)
+			if len(b) == 2 && b[1] == '|' {
+				r.Discard(2)
+				if err := skipBlockComment(r.Reader); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(b) == 2 && b[1] == ';' {
+				r.Discard(2)
+				if _, err := r.datum(); err != nil {
+					return err
+				}
+				continue
+			}
+			return nil
+		}
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return readError(err)
+		}
+		if !unicode.IsSpace(c) {
+			r.UnreadRune()
+			return nil
+		}
+	}
+}
+
+//skipBlockComment skips a (possibly nested) #| ... |# comment whose opening
+//has already been read.
+func skipBlockComment(r *bufio.Reader) error {
+	depth := 1
+	var last rune
+	for depth > 0 {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return Error{"Unterminated block comment."}
+		}
+		if last == '|' && c == '#' {
+			depth--
+			c = 0
+		} else if last == '#' && c == '|' {
+			depth++
+			c = 0
+		}
+		last = c
+	}
+	return nil
+}
+
+//readDatum reads the next datum from p, using the reader macros of the
+//readtable bound to current-readtable in env.
+func readDatum(p Port, env Environment) (Expr, error) {
+	return (&reader{p.r, p, env, currentReadtable(env), map[int]Expr{}}).datum()
+}
+
+//callMacro calls the reader macro m with the port being read and returns the
+//datum it reads.
+func (r *reader) callMacro(m Proc) (Expr, error) {
+	d := callProc(r.env, m, r.port)
+	if err, ok := d.(Error); ok {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *reader) datum() (Expr, error) {
+	if err := r.skipAtmosphere(); err != nil {
+		return nil, err
+	}
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return nil, readError(err)
+	}
+	if m, ok := r.table.macro(c, false); ok {
+		return r.callMacro(m)
+	}
+	switch c {
+	case '(', '[':
+		return r.list()
+	case ')', ']':
+		return nil, Error{"Unexpected ')'."}
+	case '}':
+		return nil, Error{"Unexpected '}'."}
+	case '\'':
+		return r.abbreviation("quote")
+	case '`':
+		return r.abbreviation("quasiquote")
+	case ',':
+		if b, _ := r.Peek(1); len(b) == 1 && b[0] == '@' {
+			r.Discard(1)
+			return r.abbreviation("unquote-splicing")
+		}
+		return r.abbreviation("unquote")
+	case '"':
+		return readString(r.Reader)
+	case '|':
+		return readBarSymbol(r.Reader)
+	case '#':
+		return r.hash()
+	}
+	r.UnreadRune()
+	return atom(r.token()), nil
+}
+
+//token reads runes up to the next delimiter, folding their case after
+//#!fold-case.
+func (r *reader) token() string {
+	t := readToken(r.Reader)
+	if r.port.foldCase {
+		return strings.Map(foldRune, t)
+	}
+	return t
+}
+
+//abbreviation reads the datum following ' ` , or ,@ and wraps it in a list
+//with the symbol the abbreviation stands for.
+func (r *reader) abbreviation(s string) (Expr, error) {
+	d, err := r.datum()
+	if err == io.EOF {
+		return nil, Error{"Unexpected EOF."}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return SliceToExprList([]Expr{Intern(s), d}), nil
+}
+
+//list reads the elements of a list whose opening parenthesis has already been
+//read. A dot in a dotted list is kept as the symbol ".", since that is what
+//lambda and syntax-rules look for.
+func (r *reader) list() (Expr, error) {
+	l := make([]Expr, 0)
+	for {
+		if err := r.skipAtmosphere(); err == io.EOF {
+			return nil, Error{"Missing ')'"}
+		} else if err != nil {
+			return nil, err
+		}
+		c, _, _ := r.ReadRune()
+		if c == ')' || c == ']' {
+			return SliceToExprList(l), nil
+		}
+		r.UnreadRune()
+		d, err := r.datum()
+		if err == io.EOF {
+			return nil, Error{"Missing ')'"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, d)
+	}
+}
+
+//readToken reads runes up to the next delimiter.
+func readToken(r *bufio.Reader) string {
+	var b strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			break
+		}
+		if isDelimiter(c) {
+			r.UnreadRune()
+			break
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+//readString reads a string literal whose opening quote has already been read.
+func readString(r *bufio.Reader) (Expr, error) {
+	var b strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return nil, Error{"Missing end quote"}
+		}
+		if c == '"' {
+			return NewString(b.String()), nil
+		}
+		if c != '\\' {
+			b.WriteRune(c)
+			continue
+		}
+		if err := readEscape(r, &b); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//readBarSymbol reads a |...| symbol whose opening bar has already been read.
+//It uses the same escapes as strings, and \| for a bar.
+func readBarSymbol(r *bufio.Reader) (Expr, error) {
+	var b strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return nil, Error{"Missing closing '|' of symbol."}
+		}
+		if c == '|' {
+			return Intern(b.String()), nil
+		}
+		if c != '\\' {
+			b.WriteRune(c)
+			continue
+		}
+		if err := readEscape(r, &b); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//readEscape reads an escape sequence in a string literal, after the
+//backslash has been read, and writes the character it stands for to b.
+func readEscape(r *bufio.Reader, b *strings.Builder) error {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return Error{"Missing end quote"}
+	}
+	switch c {
+	case 'a':
+		b.WriteRune('\a')
+	case 'b':
+		b.WriteRune('\b')
+	case 't':
+		b.WriteRune('\t')
+	case 'n':
+		b.WriteRune('\n')
+	case 'r':
+		b.WriteRune('\r')
+	case '0':
+		b.WriteRune(0)
+	case 'x', 'X':
+		//\xHH; is a character given by its hexadecimal code point.
+		hex, err := r.ReadString(';')
+		if err != nil {
+			return Error{"Missing ';' after hex escape in string."}
+		}
+		n, err := strconv.ParseUint(hex[:len(hex)-1], 16, 32)
+		if err != nil || n > unicode.MaxRune {
+			return Error{"Invalid hex escape in string."}
+		}
+		b.WriteRune(rune(n))
+	case '\n', ' ', '\t', '\r':
+		//A backslash at the end of a line skips the line break and the
+		//indentation of the next line.
+		for c != '\n' {
+			if c, _, err = r.ReadRune(); err != nil || !unicode.IsSpace(c) {
+				return Error{"Invalid escape in string."}
+			}
+		}
+		for {
+			if c, _, err = r.ReadRune(); err != nil {
+				break
+			}
+			if c != ' ' && c != '\t' {
+				r.UnreadRune()
+				break
+			}
+		}
+	default:
+		b.WriteRune(c)
+	}
+	return nil
+}
+
+//interpolate joins its arguments printed with display. It is bound to
+//%interpolate, which #"..." literals call.
+func interpolate(e Environment, args ...Expr) Expr {
+	var b strings.Builder
+	for _, x := range args {
+		if isError(x) {
+			return x
+		}
+		b.WriteString(displayString(x))
+	}
+	return NewString(b.String())
+}
+
+//interpolatedString reads a #"..." literal whose opening #" has already been
+//read. Each ${expression} in it is read as a datum, and the literal becomes a
+//call of %interpolate on the text and the expressions, so the expressions are
+//evaluated where the literal appears. %interpolate is reserved for this rather
+//than calling string-append, which the program could have rebound, and the
+//call can be written and read back. A literal without any expressions is just
+//a string. \$ is a literal dollar sign.
+func (r *reader) interpolatedString() (Expr, error) {
+	parts := []Expr{Intern("%interpolate")}
+	var b strings.Builder
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return nil, Error{"Missing end quote"}
+		}
+		switch c {
+		case '"':
+			if len(parts) == 1 {
+				return NewString(b.String()), nil
+			}
+			if b.Len() > 0 {
+				parts = append(parts, NewString(b.String()))
+			}
+			return SliceToExprList(parts), nil
+		case '\\':
+			if err := readEscape(r.Reader, &b); err != nil {
+				return nil, err
+			}
+		case '$':
+			if next, _ := r.Peek(1); len(next) == 0 || next[0] != '{' {
+				b.WriteRune(c)
+				continue
+			}
+			r.Discard(1)
+			if b.Len() > 0 {
+				parts = append(parts, NewString(b.String()))
+				b.Reset()
+			}
+			d, err := r.datum()
+			if err == io.EOF {
+				return nil, Error{"Missing '}' in interpolated string."}
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := r.skipAtmosphere(); err != nil && err != io.EOF {
+				return nil, err
+			}
+			if c, _, _ := r.ReadRune(); c != '}' {
+				return nil, Error{"Missing '}' in interpolated string."}
+			}
+			parts = append(parts, d)
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+//hash reads the syntax starting with #, after the # has been read.
+func (r *reader) hash() (Expr, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return nil, Error{"Unexpected EOF."}
+	}
+	if m, ok := r.table.macro(c, true); ok {
+		return r.callMacro(m)
+	}
+	switch c {
+	case '(':
+		l, err := r.list()
+		if err != nil {
+			return nil, err
+		}
+		return vector(Environment{}, ExprListToSlice(l.(ExprList))...), nil
+	case '"':
+		return r.interpolatedString()
+	case '\\':
+		//The first character is always part of the name, so that #\( and
+		//#\space both work.
+		first, _, err := r.ReadRune()
+		if err != nil {
+			return nil, Error{"Unexpected EOF."}
+		}
+		name := string(first) + readToken(r.Reader)
+		if r.port.foldCase && name != string(first) {
+			//Only names like #\SPACE are folded, not single characters.
+			name = strings.Map(foldRune, name)
+		}
+		return decodeCharacter(name), nil
+	case 'f', 's', 'u':
+		//Unread first, since UnreadRune does not work after Peek.
+		r.UnreadRune()
+		for _, k := range numKinds {
+			if b, _ := r.Peek(len(k.tag) + 1); string(b) == k.tag+"(" {
+				r.Discard(len(k.tag) + 1)
+				l, err := r.list()
+				if err != nil {
+					return nil, err
+				}
+				v := k.fromSlice("#"+k.tag, ExprListToSlice(l.(ExprList)))
+				if err, ok := v.(Error); ok {
+					return nil, err
+				}
+				return v, nil
+			}
+		}
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		r.UnreadRune()
+		return r.label()
+	case '!':
+		//#!fold-case and #!no-fold-case are comments that change how the rest
+		//of the port is read.
+		switch t := readToken(r.Reader); t {
+		case "fold-case", "no-fold-case":
+			r.port.foldCase = t == "fold-case"
+			return r.datum()
+		default:
+			return atom("#!" + t), nil
+		}
+	default:
+		r.UnreadRune()
+	}
+	t := r.token()
+	switch t {
+	case "t", "true":
+		return Boolean(true), nil
+	case "f", "false":
+		return Boolean(false), nil
+	}
+	return atom("#" + t), nil
+}
+
+//label reads a datum label definition #n= or reference #n#, after the # has
+//been read.
+func (r *reader) label() (Expr, error) {
+	n := 0
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return nil, Error{"Unexpected EOF."}
+		}
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			continue
+		case c == '#':
+			d, ok := r.labels[n]
+			if !ok {
+				return nil, Error{"Undefined datum label #" + strconv.Itoa(n) + "#."}
+			}
+			return d, nil
+		case c == '=':
+			ph := placeholder{n}
+			r.labels[n] = ph
+			d, err := r.datum()
+			if err == io.EOF {
+				return nil, Error{"Unexpected EOF."}
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := d.(placeholder); ok {
+				return nil, Error{"Datum label #" + strconv.Itoa(n) + "= refers to itself."}
+			}
+			r.labels[n] = d
+			patchLabel(d, ph, d, map[*Expr]bool{})
+			return d, nil
+		}
+		return nil, Error{"Invalid datum label."}
+	}
+}
+
+//patchLabel replaces the placeholder ph inside x with v. A placeholder after
+//the dot of a dotted list makes the list continue with v, which is how
+//cyclic lists are written.
+func patchLabel(x Expr, ph placeholder, v Expr, seen map[*Expr]bool) {
+	switch l := x.(type) {
+	case ExprList:
+		var prev *ExprList
+		for cell := &l; cell != nil && cell.car != nil; prev, cell = cell, cell.cdr {
+			if seen[cell.car] {
+				return
+			}
+			seen[cell.car] = true
+			if p, ok := (*cell.car).(placeholder); ok && p == ph {
+				*cell.car = v
+				continue
+			}
+			if tail, ok := v.(ExprList); ok && prev != nil && *cell.car == Intern(".") &&
+				cell.cdr != nil && cell.cdr.car != nil && (cell.cdr.cdr == nil || cell.cdr.cdr.car == nil) {
+				if p, ok := (*cell.cdr.car).(placeholder); ok && p == ph {
+					*prev.cdr = tail
+					return
+				}
+			}
+			patchLabel(*cell.car, ph, v, seen)
+		}
+	case Vector:
+		if len(l) == 0 || seen[&l[0]] {
+			return
+		}
+		seen[&l[0]] = true
+		for i := range l {
+			if p, ok := l[i].(placeholder); ok && p == ph {
+				l[i] = v
+			} else {
+				patchLabel(l[i], ph, v, seen)
+			}
+		}
+	}
+}
+
+//ParseString reads every datum in s, using the readtable of GlobalEnv. A syntax
+//error is returned as an Error in place of the datum that could not be read,
+//and ends the parsing.
+func ParseString(s string) []Expr {
+	p := newInputPort(strings.NewReader(s), nopCloser{})
+	ret := make([]Expr, 0)
+	for {
+		d, err := readDatum(p, GlobalEnv)
+		if err == io.EOF {
+			return ret
+		}
+		if err != nil {
+			return append(ret, err.(Error))
+		}
+		ret = append(ret, d)
+	}
+}
+
+//evalReader evaluates every datum read from p in env, printing the results
+//if print is true. It stops at the first error and returns it.
+func evalReader(p Port, env Environment, print bool) Expr {
+	for {
+		d, err := readDatum(p, env)
+		if err == io.EOF {
+			return Boolean(true)
+		}
+		if err != nil {
+			return err.(Error)
+		}
+		res := Eval(d, env)
+		if isError(res) {
+			return res
+		}
+		if s, ok := res.(Symbol); print && (!ok || s.name != "") {
+			Println(Sprint(res))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package goscheme
+
+import (
+	"strings"
+	"testing"
+)
+
+//readOne reads a single datum from src.
+func readOne(t *testing.T, src string) (Expr, error) {
+	t.Helper()
+	return readDatum(newInputPort(strings.NewReader(src), nopCloser{}), testEnv(t))
+}
+
+//Labelled data is written the way it was read, by write when it is cyclic and
+//by write-shared when it is only shared.
+func TestDatumLabels(t *testing.T) {
+	tests := []struct {
+		src, write, shared string
+	}{
+		{"#0=(a b . #0#)", "#0=(a b . #0#)", "#0=(a b . #0#)"},
+		{"#0=#(1 #0#)", "#0=#(1 #0#)", "#0=#(1 #0#)"},
+		{"#0=(#0# . 2)", "#0=(#0# . 2)", "#0=(#0# . 2)"},
+		{"(#1=(x) #1#)", "((x) (x))", "(#0=(x) #0#)"},
+		{"(#0=a #0=b)", "(a b)", "(a b)"},
+	}
+	for _, test := range tests {
+		d, err := readOne(t, test.src)
+		if err != nil {
+			t.Fatalf("%s: %v", test.src, err)
+		}
+		if got := Sprint(d); got != test.write {
+			t.Errorf("%s is written as %s, want %s", test.src, got, test.write)
+		}
+		if got := sharedString(d); got != test.shared {
+			t.Errorf("%s is written by write-shared as %s, want %s", test.src, got, test.shared)
+		}
+		again, err := readOne(t, sharedString(d))
+		if err != nil || sharedString(again) != test.shared {
+			t.Errorf("%s is read back as %s, %v", test.shared, sharedString(again), err)
+		}
+	}
+	for src, want := range map[string]string{"#5#": "Undefined datum label #5#.", "#0=#0#": "Datum label #0= refers to itself."} {
+		if _, err := readOne(t, src); err == nil || err.Error() != want {
+			t.Errorf("Reading %s gives the error %v, want %s", src, err, want)
+		}
+	}
+}
+
+//A cycle made by mutation is found by write, which would otherwise not end.
+func TestWriteCycle(t *testing.T) {
+	got := evalString(t, "(define cyclic (vector 1 2)) (vector-set! cyclic 1 cyclic) (list cyclic cyclic)")
+	if s := Sprint(got); s != "(#0=#(1 #0#) #0#)" {
+		t.Errorf("The cyclic vector is written as %s", s)
+	}
+}
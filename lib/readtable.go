@@ -0,0 +1,160 @@
+package goscheme
+
+import (
+	"sync"
+)
+
+/*
+Readtable type
+A readtable holds the reader macros added from Scheme. A reader macro is a
+procedure that the reader calls with the input port when it finds the macro's
+character at the start of a datum, after the character has been read. What
+the procedure returns is the datum. A dispatch macro is the same for a
+character following #, so (define-dispatch-macro #\d proc) makes the reader
+call proc for #d... Dispatch macros take precedence over the built in #
+syntax.
+The reader uses the readtable bound to current-readtable. load binds it to a
+copy of the caller's readtable while the file is read, so macros defined in a
+file only apply to the rest of that file.
+*/
+type Readtable struct {
+	*readtable
+}
+
+type readtable struct {
+	mu       sync.RWMutex
+	macros   map[rune]Proc
+	dispatch map[rune]Proc
+}
+
+func (t Readtable) isExpr() {}
+func (t Readtable) String() string {
+	return "#<readtable>"
+}
+
+func newReadtable() Readtable {
+	return Readtable{&readtable{macros: map[rune]Proc{}, dispatch: map[rune]Proc{}}}
+}
+
+//copy returns a new readtable with the same macros as t.
+func (t Readtable) copy() Readtable {
+	c := newReadtable()
+	if t.readtable == nil {
+		return c
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for k, v := range t.macros {
+		c.macros[k] = v
+	}
+	for k, v := range t.dispatch {
+		c.dispatch[k] = v
+	}
+	return c
+}
+
+//macro returns the reader macro for c, or the dispatch macro for #c if
+//dispatch is true.
+func (t Readtable) macro(c rune, dispatch bool) (Proc, bool) {
+	if t.readtable == nil {
+		return nil, false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var p Proc
+	if dispatch {
+		p = t.dispatch[c]
+	} else {
+		p = t.macros[c]
+	}
+	return p, p != nil
+}
+
+//currentReadtable returns the readtable bound to current-readtable in e, or
+//an empty one if there is none.
+func currentReadtable(e Environment) Readtable {
+	if scope := e.find("current-readtable"); scope != nil {
+		if t, ok := scope["current-readtable"].(Readtable); ok {
+			return t
+		}
+	}
+	return Readtable{}
+}
+
+//withReadtableScope binds current-readtable in e to a copy of itself while f
+//runs, so that macros defined by f do not outlive it.
+func withReadtableScope(e Environment, f func() Expr) Expr {
+	scope := e.find("current-readtable")
+	if scope == nil {
+		return f()
+	}
+	old := scope["current-readtable"]
+	t, _ := old.(Readtable)
+	scope["current-readtable"] = t.copy()
+	defer func() {
+		scope["current-readtable"] = old
+	}()
+	return f()
+}
+
+//defineMacro is define-reader-macro and define-dispatch-macro.
+func defineMacro(e Environment, name string, args []Expr, dispatch bool) Expr {
+	c, ok := args[0].(Character)
+	if !ok {
+		return Error{name + ": Argument 1 is not a character."}
+	}
+	if isDelimiter(rune(c)) || (!dispatch && c == '#') {
+		return Error{name + ": " + Sprint(c) + " can not be used for a reader macro."}
+	}
+	p, ok := args[1].(Proc)
+	if !ok {
+		return Error{name + ": Argument 2 is not a procedure."}
+	}
+	var t Readtable
+	if len(args) > 2 {
+		if t, ok = args[2].(Readtable); !ok {
+			return Error{name + ": Argument 3 is not a readtable."}
+		}
+	} else if t = currentReadtable(e); t.readtable == nil {
+		return Error{name + ": current-readtable is not a readtable."}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if dispatch {
+		t.dispatch[rune(c)] = p
+	} else {
+		t.macros[rune(c)] = p
+	}
+	return Boolean(true)
+}
+
+//(define-reader-macro char proc [readtable]) makes the reader call proc with
+//the input port when a datum starts with char. The readtable defaults to
+//current-readtable.
+func definereadermacro(e Environment, args ...Expr) Expr {
+	return defineMacro(e, "define-reader-macro", args, false)
+}
+
+//(define-dispatch-macro char proc [readtable]) makes the reader call proc
+//with the input port when it reads # followed by char.
+func definedispatchmacro(e Environment, args ...Expr) Expr {
+	return defineMacro(e, "define-dispatch-macro", args, true)
+}
+
+//(make-readtable [readtable]) returns a new readtable with the macros of
+//readtable, or without any macros.
+func makereadtable(e Environment, args ...Expr) Expr {
+	if len(args) == 0 {
+		return newReadtable()
+	}
+	t, ok := args[0].(Readtable)
+	if !ok {
+		return Error{"make-readtable: Argument 1 is not a readtable."}
+	}
+	return t.copy()
+}
+
+func readtable_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(Readtable)
+	return Boolean(ok)
+}
@@ -0,0 +1,70 @@
+package goscheme
+
+import (
+	"strings"
+	"testing"
+)
+
+//A reader macro is called with the port after its character has been read,
+//and a dispatch macro after # and its character.
+func TestReaderMacros(t *testing.T) {
+	env := testEnv(t)
+	rt := newReadtable()
+	scope := Environment{map[string]Expr{"current-readtable": rt}, map[Symbol]transformer{}, &env, false}
+	for _, src := range []string{
+		`(define-reader-macro #\! (lambda (port) (list 'not (read port))))`,
+		`(define-dispatch-macro #\d (lambda (port) (* 2 (read port))))`,
+	} {
+		d, err := readOne(t, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := Eval(d, scope); r != Expr(Boolean(true)) {
+			t.Fatalf("%s = %s", src, Sprint(r))
+		}
+	}
+	p := newInputPort(strings.NewReader("(a !b #d21 #t)"), nopCloser{})
+	d, err := readDatum(p, scope)
+	if got := Sprint(d); err != nil || got != "(a (not b) 42 #t)" {
+		t.Errorf("The macros read the list as %s, %v", got, err)
+	}
+	//The readtable of the test environment is not changed.
+	if d, err := readOne(t, "!b"); err != nil || d != Expr(Intern("!b")) {
+		t.Errorf("!b is read as %s, %v without the macro", Sprint(d), err)
+	}
+	for src, want := range map[string]string{
+		`(define-reader-macro #\( (lambda (port) 1))`:      `define-reader-macro: #\( can not be used for a reader macro.`,
+		`(define-reader-macro #\# (lambda (port) 1))`:      `define-reader-macro: #\# can not be used for a reader macro.`,
+		`(define-dispatch-macro #\d 5)`:                    "define-dispatch-macro: Argument 2 is not a procedure.",
+		`(define-reader-macro #\! (lambda (port) 1) 'tbl)`: "define-reader-macro: Argument 3 is not a readtable.",
+	} {
+		if got := Sprint(evalString(t, src)); got != want {
+			t.Errorf("%s = %s, want %s", src, got, want)
+		}
+	}
+}
+
+//Macros defined in a loaded file apply to the rest of that file only.
+func TestReadtableScope(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"macros.scm": `(define-reader-macro #\~ (lambda (port) (list 'quote (list 'tilde (read port)))))
+(define tilde-in-file ~x)`,
+	})
+	evalString(t, `(load "`+dir+`/macros.scm")`)
+	if got := Sprint(evalString(t, "tilde-in-file")); got != "(tilde x)" {
+		t.Errorf("The macro in the file gives %s", got)
+	}
+	if d, err := readOne(t, "~x"); err != nil || d != Expr(Intern("~x")) {
+		t.Errorf("The macro defined in the file is still used after loading it: %s, %v", Sprint(d), err)
+	}
+	//make-readtable copies the macros of the readtable it is given.
+	got := evalString(t, `(define copied-table (make-readtable))
+(define-reader-macro #\~ (lambda (port) 'tilde) copied-table)
+(readtable? (make-readtable copied-table))`)
+	if got != Expr(Boolean(true)) {
+		t.Errorf("make-readtable does not return a readtable: %s", Sprint(got))
+	}
+	if m, ok := evalString(t, "(make-readtable copied-table)").(Readtable).macro('~', false); !ok || m == nil {
+		t.Errorf("A copy of a readtable does not have its macros.")
+	}
+}
@@ -0,0 +1,511 @@
+package goscheme
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Regular expressions, backed by Go's regexp package, so patterns use RE2
+syntax and matching takes linear time. Wherever a regexp is expected a
+pattern can be given instead and is compiled on the spot. A pattern is either
+a string in RE2 syntax or an SRE, the s-expression syntax of SRFI 115, which
+is translated to RE2 syntax.
+Positions are indexes of characters in the string, not of bytes.
+A match is returned as a list of the whole match followed by the submatches,
+with #f for submatches that did not take part in the match.
+*/
+type Regexp struct {
+	re *regexp.Regexp
+}
+
+func (r Regexp) isExpr() {}
+
+func (r Regexp) String() string {
+	return "#<regexp " + strconv.Quote(r.re.String()) + ">"
+}
+
+//compileRegexp compiles a string in RE2 syntax or an SRE.
+func compileRegexp(name string, x Expr) (Regexp, Expr) {
+	if r, ok := x.(Regexp); ok {
+		return r, nil
+	}
+	var src string
+	if s, ok := x.(String); ok {
+		src = string(*s.runes)
+	} else {
+		var err Expr
+		if src, err = sreToRE2(x); err != nil {
+			return Regexp{}, Error{name + ": " + err.(Error).s}
+		}
+	}
+	re, cerr := regexp.Compile(src)
+	if cerr != nil {
+		return Regexp{}, Error{name + ": " + cerr.Error()}
+	}
+	return Regexp{re}, nil
+}
+
+//sreToRE2 translates an SRE to RE2 syntax. The result can always be
+//concatenated with other patterns, but may need a group to be repeated.
+func sreToRE2(x Expr) (string, Expr) {
+	switch v := x.(type) {
+	case String:
+		return regexp.QuoteMeta(string(*v.runes)), nil
+	case Character:
+		return regexp.QuoteMeta(string(rune(v))), nil
+	case CharSet:
+		return charSetClass(v), nil
+	case Symbol:
+		if s, ok := sreSymbols[v.name]; ok {
+			return s, nil
+		}
+		if _, ok := sreCharSets[v.name]; ok {
+			cs, err := sreCharSet(v)
+			return charSetClass(cs), err
+		}
+		return "", Error{"Unknown SRE " + v.name + "."}
+	case ExprList:
+		l := ExprListToSlice(v)
+		if len(l) == 0 {
+			return "", Error{"Empty SRE."}
+		}
+		sym, ok := l[0].(Symbol)
+		if !ok {
+			//("abc") is the set of the characters in the string.
+			cs, err := sreCharSet(v)
+			return charSetClass(cs), err
+		}
+		op, args := sym.name, l[1:]
+		switch op {
+		case ":", "seq":
+			return sreSeq(args)
+		case "or":
+			alts := make([]string, len(args))
+			for i, a := range args {
+				s, err := sreToRE2(a)
+				if err != nil {
+					return "", err
+				}
+				alts[i] = s
+			}
+			if len(alts) == 0 {
+				//An empty or never matches.
+				return `[^\x00-\x{10FFFF}]`, nil
+			}
+			return "(?:" + strings.Join(alts, "|") + ")", nil
+		case "*", "+", "?", "*?", "+?", "??":
+			s, err := sreSeq(args)
+			if err != nil {
+				return "", err
+			}
+			return "(?:" + s + ")" + op, nil
+		case "=", ">=", "**", "**?":
+			return sreRepeat(op, args)
+		case "$", "submatch":
+			s, err := sreSeq(args)
+			return "(" + s + ")", err
+		case "->", "submatch-named":
+			if len(args) == 0 {
+				return "", Error{"Missing name in " + Sprint(v) + "."}
+			}
+			n, ok := args[0].(Symbol)
+			if !ok {
+				return "", Error{"The name in " + Sprint(v) + " is not a symbol."}
+			}
+			s, err := sreSeq(args[1:])
+			return "(?P<" + n.name + ">" + s + ")", err
+		case "w/nocase":
+			s, err := sreSeq(args)
+			return "(?i:" + s + ")", err
+		case "w/case":
+			s, err := sreSeq(args)
+			return "(?-i:" + s + ")", err
+		case "w/ascii", "w/unicode":
+			return sreSeq(args)
+		case "look-ahead", "look-behind", "neg-look-ahead", "neg-look-behind", "backref":
+			return "", Error{op + " is not supported by RE2."}
+		}
+		cs, err := sreCharSet(v)
+		return charSetClass(cs), err
+	}
+	return "", Error{Sprint(x) + " is not a valid SRE."}
+}
+
+//sreSeq translates a sequence of SREs.
+func sreSeq(l []Expr) (string, Expr) {
+	var b strings.Builder
+	for _, x := range l {
+		s, err := sreToRE2(x)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+//sreRepeat translates (= n sre ...), (>= n sre ...), (** n m sre ...) and
+//(**? n m sre ...).
+func sreRepeat(op string, args []Expr) (string, Expr) {
+	counts := 1
+	if op == "**" || op == "**?" {
+		counts = 2
+	}
+	if len(args) < counts {
+		return "", Error{"Missing count in " + op + "."}
+	}
+	ns := make([]string, counts)
+	for i := range ns {
+		n, ok := args[i].(Number)
+		if !ok || float64(n) != float64(int(n)) || n < 0 {
+			return "", Error{"The count in " + op + " is not a non-negative integer."}
+		}
+		ns[i] = strconv.Itoa(int(n))
+	}
+	s, err := sreSeq(args[counts:])
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case "=":
+		return "(?:" + s + "){" + ns[0] + "}", nil
+	case ">=":
+		return "(?:" + s + "){" + ns[0] + ",}", nil
+	case "**?":
+		return "(?:" + s + "){" + ns[0] + "," + ns[1] + "}?", nil
+	}
+	return "(?:" + s + "){" + ns[0] + "," + ns[1] + "}", nil
+}
+
+//sreSymbols are the SRE symbols that are not character sets.
+var sreSymbols = map[string]string{
+	"any":  `(?s:.)`,
+	"nonl": `[^\n]`,
+	"bos":  `\A`,
+	"eos":  `\z`,
+	"bol":  `(?m:^)`,
+	"eol":  `(?m:$)`,
+	"bow":  `\b`,
+	"eow":  `\b`,
+	"nwb":  `\B`,
+	"word": `\b\w+\b`,
+}
+
+//sreCharSets maps the SRE names of character sets to the standard char-sets.
+var sreCharSets = map[string]string{
+	"alpha":        "letter",
+	"alphabetic":   "letter",
+	"alnum":        "letter+digit",
+	"alphanumeric": "letter+digit",
+	"num":          "digit",
+	"numeric":      "digit",
+	"digit":        "digit",
+	"space":        "whitespace",
+	"white":        "whitespace",
+	"whitespace":   "whitespace",
+	"upper":        "upper-case",
+	"upper-case":   "upper-case",
+	"lower":        "lower-case",
+	"lower-case":   "lower-case",
+	"punct":        "punctuation",
+	"punctuation":  "punctuation",
+	"symbol":       "symbol",
+	"graph":        "graphic",
+	"graphic":      "graphic",
+	"print":        "printing",
+	"printing":     "printing",
+	"cntrl":        "iso-control",
+	"control":      "iso-control",
+	"xdigit":       "hex-digit",
+	"hex-digit":    "hex-digit",
+	"ascii":        "ascii",
+	"blank":        "blank",
+}
+
+//sreCharSet evaluates an SRE that denotes a set of characters.
+func sreCharSet(x Expr) (CharSet, Expr) {
+	switch v := x.(type) {
+	case Character:
+		return newCharSet([]runeRange{{rune(v), rune(v)}}), nil
+	case CharSet:
+		return v, nil
+	case String:
+		if len(*v.runes) == 1 {
+			r := (*v.runes)[0]
+			return newCharSet([]runeRange{{r, r}}), nil
+		}
+	case Symbol:
+		if name, ok := sreCharSets[v.name]; ok {
+			return standardCharSets[name], nil
+		}
+	case ExprList:
+		l := ExprListToSlice(v)
+		if len(l) == 1 {
+			if s, ok := l[0].(String); ok {
+				return stringCharSet(*s.runes), nil
+			}
+		}
+		if len(l) == 0 {
+			break
+		}
+		var op string
+		if sym, ok := l[0].(Symbol); ok {
+			op = sym.name
+		}
+		switch op {
+		case "char-set":
+			if len(l) == 2 {
+				if s, ok := l[1].(String); ok {
+					return stringCharSet(*s.runes), nil
+				}
+			}
+		case "/", "char-range":
+			var rs []rune
+			for _, y := range l[1:] {
+				switch r := y.(type) {
+				case String:
+					rs = append(rs, *r.runes...)
+				case Character:
+					rs = append(rs, rune(r))
+				default:
+					return CharSet{}, Error{Sprint(v) + " is not a valid character range."}
+				}
+			}
+			if len(rs)%2 != 0 {
+				return CharSet{}, Error{Sprint(v) + " has an odd number of characters."}
+			}
+			ranges := make([]runeRange, 0, len(rs)/2)
+			for i := 0; i < len(rs); i += 2 {
+				ranges = append(ranges, runeRange{rs[i], rs[i+1]})
+			}
+			return newCharSet(ranges), nil
+		case "or", "~", "complement", "-", "difference", "&", "and":
+			sets := make([]CharSet, len(l)-1)
+			for i, y := range l[1:] {
+				cs, err := sreCharSet(y)
+				if err != nil {
+					return CharSet{}, err
+				}
+				sets[i] = cs
+			}
+			ret := standardCharSets["empty"]
+			if op == "&" || op == "and" {
+				ret = standardCharSets["full"]
+			}
+			for i, cs := range sets {
+				switch {
+				case (op == "-" || op == "difference") && i > 0:
+					ret = ret.difference(cs)
+				case op == "&" || op == "and":
+					ret = ret.intersection(cs)
+				default:
+					ret = ret.union(cs)
+				}
+			}
+			if op == "~" || op == "complement" {
+				ret = ret.complement()
+			}
+			return ret, nil
+		}
+	}
+	return CharSet{}, Error{Sprint(x) + " is not a valid SRE."}
+}
+
+func stringCharSet(s []rune) CharSet {
+	rs := make([]runeRange, len(s))
+	for i, r := range s {
+		rs[i] = runeRange{r, r}
+	}
+	return newCharSet(rs)
+}
+
+//charSetClass returns an RE2 character class matching the runes in cs.
+func charSetClass(cs CharSet) string {
+	if len(*cs.ranges) == 0 {
+		return `[^\x00-\x{10FFFF}]`
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	for _, r := range *cs.ranges {
+		fmt.Fprintf(&b, `\x{%x}`, r.lo)
+		if r.hi != r.lo {
+			fmt.Fprintf(&b, `-\x{%x}`, r.hi)
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+//regexpArgs reads a regexp or pattern from args[0] and a string from args[1],
+//with optional start and end arguments from args[i] on. It returns the part
+//of the string to search and its offset in the whole string.
+func regexpArgs(name string, args []Expr, i int) (Regexp, string, int, Expr) {
+	re, err := compileRegexp(name, args[0])
+	if err != nil {
+		return Regexp{}, "", 0, err
+	}
+	s, ok := args[1].(String)
+	if !ok {
+		return Regexp{}, "", 0, Error{name + ": Argument 2 is not a string."}
+	}
+	start, end, err := rangeArgs(name, args, i, len(*s.runes))
+	if err != nil {
+		return Regexp{}, "", 0, err
+	}
+	return re, string((*s.runes)[start:end]), start, nil
+}
+
+//runeIndex converts the byte offset b in s to a character offset.
+func runeIndex(s string, b int) int {
+	return utf8.RuneCountInString(s[:b])
+}
+
+//submatches returns the match given by the byte offsets in loc as a list of
+//strings.
+func submatches(s string, loc []int) Expr {
+	ret := make([]Expr, len(loc)/2)
+	for i := range ret {
+		if loc[2*i] < 0 {
+			ret[i] = Boolean(false)
+		} else {
+			ret[i] = NewString(s[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return SliceToExprList(ret)
+}
+
+//Compiles a pattern, either a string in RE2 syntax or an SRE, to a regexp.
+func makeregexp(e Environment, args ...Expr) Expr {
+	re, err := compileRegexp("regexp", args[0])
+	if err != nil {
+		return err
+	}
+	return re
+}
+
+func regexp_(e Environment, args ...Expr) Expr {
+	_, ok := args[0].(Regexp)
+	return Boolean(ok)
+}
+
+//(regexp-match re string [start [end]]) returns the first match of re in the
+//string, or #f.
+func regexpmatch(e Environment, args ...Expr) Expr {
+	re, s, _, err := regexpArgs("regexp-match", args, 2)
+	if err != nil {
+		return err
+	}
+	loc := re.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Boolean(false)
+	}
+	return submatches(s, loc)
+}
+
+//(regexp-match-positions re string [start [end]]) returns the start and end
+//positions of the first match and its submatches as lists, or #f.
+func regexpmatchpositions(e Environment, args ...Expr) Expr {
+	re, s, offset, err := regexpArgs("regexp-match-positions", args, 2)
+	if err != nil {
+		return err
+	}
+	loc := re.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Boolean(false)
+	}
+	ret := make([]Expr, len(loc)/2)
+	for i := range ret {
+		if loc[2*i] < 0 {
+			ret[i] = Boolean(false)
+			continue
+		}
+		start, end := runeIndex(s, loc[2*i]), runeIndex(s, loc[2*i+1])
+		ret[i] = SliceToExprList([]Expr{Number(offset + start), Number(offset + end)})
+	}
+	return SliceToExprList(ret)
+}
+
+//(regexp-search-all re string [start [end]]) returns a list of all
+//non-overlapping matches.
+func regexpsearchall(e Environment, args ...Expr) Expr {
+	re, s, _, err := regexpArgs("regexp-search-all", args, 2)
+	if err != nil {
+		return err
+	}
+	locs := re.re.FindAllStringSubmatchIndex(s, -1)
+	ret := make([]Expr, len(locs))
+	for i, loc := range locs {
+		ret[i] = submatches(s, loc)
+	}
+	return SliceToExprList(ret)
+}
+
+//replaceMatches replaces the first n matches of re in s, or all of them if n
+//is negative. A string replacement can refer to submatches as $1 or ${name},
+//like Regexp.Expand. A procedure replacement is called with the match and its
+//submatches, and must return a string.
+func replaceMatches(e Environment, name string, args []Expr, n int) Expr {
+	re, s, _, err := regexpArgs(name, args[:2], 2)
+	if err != nil {
+		return err
+	}
+	var template []byte
+	var proc Proc
+	switch v := args[2].(type) {
+	case String:
+		template = []byte(string(*v.runes))
+	case Proc:
+		proc = v
+	default:
+		return Error{name + ": Argument 3 is not a string or a procedure."}
+	}
+	var b []byte
+	last := 0
+	for _, loc := range re.re.FindAllStringSubmatchIndex(s, n) {
+		b = append(b, s[last:loc[0]]...)
+		last = loc[1]
+		if proc == nil {
+			b = re.re.ExpandString(b, string(template), s, loc)
+			continue
+		}
+		r := callProc(e, proc, ExprListToSlice(submatches(s, loc).(ExprList))...)
+		rs, ok := r.(String)
+		if !ok {
+			if isError(r) {
+				return r
+			}
+			return Error{name + ": The replacement procedure returned " + Sprint(r) + ", which is not a string."}
+		}
+		b = append(b, string(*rs.runes)...)
+	}
+	return NewString(string(append(b, s[last:]...)))
+}
+
+//(regexp-replace re string replacement) replaces the first match.
+func regexpreplace(e Environment, args ...Expr) Expr {
+	return replaceMatches(e, "regexp-replace", args, 1)
+}
+
+//(regexp-replace-all re string replacement)
+func regexpreplaceall(e Environment, args ...Expr) Expr {
+	return replaceMatches(e, "regexp-replace-all", args, -1)
+}
+
+//(regexp-split re string) returns the list of the parts of the string between
+//matches.
+func regexpsplit(e Environment, args ...Expr) Expr {
+	re, s, _, err := regexpArgs("regexp-split", args, 2)
+	if err != nil {
+		return err
+	}
+	parts := re.re.Split(s, -1)
+	ret := make([]Expr, len(parts))
+	for i, p := range parts {
+		ret[i] = NewString(p)
+	}
+	return SliceToExprList(ret)
+}
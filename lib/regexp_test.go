@@ -0,0 +1,105 @@
+package goscheme
+
+import (
+	"regexp"
+	"testing"
+)
+
+//The operators of an SRE are translated to the RE2 syntax for them, and the
+//result can be repeated and concatenated.
+func TestSREToRE2(t *testing.T) {
+	tests := []struct {
+		sre, re2 string
+	}{
+		{`"a.b"`, `a\.b`},
+		{`(: "a" (or "b" "cd"))`, `a(?:b|cd)`},
+		{`(* "ab")`, `(?:ab)*`},
+		{`(+? #\x)`, `(?:x)+?`},
+		{`(= 3 "a")`, `(?:a){3}`},
+		{`(>= 2 "a")`, `(?:a){2,}`},
+		{`(** 1 2 "a" "b")`, `(?:ab){1,2}`},
+		{`($ "a")`, `(a)`},
+		{`(-> year (= 4 "0"))`, `(?P<year>(?:0){4})`},
+		{`(w/nocase "a")`, `(?i:a)`},
+		{`(: bos nonl eos)`, `\A[^\n]\z`},
+	}
+	for _, test := range tests {
+		d, err := readOne(t, test.sre)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, serr := sreToRE2(d)
+		if serr != nil || got != test.re2 {
+			t.Errorf("%s is translated to %q, %v, want %q", test.sre, got, serr, test.re2)
+		}
+	}
+}
+
+//Character set SREs match the same characters as the char-sets they name.
+func TestSRECharSets(t *testing.T) {
+	tests := []struct {
+		sre           string
+		match, reject string
+	}{
+		{"alpha", "aλZ", "1 _"},
+		{"digit", "09٤", "a"},
+		{`("abc")`, "abc", "d"},
+		{`(/ "az")`, "amz", "A{"},
+		{`(or alpha "_")`, "a_", "-"},
+		{`(~ digit)`, "a ", "5"},
+		{`(- alpha ("aeiou"))`, "bz", "ae"},
+		{`(& alpha ("a1"))`, "a", "1b"},
+	}
+	for _, test := range tests {
+		d, err := readOne(t, test.sre)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, serr := sreToRE2(d)
+		if serr != nil {
+			t.Fatalf("%s: %s", test.sre, Sprint(serr))
+		}
+		re := regexp.MustCompile(`\A(?:` + s + `)\z`)
+		for _, r := range test.match {
+			if !re.MatchString(string(r)) {
+				t.Errorf("%s does not match %q", test.sre, r)
+			}
+		}
+		for _, r := range test.reject {
+			if re.MatchString(string(r)) {
+				t.Errorf("%s matches %q", test.sre, r)
+			}
+		}
+	}
+	for src, want := range map[string]string{
+		"(look-ahead \"x\")": "look-ahead is not supported by RE2.",
+		"(= a \"x\")":        "The count in = is not a non-negative integer.",
+		"bogus":              "Unknown SRE bogus.",
+	} {
+		d, _ := readOne(t, src)
+		if _, err := sreToRE2(d); Sprint(err) != want {
+			t.Errorf("%s gives the error %s, want %s", src, Sprint(err), want)
+		}
+	}
+}
+
+//A replacement procedure is called with the match and every submatch, #f for
+//the ones that did not match.
+func TestReplaceWithProcedure(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(regexp-replace-all "(a)(x)?" "aab" (lambda (m a x) (string-append "<" m (if x x "-") ">")))`, `"<a-><a->b"`},
+		{`(regexp-replace-all '(+ digit) "a1b22" (lambda (m) (number->string (* 2 (string->number m)))))`, `"a2b44"`},
+		{`(regexp-replace "a" "aa" (lambda (m) "b"))`, `"ba"`},
+		{`(regexp-replace-all "a" "aa" (lambda (m) 5))`, "regexp-replace-all: The replacement procedure returned 5, which is not a string."},
+		{`(regexp-replace-all "a" "aa" (lambda (m) (car 5)))`, "car: Argument 1 is not a list."},
+		{`(regexp-replace-all "a" "aa" 5)`, "regexp-replace-all: Argument 3 is not a string or a procedure."},
+		{`(regexp-replace "(?P<w>b+)" "abbc" "[${w}]")`, `"a[bb]c"`},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package goscheme
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var standardEnv sync.Once
+
+//testEnv returns GlobalEnv, made with StandardEnv the first time. StandardEnv
+//reads the std directory relative to the working directory, which is lib when
+//testing.
+func testEnv(t *testing.T) Environment {
+	t.Helper()
+	standardEnv.Do(func() {
+		if err := os.Chdir(".."); err != nil {
+			t.Fatal(err)
+		}
+		GlobalEnv = StandardEnv()
+		GlobalEnv.Local["load-verbose"] = Boolean(false)
+	})
+	return GlobalEnv
+}
+
+//evalString evaluates the expressions in src in GlobalEnv and returns the
+//value of the last one.
+func evalString(t *testing.T, src string) Expr {
+	t.Helper()
+	env := testEnv(t)
+	p := newInputPort(strings.NewReader(src), nopCloser{})
+	var r Expr
+	for {
+		d, err := readDatum(p, env)
+		if err == io.EOF {
+			return r
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		r = Eval(d, env)
+	}
+}
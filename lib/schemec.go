@@ -27,6 +27,10 @@ import (
 
 var GlobalEnv Environment
 
+//Tokenize splits s into the tokens read by Parse.
+//
+//Deprecated: Tokenize only knows lists, quotes and strings. Use ParseString,
+//which reads all of the syntax that load and read do.
 func Tokenize(s string) []string {
 	ignoreString := "\t\n\r"
 
@@ -88,6 +92,10 @@ func Tokenize(s string) []string {
 	return r
 }
 
+//Parse reads one expression from the tokens in s, removing them from s. If
+//allowblock is true a quoted datum is returned as an EvalBlock.
+//
+//Deprecated: Use ParseString.
 func Parse(s *[]string, allowblock bool) Expr {
 	if len(*s) == 0 {
 		return Error{"Unexpected EOF."}
@@ -113,7 +121,7 @@ func Parse(s *[]string, allowblock bool) Expr {
 		if t[len(t)-1] != '"' {
 			return Error{"Missing end quote"}
 		}
-		return String(t[1 : len(t)-1])
+		return NewString(t[1 : len(t)-1])
 	}
 	if t == "(" {
 		l := make([]Expr, 0)
@@ -133,7 +141,7 @@ func Parse(s *[]string, allowblock bool) Expr {
 
 func Eval(e Expr, env Environment) Expr {
 	if bool(symbol_(env, e).(Boolean)) {
-		return env.find(unwrapSymbol(e))[unwrapSymbol(e)]
+		return env.get(unwrapSymbol(e))
 	} else if eb, ok := e.(EvalBlock); ok {
 		return eb.e
 	} else if v, ok := e.(String); ok {
@@ -207,6 +215,10 @@ func Eval(e Expr, env Environment) Expr {
 				return Error{"define: Must be of form '(define <variable> <expression>)'"}
 			}
 			er := Eval(el[2], env)
+			if u, ok := er.(UserProc); ok && u.name == "" {
+				u.name = unwrapSymbol(el[1])
+				er = u
+			}
 			env.Local[unwrapSymbol(el[1])] = er
 			if _, ok := er.(Proc); !ok {
 				return er
@@ -216,6 +228,9 @@ func Eval(e Expr, env Environment) Expr {
 				return Error{"set!: Must be of form '(set! <variable> <expression>)'"}
 			}
 			if env.find(unwrapSymbol(el[1])) != nil {
+				if _, ok := env.find(unwrapSymbol(el[1]))[unwrapSymbol(el[1])].(importedVar); ok {
+					return Error{"set!: " + unwrapSymbol(el[1]) + " is imported from a library and cannot be assigned."}
+				}
 				er := Eval(el[2], env)
 				env.find(unwrapSymbol(el[1]))[unwrapSymbol(el[1])] = er
 				return er
@@ -234,25 +249,41 @@ func Eval(e Expr, env Environment) Expr {
 				env.LocalSyntax[el[1].(Symbol)] = t
 			}
 		} else if s0 == "lambda" {
+			var doc string
+			if len(el) == 4 {
+				//A leading string literal followed by the real body is a docstring.
+				ds, ok := el[2].(String)
+				if !ok {
+					return Error{"lambda: Must be of form '(lambda <formals> <body>)'"}
+				}
+				doc = unwrapString(ds)
+				el = append(el[:2:2], el[3])
+			}
 			if len(el) != 3 {
 				return Error{"lambda: Must be of form '(lambda <formals> <body>)'"}
 			}
 			//newenv := env.copy()
-			newenv := Environment{map[string]Expr{}, map[Symbol]transformer{}, &env}
+			newenv := Environment{map[string]Expr{}, map[Symbol]transformer{}, &env, env.lexical}
+			proc := UserProc{env: newenv, partialArgs: []Expr{}, body: el[2], doc: doc, source: e.(ExprList)}
 			if l, ok := el[1].(ExprList); ok {
 				expl := ExprListToSlice(l)
 				for i, v := range expl {
-					if v == Symbol(".") {
+					if v == Intern(".") {
 						if i != l.Length()-2 {
 							return Error{"Multiple variables after '.' not allowed!"}
 						}
 						//append(...) removes the . from the list of params
-						return UserProc{true, newenv, SliceToExprList(append(expl[:i], expl[i+1:]...)), []Expr{}, el[2]}
+						proc.variadic = true
+						proc.params = SliceToExprList(append(expl[:i], expl[i+1:]...))
+						return proc
 					}
 				}
-				return UserProc{false, newenv, el[1].(ExprList), []Expr{}, el[2]}
+				proc.params = l
+				return proc
 			} else if v, ok := el[1].(Symbol); ok {
-				return UserProc{true, newenv, SliceToExprList([]Expr{v}), []Expr{}, el[2]}
+				proc.variadic = true
+				proc.params = SliceToExprList([]Expr{v})
+				return proc
 			}
 		} else if s0 == "go" {
 			if len(el) != 2 {
@@ -271,8 +302,26 @@ func Eval(e Expr, env Environment) Expr {
 			ret := Eval(el[1], env)
 			fmt.Println("time:", time.Now().Sub(t))
 			return ret
-		} else if env.LocalSyntax[Symbol(s0)] != nil {
-			exp := env.LocalSyntax[Symbol(s0)].transform(el)
+		} else if s0 == "define-library" {
+			return defineLibrary(el, env)
+		} else if s0 == "import" {
+			if err := importSets("import", env, false, el[1:]); err != nil {
+				return err
+			}
+		} else if s0 == "cond-expand" {
+			body, err := condExpand("cond-expand", el[1:], env)
+			if err != nil {
+				return err
+			}
+			return evalSequence(body, env)
+		} else if s0 == "include" || s0 == "include-ci" {
+			forms, err := includeFiles(s0, el[1:], env, s0 == "include-ci")
+			if err != nil {
+				return err
+			}
+			return evalSequence(forms, env)
+		} else if env.LocalSyntax[Intern(s0)] != nil {
+			exp := env.LocalSyntax[Intern(s0)].transform(el)
 			if _, ok := exp.(Error); ok {
 				return exp
 			}
@@ -287,7 +336,7 @@ func Eval(e Expr, env Environment) Expr {
 				return Eval(SliceToExprList(elcopy), env)
 			} else {
 				//TODO
-				fmt.Println("Error: Expected procedure, have", proc)
+				Println("Error: Expected procedure, have " + Sprint(proc))
 			}
 		}
 	} else if p, ok := el[0].(Proc); ok {
@@ -295,7 +344,7 @@ func Eval(e Expr, env Environment) Expr {
 		for _, arg := range el[1:] {
 			args = append(args, Eval(arg, env))
 		}
-		nEnv := Environment{map[string]Expr{}, map[Symbol]transformer{}, &env}
+		nEnv := Environment{map[string]Expr{}, map[Symbol]transformer{}, &env, false}
 		return p.eval(nEnv, args...)
 	} else {
 		proc := Eval(el[0], env)
@@ -306,21 +355,18 @@ func Eval(e Expr, env Environment) Expr {
 			return Eval(SliceToExprList(elcopy), env)
 		} else {
 			//TODO
-			fmt.Println("Error: Expected procedure, have", proc)
+			Println("Error: Expected procedure, have " + Sprint(proc))
 		}
 	}
-	return Symbol("")
+	return Intern("")
 }
 
 func atom(s string) Expr {
-	if strings.HasPrefix(s, "#\\") {
-		return decodeCharacter(s[2:])
-	}
 	if i, err := strconv.Atoi(s); err == nil {
 		return Number((i))
 	}
 	if f, err := strconv.ParseFloat(s, 64); err == nil {
 		return Number(f)
 	}
-	return Symbol(s)
+	return Intern(s)
 }
@@ -0,0 +1,23 @@
+package goscheme
+
+import "testing"
+
+//The deprecated Tokenize and Parse are kept for programs that embed the
+//interpreter.
+func TestDeprecatedParse(t *testing.T) {
+	env := testEnv(t)
+	tests := []struct {
+		src, want string
+	}{
+		{"(+ 1 2)", "3"},
+		{"'(a b)", "(a b)"},
+		{`(string-append "a b" "c")`, `"a bc"`},
+		{"(car (quote (x y)))", "x"},
+	}
+	for _, test := range tests {
+		tokens := Tokenize(test.src)
+		if got := Sprint(Eval(Parse(&tokens, true), env)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
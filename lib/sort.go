@@ -0,0 +1,336 @@
+package goscheme
+
+import (
+	"sort"
+	"strconv"
+)
+
+/*
+Sorting procedures from SRFI 132. Every sort is stable since they all use
+sort.SliceStable. The ordering is a Scheme procedure, so an Error it returns
+is remembered and returned once the sort is done.
+*/
+
+//lessFunc wraps the Scheme procedure less for use by the sort package. The
+//first Error returned by less is stored in err.
+func lessFunc(e Environment, less Proc, err *Expr) func(a, b Expr) bool {
+	return func(a, b Expr) bool {
+		if *err != nil {
+			return false
+		}
+		r := callProc(e, less, a, b)
+		if _, ok := r.(Error); ok {
+			*err = r
+			return false
+		}
+		return truthy(r)
+	}
+}
+
+//sortExprs sorts s in place.
+func sortExprs(e Environment, less Proc, s []Expr) Expr {
+	var err Expr
+	lt := lessFunc(e, less, &err)
+	sort.SliceStable(s, func(i, j int) bool { return lt(s[i], s[j]) })
+	return err
+}
+
+//mergeExprs merges two sorted slices, taking from a when elements are equal.
+func mergeExprs(e Environment, less Proc, a, b []Expr) ([]Expr, Expr) {
+	var err Expr
+	lt := lessFunc(e, less, &err)
+	ret := make([]Expr, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if lt(b[0], a[0]) {
+			ret, b = append(ret, b[0]), b[1:]
+		} else {
+			ret, a = append(ret, a[0]), a[1:]
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret = append(append(ret, a...), b...)
+	return ret, nil
+}
+
+//deleteNeighborDups keeps the first of every run of elements that are equal
+//according to eq.
+func deleteNeighborDups(e Environment, eq Proc, s []Expr) ([]Expr, Expr) {
+	ret := make([]Expr, 0, len(s))
+	for i, x := range s {
+		if i > 0 {
+			r := callProc(e, eq, ret[len(ret)-1], x)
+			if _, ok := r.(Error); ok {
+				return nil, r
+			}
+			if truthy(r) {
+				continue
+			}
+		}
+		ret = append(ret, x)
+	}
+	return ret, nil
+}
+
+func procArg(name string, args []Expr, i int) (Proc, Expr) {
+	p, ok := args[i].(Proc)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a procedure."}
+	}
+	return p, nil
+}
+
+//listArg returns the elements of the list in args[i], which must not be
+//circular.
+func listArg(name string, args []Expr, i int) ([]Expr, Expr) {
+	l, ok := args[i].(ExprList)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a list."}
+	}
+	if listLength(l) < 0 {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is a circular list."}
+	}
+	return ExprListToSlice(l), nil
+}
+
+//vectorRangeArg returns the part of the vector in args[i] selected by the
+//optional start and end arguments after it.
+func vectorRangeArg(name string, args []Expr, i int) (Vector, Expr) {
+	v, ok := args[i].(Vector)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a vector."}
+	}
+	start, end, err := rangeArgs(name, args, i+1, len(v))
+	if err != nil {
+		return nil, err
+	}
+	return v[start:end], nil
+}
+
+//(list-delete-neighbor-dups = list)
+func listdeleteneighbordups(e Environment, args ...Expr) Expr {
+	eq, err := procArg("list-delete-neighbor-dups", args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg("list-delete-neighbor-dups", args, 1)
+	if err != nil {
+		return err
+	}
+	ret, err := deleteNeighborDups(e, eq, l)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//(list-merge < list1 list2)
+func listmerge(e Environment, args ...Expr) Expr {
+	less, err := procArg("list-merge", args, 0)
+	if err != nil {
+		return err
+	}
+	a, err := listArg("list-merge", args, 1)
+	if err != nil {
+		return err
+	}
+	b, err := listArg("list-merge", args, 2)
+	if err != nil {
+		return err
+	}
+	ret, err := mergeExprs(e, less, a, b)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(ret)
+}
+
+//(list-sort < list) returns a sorted copy of the list.
+func listsort(e Environment, args ...Expr) Expr {
+	less, err := procArg("list-sort", args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg("list-sort", args, 1)
+	if err != nil {
+		return err
+	}
+	if err := sortExprs(e, less, l); err != nil {
+		return err
+	}
+	return SliceToExprList(l)
+}
+
+//(list-sorted? < list)
+func listsorted(e Environment, args ...Expr) Expr {
+	less, err := procArg("list-sorted?", args, 0)
+	if err != nil {
+		return err
+	}
+	l, err := listArg("list-sorted?", args, 1)
+	if err != nil {
+		return err
+	}
+	return sorted(e, less, l)
+}
+
+//(sort sequence <) from SRFI 95 sorts either a list or a vector, returning a
+//new one.
+func sort_(e Environment, args ...Expr) Expr {
+	less, err := procArg("sort", args, 1)
+	if err != nil {
+		return err
+	}
+	switch v := args[0].(type) {
+	case ExprList:
+		return listsort(e, less, v)
+	case Vector:
+		return vectorsort(e, less, v)
+	}
+	return Error{"sort: Argument 1 is not a list or a vector."}
+}
+
+//sorted reports whether s is sorted according to less.
+func sorted(e Environment, less Proc, s []Expr) Expr {
+	var err Expr
+	lt := lessFunc(e, less, &err)
+	for i := 1; i < len(s); i++ {
+		if lt(s[i], s[i-1]) {
+			return Boolean(false)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return Boolean(true)
+}
+
+//(vector-binary-search vector value cmp [start [end]]) finds value in a
+//sorted vector. (cmp element value) returns a negative number, zero or a
+//positive number when element is less than, equal to or greater than value.
+//Returns the index of value, or #f if it is not found.
+func vectorbinarysearch(e Environment, args ...Expr) Expr {
+	v, ok := args[0].(Vector)
+	if !ok {
+		return Error{"vector-binary-search: Argument 1 is not a vector."}
+	}
+	cmp, err := procArg("vector-binary-search", args, 2)
+	if err != nil {
+		return err
+	}
+	lo, hi, err := rangeArgs("vector-binary-search", args, 3, len(v))
+	if err != nil {
+		return err
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		r := callProc(e, cmp, v[mid], args[1])
+		n, ok := r.(Number)
+		if !ok {
+			if _, ok := r.(Error); ok {
+				return r
+			}
+			return Error{"vector-binary-search: The comparison procedure did not return a number."}
+		}
+		switch {
+		case n < 0:
+			lo = mid + 1
+		case n > 0:
+			hi = mid
+		default:
+			return Number(mid)
+		}
+	}
+	return Boolean(false)
+}
+
+//(vector-delete-neighbor-dups = vector [start [end]])
+func vectordeleteneighbordups(e Environment, args ...Expr) Expr {
+	eq, err := procArg("vector-delete-neighbor-dups", args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := vectorRangeArg("vector-delete-neighbor-dups", args, 1)
+	if err != nil {
+		return err
+	}
+	ret, err := deleteNeighborDups(e, eq, v)
+	if err != nil {
+		return err
+	}
+	return Vector(ret)
+}
+
+//(vector-merge < vector1 vector2)
+func vectormerge(e Environment, args ...Expr) Expr {
+	less, err := procArg("vector-merge", args, 0)
+	if err != nil {
+		return err
+	}
+	a, ok := args[1].(Vector)
+	if !ok {
+		return Error{"vector-merge: Argument 2 is not a vector."}
+	}
+	b, ok := args[2].(Vector)
+	if !ok {
+		return Error{"vector-merge: Argument 3 is not a vector."}
+	}
+	ret, err := mergeExprs(e, less, a, b)
+	if err != nil {
+		return err
+	}
+	return Vector(ret)
+}
+
+//(vector-sort < vector [start [end]]) returns a sorted copy of the vector, or
+//of the part of it between start and end.
+func vectorsort(e Environment, args ...Expr) Expr {
+	less, err := procArg("vector-sort", args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := vectorRangeArg("vector-sort", args, 1)
+	if err != nil {
+		return err
+	}
+	ret := append(Vector{}, v...)
+	if err := sortExprs(e, less, ret); err != nil {
+		return err
+	}
+	return ret
+}
+
+//(vector-sort! vector < [start [end]]) sorts the vector in place. Since every
+//sort is stable this is also vector-stable-sort!.
+func vectorsort_(e Environment, args ...Expr) Expr {
+	less, err := procArg("vector-sort!", args, 1)
+	if err != nil {
+		return err
+	}
+	v, ok := args[0].(Vector)
+	if !ok {
+		return Error{"vector-sort!: Argument 1 is not a vector."}
+	}
+	start, end, err := rangeArgs("vector-sort!", args, 2, len(v))
+	if err != nil {
+		return err
+	}
+	if err := sortExprs(e, less, v[start:end]); err != nil {
+		return err
+	}
+	return v
+}
+
+//(vector-sorted? < vector [start [end]])
+func vectorsorted(e Environment, args ...Expr) Expr {
+	less, err := procArg("vector-sorted?", args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := vectorRangeArg("vector-sorted?", args, 1)
+	if err != nil {
+		return err
+	}
+	return sorted(e, less, v)
+}
@@ -0,0 +1,63 @@
+package goscheme
+
+import (
+	"math/rand"
+	"testing"
+)
+
+//Elements that compare equal keep their order in every sort and merge.
+func TestSortStable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pairs := make([]Expr, 200)
+	for i := range pairs {
+		pairs[i] = SliceToExprList([]Expr{Number(rng.Intn(10)), Number(i)})
+	}
+	env := testEnv(t)
+	env.Local["unsorted"] = SliceToExprList(pairs)
+	defer delete(env.Local, "unsorted")
+	evalString(t, `(define car< (lambda (a b) (< (car a) (car b))))`)
+	for _, src := range []string{
+		"(list-sort car< unsorted)",
+		"(vector->list (vector-sort car< (list->vector unsorted)))",
+		"(define sorted-vector (list->vector unsorted)) (vector-stable-sort! sorted-vector car<) (vector->list sorted-vector)",
+		"(sort unsorted car<)",
+	} {
+		sorted := ExprListToSlice(evalString(t, src).(ExprList))
+		if len(sorted) != len(pairs) {
+			t.Fatalf("%s returned %d elements, want %d", src, len(sorted), len(pairs))
+		}
+		for i := 1; i < len(sorted); i++ {
+			a, b := ExprListToSlice(sorted[i-1].(ExprList)), ExprListToSlice(sorted[i].(ExprList))
+			if a[0].(Number) > b[0].(Number) || a[0] == b[0] && a[1].(Number) > b[1].(Number) {
+				t.Errorf("%s puts %s before %s", src, Sprint(sorted[i-1]), Sprint(sorted[i]))
+				break
+			}
+		}
+	}
+	if got := Sprint(evalString(t, "(list-merge car< '((1 a) (2 a)) '((1 b) (2 b)))")); got != "((1 a) (1 b) (2 a) (2 b))" {
+		t.Errorf("list-merge does not take from the first list first: %s", got)
+	}
+}
+
+func TestSortProcedures(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(define partly (vector 5 3 1 4 2)) (vector-sort! partly < 1 4) partly", "#(5 1 3 4 2)"},
+		{"(vector-binary-search #(1 3 5 7) 5 -)", "2"},
+		{"(vector-binary-search #(1 3 5 7) 4 -)", "#f"},
+		{"(list-delete-neighbor-dups = '(1 1 2 1 1))", "(1 2 1)"},
+		{"(vector-delete-neighbor-dups = #(1 1 2 2 3) 1)", "#(1 2 3)"},
+		{"(list-sorted? < '(1 2 2))", "#t"},
+		{"(list-sorted? < '(2 1))", "#f"},
+		{"(vector-merge < #(1 4) #(2 3))", "#(1 2 3 4)"},
+		{"(list-sort < '(2 a))", "<: Argument 1 is not a number."},
+		{"(list-sort (lambda (a b) (car 5)) '(2 1))", "car: Argument 1 is not a list."},
+		{"(list-sort < 5)", "list-sort: Argument 2 is not a list."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
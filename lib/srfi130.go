@@ -0,0 +1,504 @@
+package goscheme
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+String library in the style of SRFI 130 (with string-split and string-join
+from SRFI 152).
+Strings are stored as runes, so string cursors are simply rune indexes and the
+cursor procedures accept and return numbers. Every procedure that takes
+optional start and end arguments accepts either cursors or indexes.
+*/
+
+//charPredicate turns args[i] into a predicate on runes. args[i] may be a
+//character, which matches itself, a char-set, or a procedure that is called on
+//each character.
+func charPredicate(e Environment, name string, args []Expr, i int) (func(rune) (bool, Expr), Expr) {
+	switch v := args[i].(type) {
+	case Character:
+		return func(r rune) (bool, Expr) { return r == rune(v), nil }, nil
+	case CharSet:
+		return func(r rune) (bool, Expr) { return v.contains(r), nil }, nil
+	case Proc:
+		return func(r rune) (bool, Expr) {
+			res := callProc(e, v, Character(r))
+			if _, ok := res.(Error); ok {
+				return false, res
+			}
+			return truthy(res), nil
+		}, nil
+	}
+	return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a character, char-set or predicate."}
+}
+
+//stringAndRange reads a string from args[0] and optional start and end
+//arguments following it at args[i].
+func stringAndRange(name string, args []Expr, i int) ([]rune, int, int, Expr) {
+	s, ok := args[0].(String)
+	if !ok {
+		return nil, 0, 0, Error{name + ": Argument 1 is not a string."}
+	}
+	start, end, err := rangeArgs(name, args, i, len(*s.runes))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return *s.runes, start, end, nil
+}
+
+//Returns the index of the first character in s that satisfies pred, or #f.
+func stringindex(e Environment, args ...Expr) Expr {
+	s, start, end, err := stringAndRange("string-index", args, 2)
+	if err != nil {
+		return err
+	}
+	pred, err := charPredicate(e, "string-index", args, 1)
+	if err != nil {
+		return err
+	}
+	for i := start; i < end; i++ {
+		if ok, err := pred(s[i]); err != nil {
+			return err
+		} else if ok {
+			return Number(i)
+		}
+	}
+	return Boolean(false)
+}
+
+//Returns the index of the last character in s that satisfies pred, or #f.
+func stringindexright(e Environment, args ...Expr) Expr {
+	s, start, end, err := stringAndRange("string-index-right", args, 2)
+	if err != nil {
+		return err
+	}
+	pred, err := charPredicate(e, "string-index-right", args, 1)
+	if err != nil {
+		return err
+	}
+	for i := end - 1; i >= start; i-- {
+		if ok, err := pred(s[i]); err != nil {
+			return err
+		} else if ok {
+			return Number(i)
+		}
+	}
+	return Boolean(false)
+}
+
+//(string-contains s1 s2 [start end]) returns the index in s1 where s2 first
+//occurs, or #f.
+func stringcontains(e Environment, args ...Expr) Expr {
+	s1, start, end, err := stringAndRange("string-contains", args, 2)
+	if err != nil {
+		return err
+	}
+	s2, ok := args[1].(String)
+	if !ok {
+		return Error{"string-contains: Argument 2 is not a string."}
+	}
+	hay := string(s1[start:end])
+	i := strings.Index(hay, unwrapString(s2))
+	if i == -1 {
+		return Boolean(false)
+	}
+	return Number(start + utf8.RuneCountInString(hay[:i]))
+}
+
+//(string-prefix? s1 s2) is true if s1 is a prefix of s2.
+func stringprefix_(e Environment, args ...Expr) Expr {
+	ss, err := stringArgs("string-prefix?", args, 0)
+	if err != nil {
+		return err
+	}
+	return Boolean(strings.HasPrefix(string(ss[1]), string(ss[0])))
+}
+
+//(string-suffix? s1 s2) is true if s1 is a suffix of s2.
+func stringsuffix_(e Environment, args ...Expr) Expr {
+	ss, err := stringArgs("string-suffix?", args, 0)
+	if err != nil {
+		return err
+	}
+	return Boolean(strings.HasSuffix(string(ss[1]), string(ss[0])))
+}
+
+//trim implements the string-trim family. The optional predicate defaults to
+//char-whitespace?.
+func trim(e Environment, name string, left, right bool, args []Expr) Expr {
+	s, start, end, err := stringAndRange(name, args, 2)
+	if err != nil {
+		return err
+	}
+	pred := func(r rune) (bool, Expr) { return unicode.IsSpace(r), nil }
+	if len(args) > 1 {
+		if pred, err = charPredicate(e, name, args, 1); err != nil {
+			return err
+		}
+	}
+	for left && start < end {
+		ok, err := pred(s[start])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		start++
+	}
+	for right && end > start {
+		ok, err := pred(s[end-1])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		end--
+	}
+	r := make([]rune, end-start)
+	copy(r, s[start:end])
+	return runesToString(r)
+}
+
+func stringtrim(e Environment, args ...Expr) Expr {
+	return trim(e, "string-trim", true, false, args)
+}
+
+func stringtrimright(e Environment, args ...Expr) Expr {
+	return trim(e, "string-trim-right", false, true, args)
+}
+
+func stringtrimboth(e Environment, args ...Expr) Expr {
+	return trim(e, "string-trim-both", true, true, args)
+}
+
+//pad implements string-pad and string-pad-right. A string longer than the
+//requested length is truncated from the side that would have been padded.
+func pad(name string, left bool, args []Expr) Expr {
+	s, start, end, err := stringAndRange(name, args, 3)
+	if err != nil {
+		return err
+	}
+	n, ok := args[1].(Number)
+	if !ok || n < 0 || float64(n) != float64(int(n)) {
+		return Error{name + ": Argument 2 is not a non-negative integer."}
+	}
+	c := ' '
+	if len(args) > 2 {
+		cc, ok := args[2].(Character)
+		if !ok {
+			return Error{name + ": Argument 3 is not a character."}
+		}
+		c = rune(cc)
+	}
+	s = s[start:end]
+	l := int(n)
+	r := make([]rune, l)
+	if len(s) >= l {
+		if left {
+			copy(r, s[len(s)-l:])
+		} else {
+			copy(r, s[:l])
+		}
+		return runesToString(r)
+	}
+	padding := l - len(s)
+	if left {
+		copy(r[padding:], s)
+		for i := 0; i < padding; i++ {
+			r[i] = c
+		}
+	} else {
+		copy(r, s)
+		for i := len(s); i < l; i++ {
+			r[i] = c
+		}
+	}
+	return runesToString(r)
+}
+
+func stringpad(e Environment, args ...Expr) Expr {
+	return pad("string-pad", true, args)
+}
+
+func stringpadright(e Environment, args ...Expr) Expr {
+	return pad("string-pad-right", false, args)
+}
+
+//grammarArg reads one of the symbols infix, strict-infix, prefix or suffix
+//from args[i], defaulting to infix.
+func grammarArg(name string, args []Expr, i int) (string, Expr) {
+	if len(args) <= i {
+		return "infix", nil
+	}
+	g, ok := args[i].(Symbol)
+	switch {
+	case !ok:
+		return "", Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a symbol."}
+	case g.name == "infix", g.name == "strict-infix", g.name == "prefix", g.name == "suffix":
+		return g.name, nil
+	}
+	return "", Error{name + ": Unknown grammar " + g.name + "."}
+}
+
+//(string-split s delimiter [grammar limit start end])
+//The delimiter may be a string or a character. An empty delimiter splits the
+//string into its characters. limit is the maximum number of splits to do.
+func stringsplit(e Environment, args ...Expr) Expr {
+	s, start, end, err := stringAndRange("string-split", args, 4)
+	if err != nil {
+		return err
+	}
+	var delim string
+	switch v := args[1].(type) {
+	case String:
+		delim = unwrapString(v)
+	case Character:
+		delim = string(rune(v))
+	default:
+		return Error{"string-split: Argument 2 is not a string or a character."}
+	}
+	grammar, err := grammarArg("string-split", args, 2)
+	if err != nil {
+		return err
+	}
+	limit := -1
+	if len(args) > 3 {
+		if n, ok := args[3].(Number); ok {
+			limit = int(n) + 1
+		} else if b, ok := args[3].(Boolean); !ok || bool(b) {
+			return Error{"string-split: Argument 4 is not a number or #f."}
+		}
+	}
+	str := string(s[start:end])
+	if str == "" {
+		if grammar == "strict-infix" {
+			return Error{"string-split: Cannot split an empty string with the strict-infix grammar."}
+		}
+		return ExprList{nil, nil}
+	}
+	parts := strings.SplitN(str, delim, limit)
+	if grammar == "prefix" && len(parts) > 1 && parts[0] == "" {
+		parts = parts[1:]
+	}
+	if grammar == "suffix" && len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	r := make([]Expr, len(parts))
+	for i, p := range parts {
+		r[i] = NewString(p)
+	}
+	return SliceToExprList(r)
+}
+
+//(string-join string-list [delimiter grammar])
+//The delimiter defaults to a single space.
+func stringjoin(e Environment, args ...Expr) Expr {
+	l, ok := args[0].(ExprList)
+	if !ok {
+		return Error{"string-join: Argument 1 is not a list."}
+	}
+	ss, err := stringArgs("string-join", ExprListToSlice(l), 0)
+	if err != nil {
+		return Error{"string-join: All members of the list must be strings."}
+	}
+	delim := " "
+	if len(args) > 1 {
+		d, ok := args[1].(String)
+		if !ok {
+			return Error{"string-join: Argument 2 is not a string."}
+		}
+		delim = unwrapString(d)
+	}
+	grammar, err := grammarArg("string-join", args, 2)
+	if err != nil {
+		return err
+	}
+	if len(ss) == 0 && grammar == "strict-infix" {
+		return Error{"string-join: Cannot join an empty list with the strict-infix grammar."}
+	}
+	strs := make([]string, len(ss))
+	for i, s := range ss {
+		strs[i] = string(s)
+	}
+	r := strings.Join(strs, delim)
+	if len(strs) != 0 {
+		if grammar == "prefix" {
+			r = delim + r
+		} else if grammar == "suffix" {
+			r += delim
+		}
+	}
+	return NewString(r)
+}
+
+//(string-replace s1 s2 start1 end1 [start2 end2]) returns a copy of s1 where
+//the characters between start1 and end1 are replaced by s2.
+func stringreplace(e Environment, args ...Expr) Expr {
+	s1, ok := args[0].(String)
+	if !ok {
+		return Error{"string-replace: Argument 1 is not a string."}
+	}
+	s2, ok := args[1].(String)
+	if !ok {
+		return Error{"string-replace: Argument 2 is not a string."}
+	}
+	start1, end1, err := rangeArgs("string-replace", args, 2, len(*s1.runes))
+	if err != nil {
+		return err
+	}
+	start2, end2, err := rangeArgs("string-replace", args, 4, len(*s2.runes))
+	if err != nil {
+		return err
+	}
+	r := make([]rune, 0, len(*s1.runes)-(end1-start1)+(end2-start2))
+	r = append(r, (*s1.runes)[:start1]...)
+	r = append(r, (*s2.runes)[start2:end2]...)
+	r = append(r, (*s1.runes)[end1:]...)
+	return runesToString(r)
+}
+
+func stringreverse(e Environment, args ...Expr) Expr {
+	s, start, end, err := stringAndRange("string-reverse", args, 1)
+	if err != nil {
+		return err
+	}
+	r := make([]rune, end-start)
+	for i := range r {
+		r[i] = s[end-1-i]
+	}
+	return runesToString(r)
+}
+
+func stringcursorstart(e Environment, args ...Expr) Expr {
+	if _, ok := args[0].(String); !ok {
+		return Error{"string-cursor-start: Argument 1 is not a string."}
+	}
+	return Number(0)
+}
+
+func stringcursorend(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"string-cursor-end: Argument 1 is not a string."}
+	} else {
+		return Number(len(*s.runes))
+	}
+}
+
+//cursorMove implements string-cursor-next, -prev, -forward and -back.
+//dir is the direction to move in, the distance is read from args[2] if given.
+func cursorMove(name string, dir int, args []Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{name + ": Argument 1 is not a string."}
+	}
+	c, err := indexArg(name, args, 1, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	n := 1
+	if len(args) > 2 {
+		if n, err = indexArg(name, args, 2, len(*s.runes)); err != nil {
+			return err
+		}
+	}
+	c += dir * n
+	if c < 0 || c > len(*s.runes) {
+		return Error{name + ": Cursor moved out of range."}
+	}
+	return Number(c)
+}
+
+func stringcursornext(e Environment, args ...Expr) Expr {
+	return cursorMove("string-cursor-next", 1, args)
+}
+
+func stringcursorprev(e Environment, args ...Expr) Expr {
+	return cursorMove("string-cursor-prev", -1, args)
+}
+
+func stringcursorforward(e Environment, args ...Expr) Expr {
+	return cursorMove("string-cursor-forward", 1, args)
+}
+
+func stringcursorback(e Environment, args ...Expr) Expr {
+	return cursorMove("string-cursor-back", -1, args)
+}
+
+func stringcursorref(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-cursor-ref: Argument 1 is not a string."}
+	}
+	c, err := indexArg("string-cursor-ref", args, 1, len(*s.runes)-1)
+	if err != nil {
+		return err
+	}
+	return Character((*s.runes)[c])
+}
+
+//(string-cursor-diff s start end) returns the number of characters between
+//the two cursors.
+func stringcursordiff(e Environment, args ...Expr) Expr {
+	_, start, end, err := stringAndRange("string-cursor-diff", args, 1)
+	if err != nil {
+		return err
+	}
+	return Number(end - start)
+}
+
+//Cursors and indexes are the same thing, so both conversions only check that
+//the argument is in range.
+func stringcursortoindex(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-cursor->index: Argument 1 is not a string."}
+	}
+	c, err := indexArg("string-cursor->index", args, 1, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	return Number(c)
+}
+
+func stringindextocursor(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-index->cursor: Argument 1 is not a string."}
+	}
+	c, err := indexArg("string-index->cursor", args, 1, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	return Number(c)
+}
+
+//(string-for-each-cursor proc s [start end]) calls proc with every cursor in
+//the string, in order.
+func stringforeachcursor(e Environment, args ...Expr) Expr {
+	p, ok := args[0].(Proc)
+	if !ok {
+		return Error{"string-for-each-cursor: Argument 1 is not a procedure."}
+	}
+	s, ok := args[1].(String)
+	if !ok {
+		return Error{"string-for-each-cursor: Argument 2 is not a string."}
+	}
+	start, end, err := rangeArgs("string-for-each-cursor", args, 2, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	for i := start; i < end; i++ {
+		if r := callProc(e, p, Number(i)); r != nil {
+			if _, ok := r.(Error); ok {
+				return r
+			}
+		}
+	}
+	return Boolean(true)
+}
@@ -0,0 +1,101 @@
+package goscheme
+
+import "testing"
+
+//The length given to string-pad and string-pad-right must be an exact count
+//of characters, so fractions are rejected rather than truncated.
+func TestPadLength(t *testing.T) {
+	if got := Sprint(evalString(t, `(string-pad "ab" 3)`)); got != `" ab"` {
+		t.Errorf(`(string-pad "ab" 3) = %s`, got)
+	}
+	if got := Sprint(evalString(t, `(string-pad-right "abc" 2)`)); got != `"ab"` {
+		t.Errorf(`(string-pad-right "abc" 2) = %s`, got)
+	}
+	for _, name := range []string{"string-pad", "string-pad-right"} {
+		for _, n := range []string{"3.7", "-1", "(/ 1 0)", "'a"} {
+			src := "(" + name + ` "ab" ` + n + ")"
+			want := name + ": Argument 2 is not a non-negative integer."
+			if got := Sprint(evalString(t, src)); got != want {
+				t.Errorf("%s = %s, want %s", src, got, want)
+			}
+		}
+	}
+}
+
+func TestStringSearch(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(string-index "hello world" char-whitespace?)`, "5"},
+		{`(string-index "abc" #\z)`, "#f"},
+		{`(string-index-right "a.b.c" #\.)`, "3"},
+		{`(string-contains "hello" "ll")`, "2"},
+		{`(string-contains "hello" "x")`, "#f"},
+		{`(string-prefix? "he" "hello")`, "#t"},
+		{`(string-suffix? "he" "hello")`, "#f"},
+		{`(string-trim "  ab  ")`, `"ab  "`},
+		{`(string-trim-right "  ab  ")`, `"  ab"`},
+		{`(string-trim-both "xxabxx" #\x)`, `"ab"`},
+		{`(string-replace "abcdef" "XY" 1 3)`, `"aXYdef"`},
+		{`(string-reverse "héλ")`, `"λéh"`},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+//string-join with the infix grammars undoes string-split, and prefix and
+//suffix put the delimiter before or after every part.
+func TestSplitJoin(t *testing.T) {
+	for _, grammar := range []string{"infix", "strict-infix"} {
+		for _, s := range []string{"a,b,,c", ",a", "a,", "abc"} {
+			src := `(string-join (string-split "` + s + `" #\, '` + grammar + `) "," '` + grammar + `)`
+			if got := Sprint(evalString(t, src)); got != `"`+s+`"` {
+				t.Errorf("%s = %s, want %q", src, got, s)
+			}
+		}
+	}
+	tests := []struct {
+		src, want string
+	}{
+		{`(string-split ",a,b" #\, 'prefix)`, `("a" "b")`},
+		{`(string-split "a,b," #\, 'suffix)`, `("a" "b")`},
+		{`(string-join '("a" "b") "/" 'prefix)`, `"/a/b"`},
+		{`(string-join '("a" "b") "/" 'suffix)`, `"a/b/"`},
+		{`(string-split "a,b,c" #\, 'infix 1)`, `("a" "b,c")`},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+	want := "string-join: Cannot join an empty list with the strict-infix grammar."
+	if got := Sprint(evalString(t, `(string-join '() "," 'strict-infix)`)); got != want {
+		t.Errorf("Joining an empty list with strict-infix gives %s, want %s", got, want)
+	}
+}
+
+//Cursors step over characters, whatever their size in bytes.
+func TestStringCursors(t *testing.T) {
+	evalString(t, `(define cursor-string "héλlo") (define cursor (string-cursor-start cursor-string))`)
+	var got []rune
+	for i := 0; i < 5; i++ {
+		c, ok := evalString(t, "(string-cursor-ref cursor-string cursor)").(Character)
+		if !ok {
+			t.Fatalf("string-cursor-ref at cursor %d is not a character", i)
+		}
+		got = append(got, rune(c))
+		evalString(t, "(set! cursor (string-cursor-next cursor-string cursor))")
+	}
+	if string(got) != "héλlo" {
+		t.Errorf("Walking the string with cursors gives %q", string(got))
+	}
+	if r := evalString(t, "(equal? cursor (string-cursor-end cursor-string))"); r != Expr(Boolean(true)) {
+		t.Errorf("The cursor is not at the end after 5 steps.")
+	}
+	if r := Sprint(evalString(t, "(string-cursor-ref cursor-string cursor)")); r != "string-cursor-ref: Index 5 is out of range." {
+		t.Errorf("string-cursor-ref at the end gives %s", r)
+	}
+}
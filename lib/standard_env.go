@@ -18,9 +18,9 @@
 package goscheme
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	//	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -35,129 +36,464 @@ import (
 
 //TODO: Obviously not complete yet. Also will include some non-R5RS stuff since e.g. the "go" keyword is baked inside Eval.
 func R5RSNullEnv() Environment {
-	e := Environment{map[string]Expr{}, map[Symbol]transformer{}, nil}
-	in, err := ioutil.ReadFile("std/r5rssyntax.scm")
+	e := Environment{map[string]Expr{}, map[Symbol]transformer{}, nil, false}
+	f, err := os.Open("std/r5rssyntax.scm")
 	if err != nil {
 		//TODO:
 		panic("Error loading standard syntax.")
 	}
-	ins := string(in)
-	t := Tokenize(ins)
-	for len(t) != 0 {
-		r := Eval(Parse(&t, true), e)
-		if s, ok := r.(Symbol); !ok || string(s) != "" {
-			fmt.Println(r)
-		}
-	}
+	defer f.Close()
+	evalReader(newInputPort(f, nopCloser{}), e, true)
 	return e
 }
 
 func StandardEnv() Environment {
 	e := Environment{map[string]Expr{
-		"#f":                  Boolean(false),
-		"#t":                  Boolean(true),
-		"+":                   NewBuiltIn("+", 0, -1, add),
-		"-":                   NewBuiltIn("-", 1, -1, sub),
-		"*":                   NewBuiltIn("*", 0, -1, mul),
-		"/":                   NewBuiltIn("/", 1, -1, div),
-		">":                   NewBuiltIn(">", 2, -1, gt),
-		"<":                   NewBuiltIn("<", 2, -1, lt),
-		"=":                   NewBuiltIn("=", 2, -1, eq),
-		"<-":                  NewBuiltIn("<-", 2, 2, send),
-		"->":                  NewBuiltIn("->", 1, 1, receive),
-		"acos":                NewBuiltIn("acos", 1, 1, acos),
-		"angle":               NewBuiltIn("angle", 1, 1, angle),
-		"apply":               NewBuiltIn("apply", 2, -1, apply),
-		"asin":                NewBuiltIn("asin", 1, 1, asin),
-		"atan":                NewBuiltIn("atan", 1, 1, atan),
-		"begin":               NewBuiltIn("begin", 0, -1, begin),
-		"boolean?":            NewBuiltIn("boolean?", 1, 1, boolean_),
-		"byte?":               NewBuiltIn("byte?", 1, 1, byte_),
-		"bytes->chars":        NewBuiltIn("bytes->char", 1, 1, bytestochars),
-		"ceiling":             NewBuiltIn("ceiling", 1, 1, ceiling),
-		"char-ready?":         NewBuiltIn("char-ready?", 0, 1, charready_),
-		"char?":               NewBuiltIn("char?", 1, 1, char_),
-		"close":               NewBuiltIn("close", 1, 1, sclose),
-		"complex?":            NewBuiltIn("complex?", 1, 1, complex_),
-		"car":                 NewBuiltIn("car", 1, 1, car),
-		"cdr":                 NewBuiltIn("cdr", 1, 1, cdr),
-		"chan":                NewBuiltIn("chan", 0, 0, schan),
-		"char->bytes":         NewBuiltIn("char->bytes", 1, 1, chartobytes),
-		"char->integer":       NewBuiltIn("char->integer", 1, 1, chartoint),
-		"char-alphabetic?":    NewBuiltIn("char-alphabetic?", 1, 1, charalpha_),
-		"char-downcase":       NewBuiltIn("char-downcase", 1, 1, chardown),
-		"char-lower-case?":    NewBuiltIn("char-lower-case?", 1, 1, charlower_),
-		"char-numeric?":       NewBuiltIn("char-numeric?", 1, 1, charnumeric_),
-		"char-upcase":         NewBuiltIn("char-upcase", 1, 1, charup),
-		"char-upper-case?":    NewBuiltIn("char-upper-case?", 1, 1, charupper_),
-		"char-whitespace?":    NewBuiltIn("char-whitespace?", 1, 1, charwhitespace_),
-		"close-input-port":    NewBuiltIn("close-input-port", 1, 1, closeinport),
-		"close-output-port":   NewBuiltIn("close-output-port", 1, 1, closeoutport),
-		"cons":                NewBuiltIn("cons", 2, 2, cons),
-		"cos":                 NewBuiltIn("cos", 1, 1, cos),
-		"current-input-port":  Port{os.Stdin, nil, bufio.NewReader(os.Stdin), nil},
-		"current-output-port": Port{nil, os.Stdout, nil, bufio.NewWriter(os.Stdout)},
-		"exp":                     NewBuiltIn("exp", 1, 1, exp),
-		"eq?":                     NewBuiltIn("eq?", 2, 2, eqv),
-		"equal?":                  NewBuiltIn("equal?", 2, 2, eq),
-		"eqv?":                    NewBuiltIn("eqv?", 2, 2, eqv),
-		"error":                   NewBuiltIn("error", 1, 1, serror),
-		"error?":                  NewBuiltIn("error?", 1, 1, error_),
-		"eval":                    NewBuiltIn("eval", 1, 2, eval),
-		"file-size":               NewBuiltIn("file-size", 1, 1, filesize),
-		"floor":                   NewBuiltIn("floor", 1, 1, floor),
-		"flush":                   NewBuiltIn("flush", 0, 1, flush),
-		"imag-part":               NewBuiltIn("imag-part", 1, 1, imagpart),
-		"input-port?":             NewBuiltIn("input-port?", 1, 1, inputport_),
-		"integer->char":           NewBuiltIn("integer->char", 1, 1, inttochar),
-		"integer?":                NewBuiltIn("integer?", 1, 1, integer_),
-		"interaction-environment": NewBuiltIn("interaction-environment", 0, 0, interactionEnv),
-		"list":             NewBuiltIn("list", 0, -1, list),
-		"list?":            NewBuiltIn("list?", 1, 1, list_),
-		"list->string":     NewBuiltIn("list->string", 1, 1, listtostr),
-		"load":             NewBuiltIn("load", 1, 1, load),
-		"log":              NewBuiltIn("log", 1, 1, log),
-		"magnitude":        NewBuiltIn("magnitude", 1, 1, magnitude),
-		"make-polar":       NewBuiltIn("make-polar", 2, 2, makepolar),
-		"make-rectangular": NewBuiltIn("make-rectangular", 2, 2, makerect),
-		"make-vector":      NewBuiltIn("make-vector", 1, 2, makevec),
-		"modulo":           NewBuiltIn("modulo", 2, 2, modulo),
-		"newline":          NewBuiltIn("newline", 1, 2, newline),
-		"not":              NewBuiltIn("not", 1, 1, not),
-		"null-environment": NewBuiltIn("null-environment", 0, 1, nullEnv),
-		"number->string":   NewBuiltIn("number->string", 1, 1, numtostr),
-		"number?":          NewBuiltIn("number?", 1, 1, number_),
-		"open-input-file":  NewBuiltIn("open-input-file", 1, 1, openinfile),
-		"open-output-file": NewBuiltIn("open-output-file", 1, 1, openoutfile),
-		"output-port?":     NewBuiltIn("output-port?", 1, 1, outputport_),
-		"pair?":            NewBuiltIn("pair?", 1, 1, pair_),
-		"peek-char":        NewBuiltIn("peek-char", 0, 1, peekchar),
+		"#f":                              Boolean(false),
+		"#t":                              Boolean(true),
+		"%interpolate":                    NewBuiltIn("%interpolate", 0, -1, interpolate),
+		"+":                               NewBuiltIn("+", 0, -1, add),
+		"-":                               NewBuiltIn("-", 1, -1, sub),
+		"*":                               NewBuiltIn("*", 0, -1, mul),
+		"/":                               NewBuiltIn("/", 1, -1, div),
+		">":                               NewBuiltIn(">", 2, -1, gt),
+		"<":                               NewBuiltIn("<", 2, -1, lt),
+		"=":                               NewBuiltIn("=", 2, -1, eq),
+		"<-":                              NewBuiltIn("<-", 2, 2, send),
+		"->":                              NewBuiltIn("->", 1, 1, receive),
+		"acos":                            NewBuiltIn("acos", 1, 1, acos),
+		"alist->hash-table":               NewBuiltIn("alist->hash-table", 1, 3, alisttohashtable),
+		"alist-cons":                      NewBuiltIn("alist-cons", 3, 3, alistcons),
+		"alist-copy":                      NewBuiltIn("alist-copy", 1, 1, alistcopy),
+		"alist-delete":                    NewBuiltIn("alist-delete", 2, 3, alistdelete),
+		"alist-delete!":                   NewBuiltIn("alist-delete!", 2, 3, alistdelete),
+		"angle":                           NewBuiltIn("angle", 1, 1, angle),
+		"any":                             NewBuiltIn("any", 2, -1, any_),
+		"append":                          NewBuiltIn("append", 0, -1, append_),
+		"append!":                         NewBuiltIn("append!", 0, -1, append_),
+		"append-map":                      NewBuiltIn("append-map", 2, -1, appendmap),
+		"append-map!":                     NewBuiltIn("append-map!", 2, -1, appendmap),
+		"append-reverse":                  NewBuiltIn("append-reverse", 2, 2, appendreverse),
+		"append-reverse!":                 NewBuiltIn("append-reverse!", 2, 2, appendreverse),
+		"apply":                           NewBuiltIn("apply", 2, -1, apply),
+		"asin":                            NewBuiltIn("asin", 1, 1, asin),
+		"assoc":                           NewBuiltIn("assoc", 2, 3, assoc),
+		"assq":                            NewBuiltIn("assq", 2, 2, assq),
+		"assv":                            NewBuiltIn("assv", 2, 2, assv),
+		"atan":                            NewBuiltIn("atan", 1, 1, atan),
+		"begin":                           NewBuiltIn("begin", 0, -1, begin),
+		"boolean?":                        NewBuiltIn("boolean?", 1, 1, boolean_),
+		"break":                           NewBuiltIn("break", 2, 2, break_),
+		"break!":                          NewBuiltIn("break!", 2, 2, break_),
+		"call-with-output-string":         NewBuiltIn("call-with-output-string", 1, 1, callwithoutputstring),
+		"byte?":                           NewBuiltIn("byte?", 1, 1, byte_),
+		"bytevector?":                     NewBuiltIn("bytevector?", 1, 1, bytevector_),
+		"bytevector":                      NewBuiltIn("bytevector", 0, -1, bytevector),
+		"bytevector-append":               NewBuiltIn("bytevector-append", 0, -1, bytevectorappend),
+		"bytevector-copy":                 NewBuiltIn("bytevector-copy", 1, 3, bytevectorcopy),
+		"bytevector-copy!":                NewBuiltIn("bytevector-copy!", 3, 5, bytevectorcopy_),
+		"bytevector-length":               NewBuiltIn("bytevector-length", 1, 1, bytevectorlength),
+		"bytevector-u8-ref":               NewBuiltIn("bytevector-u8-ref", 2, 2, bytevectoru8ref),
+		"bytevector-u8-set!":              NewBuiltIn("bytevector-u8-set!", 3, 3, bytevectoru8set),
+		"bytes->chars":                    NewBuiltIn("bytes->char", 1, 1, bytestochars),
+		"car+cdr":                         NewBuiltIn("car+cdr", 1, 1, carcdr),
+		"ceiling":                         NewBuiltIn("ceiling", 1, 1, ceiling),
+		"char-ready?":                     NewBuiltIn("char-ready?", 0, 1, charready_),
+		"char?":                           NewBuiltIn("char?", 1, 1, char_),
+		"char-set":                        NewBuiltIn("char-set", 0, -1, charset),
+		"char-set->list":                  NewBuiltIn("char-set->list", 1, 1, charsettolist),
+		"char-set->string":                NewBuiltIn("char-set->string", 1, 1, charsettostring),
+		"char-set-adjoin":                 NewBuiltIn("char-set-adjoin", 1, -1, charsetadjoin),
+		"char-set-complement":             NewBuiltIn("char-set-complement", 1, 1, charsetcomplement),
+		"char-set-contains?":              NewBuiltIn("char-set-contains?", 2, 2, charsetcontains_),
+		"char-set-count":                  NewBuiltIn("char-set-count", 2, 2, charsetcount),
+		"char-set-delete":                 NewBuiltIn("char-set-delete", 1, -1, charsetdelete),
+		"char-set-difference":             NewBuiltIn("char-set-difference", 1, -1, charsetdifference),
+		"char-set-filter":                 NewBuiltIn("char-set-filter", 2, 3, charsetfilter),
+		"char-set-fold":                   NewBuiltIn("char-set-fold", 3, 3, charsetfold),
+		"char-set-for-each":               NewBuiltIn("char-set-for-each", 2, 2, charsetforeach),
+		"char-set-intersection":           NewBuiltIn("char-set-intersection", 0, -1, charsetintersection),
+		"char-set-size":                   NewBuiltIn("char-set-size", 1, 1, charsetsize),
+		"char-set-union":                  NewBuiltIn("char-set-union", 0, -1, charsetunion),
+		"char-set-xor":                    NewBuiltIn("char-set-xor", 0, -1, charsetxor),
+		"char-set<=":                      NewBuiltIn("char-set<=", 0, -1, charsetle),
+		"char-set=":                       NewBuiltIn("char-set=", 0, -1, charseteq),
+		"char-set?":                       NewBuiltIn("char-set?", 1, 1, charset_),
+		"circular-list":                   NewBuiltIn("circular-list", 1, -1, circularlist),
+		"circular-list?":                  NewBuiltIn("circular-list?", 1, 1, circularlist_),
+		"close":                           NewBuiltIn("close", 1, 1, sclose),
+		"complex?":                        NewBuiltIn("complex?", 1, 1, complex_),
+		"car":                             NewBuiltIn("car", 1, 1, car),
+		"cdr":                             NewBuiltIn("cdr", 1, 1, cdr),
+		"chan":                            NewBuiltIn("chan", 0, 0, schan),
+		"char->bytes":                     NewBuiltIn("char->bytes", 1, 1, chartobytes),
+		"char->integer":                   NewBuiltIn("char->integer", 1, 1, chartoint),
+		"char-alphabetic?":                NewBuiltIn("char-alphabetic?", 1, 1, charalpha_),
+		"char-downcase":                   NewBuiltIn("char-downcase", 1, 1, chardown),
+		"char-foldcase":                   NewBuiltIn("char-foldcase", 1, 1, charfoldcase),
+		"char-general-category":           NewBuiltIn("char-general-category", 1, 1, chargeneralcategory),
+		"char-lower-case?":                NewBuiltIn("char-lower-case?", 1, 1, charlower_),
+		"char-numeric?":                   NewBuiltIn("char-numeric?", 1, 1, charnumeric_),
+		"char-upcase":                     NewBuiltIn("char-upcase", 1, 1, charup),
+		"char-upper-case?":                NewBuiltIn("char-upper-case?", 1, 1, charupper_),
+		"char-whitespace?":                NewBuiltIn("char-whitespace?", 1, 1, charwhitespace_),
+		"close-input-port":                NewBuiltIn("close-input-port", 1, 1, closeinport),
+		"close-output-port":               NewBuiltIn("close-output-port", 1, 1, closeoutport),
+		"close-port":                      NewBuiltIn("close-port", 1, 1, closeport),
+		"concatenate":                     NewBuiltIn("concatenate", 1, 1, concatenate),
+		"concatenate!":                    NewBuiltIn("concatenate!", 1, 1, concatenate),
+		"cons":                            NewBuiltIn("cons", 2, 2, cons),
+		"cons*":                           NewBuiltIn("cons*", 1, -1, consstar),
+		"cos":                             NewBuiltIn("cos", 1, 1, cos),
+		"count":                           NewBuiltIn("count", 2, -1, count),
+		"current-input-port":              newInputPort(os.Stdin, os.Stdin),
+		"current-output-port":             stdout,
+		"current-readtable":               newReadtable(),
+		"define-dispatch-macro":           NewBuiltIn("define-dispatch-macro", 2, 3, definedispatchmacro),
+		"define-reader-macro":             NewBuiltIn("define-reader-macro", 2, 3, definereadermacro),
+		"delete":                          NewBuiltIn("delete", 2, 3, delete_),
+		"delete!":                         NewBuiltIn("delete!", 2, 3, delete_),
+		"delete-duplicates":               NewBuiltIn("delete-duplicates", 1, 2, deleteduplicates),
+		"delete-duplicates!":              NewBuiltIn("delete-duplicates!", 1, 2, deleteduplicates),
+		"digit-value":                     NewBuiltIn("digit-value", 1, 1, digitvalue),
+		"display":                         NewBuiltIn("display", 1, 2, display),
+		"dotted-list?":                    NewBuiltIn("dotted-list?", 1, 1, dottedlist_),
+		"drop":                            NewBuiltIn("drop", 2, 2, drop),
+		"drop-right":                      NewBuiltIn("drop-right", 2, 2, dropright),
+		"drop-right!":                     NewBuiltIn("drop-right!", 2, 2, dropright),
+		"drop-while":                      NewBuiltIn("drop-while", 2, 2, dropwhile),
+		"eighth":                          NewBuiltIn("eighth", 1, 1, nth("eighth", 7)),
+		"environment":                     NewBuiltIn("environment", 0, -1, environment),
+		"eof-object":                      NewBuiltIn("eof-object", 0, 0, eofobject),
+		"eof-object?":                     NewBuiltIn("eof-object?", 1, 1, eofobject_),
+		"every":                           NewBuiltIn("every", 2, -1, every),
+		"exp":                             NewBuiltIn("exp", 1, 1, exp),
+		"eq?":                             NewBuiltIn("eq?", 2, 2, eqv),
+		"equal?":                          NewBuiltIn("equal?", 2, 2, equal),
+		"eqv?":                            NewBuiltIn("eqv?", 2, 2, eqv),
+		"error":                           NewBuiltIn("error", 1, 1, serror),
+		"error?":                          NewBuiltIn("error?", 1, 1, error_),
+		"eval":                            NewBuiltIn("eval", 1, 2, eval),
+		"exit":                            NewBuiltIn("exit", 0, 1, exit),
+		"features":                        NewBuiltIn("features", 0, 0, features_),
+		"fifth":                           NewBuiltIn("fifth", 1, 1, nth("fifth", 4)),
+		"file-size":                       NewBuiltIn("file-size", 1, 1, filesize),
+		"filter":                          NewBuiltIn("filter", 2, 2, filter),
+		"filter!":                         NewBuiltIn("filter!", 2, 2, filter),
+		"filter-map":                      NewBuiltIn("filter-map", 2, -1, filtermap),
+		"find":                            NewBuiltIn("find", 2, 2, find),
+		"find-tail":                       NewBuiltIn("find-tail", 2, 2, findtail),
+		"first":                           NewBuiltIn("first", 1, 1, nth("first", 0)),
+		"floor":                           NewBuiltIn("floor", 1, 1, floor),
+		"fold":                            NewBuiltIn("fold", 3, -1, fold),
+		"fold-left":                       NewBuiltIn("fold-left", 3, -1, fold),
+		"fold-right":                      NewBuiltIn("fold-right", 3, -1, foldright),
+		"for-each":                        NewBuiltIn("for-each", 2, -1, foreach),
+		"format":                          NewBuiltIn("format", 1, -1, format),
+		"fourth":                          NewBuiltIn("fourth", 1, 1, nth("fourth", 3)),
+		"generate-uninterned-symbol":      NewBuiltIn("generate-uninterned-symbol", 0, 1, generateuninternedsym),
+		"get-output-bytevector":           NewBuiltIn("get-output-bytevector", 1, 1, getoutputbytevector),
+		"get-output-string":               NewBuiltIn("get-output-string", 1, 1, getoutputstring),
+		"flush":                           NewBuiltIn("flush", 0, 1, flush),
+		"hash":                            NewBuiltIn("hash", 1, 2, hash_),
+		"hash-by-identity":                NewBuiltIn("hash-by-identity", 1, 2, hashbyidentity),
+		"hash-table?":                     NewBuiltIn("hash-table?", 1, 1, hashtable_),
+		"hash-table->alist":               NewBuiltIn("hash-table->alist", 1, 1, hashtabletoalist),
+		"hash-table-clear!":               NewBuiltIn("hash-table-clear!", 1, 1, hashtableclear),
+		"hash-table-contains?":            NewBuiltIn("hash-table-contains?", 2, 2, hashtablecontains),
+		"hash-table-copy":                 NewBuiltIn("hash-table-copy", 1, 2, hashtablecopy),
+		"hash-table-delete!":              NewBuiltIn("hash-table-delete!", 1, -1, hashtabledelete),
+		"hash-table-equivalence-function": NewBuiltIn("hash-table-equivalence-function", 1, 1, hashtableequivfn),
+		"hash-table-exists?":              NewBuiltIn("hash-table-exists?", 2, 2, hashtablecontains),
+		"hash-table-fold":                 NewBuiltIn("hash-table-fold", 3, 3, hashtablefold),
+		"hash-table-hash-function":        NewBuiltIn("hash-table-hash-function", 1, 1, hashtablehashfn),
+		"hash-table-keys":                 NewBuiltIn("hash-table-keys", 1, 1, hashtablekeys),
+		"hash-table-ref":                  NewBuiltIn("hash-table-ref", 2, 4, hashtableref),
+		"hash-table-ref/default":          NewBuiltIn("hash-table-ref/default", 3, 3, hashtablerefdefault),
+		"hash-table-set!":                 NewBuiltIn("hash-table-set!", 1, -1, hashtableset),
+		"hash-table-size":                 NewBuiltIn("hash-table-size", 1, 1, hashtablesize),
+		"hash-table-update!":              NewBuiltIn("hash-table-update!", 3, 5, hashtableupdate),
+		"hash-table-update!/default":      NewBuiltIn("hash-table-update!/default", 4, 4, hashtableupdatedefault),
+		"hash-table-values":               NewBuiltIn("hash-table-values", 1, 1, hashtablevalues),
+		"hash-table-walk":                 NewBuiltIn("hash-table-walk", 2, 2, hashtablewalk),
+		"imag-part":                       NewBuiltIn("imag-part", 1, 1, imagpart),
+		"input-port?":                     NewBuiltIn("input-port?", 1, 1, inputport_),
+		"integer->char":                   NewBuiltIn("integer->char", 1, 1, inttochar),
+		"integer?":                        NewBuiltIn("integer?", 1, 1, integer_),
+		"interaction-environment":         NewBuiltIn("interaction-environment", 0, 0, interactionEnv),
+		"iota":                            NewBuiltIn("iota", 1, 3, iota_),
+		"join":                            NewBuiltIn("join", 1, -1, join),
+		"last":                            NewBuiltIn("last", 1, 1, last),
+		"last-pair":                       NewBuiltIn("last-pair", 1, 1, lastpair),
+		"length":                          NewBuiltIn("length", 1, 1, length),
+		"length+":                         NewBuiltIn("length+", 1, 1, lengthplus),
+		"library-path":                    SliceToExprList(append([]Expr{NewString(".")}, environmentPath()...)),
+		"list":                            NewBuiltIn("list", 0, -1, list),
+		"list->vector":                    NewBuiltIn("list->vector", 1, 3, listtovector),
+		"list-copy":                       NewBuiltIn("list-copy", 1, 1, listcopy),
+		"list-index":                      NewBuiltIn("list-index", 2, -1, listindex),
+		"list-ref":                        NewBuiltIn("list-ref", 2, 2, listref),
+		"list-tabulate":                   NewBuiltIn("list-tabulate", 2, 2, listtabulate),
+		"list-tail":                       NewBuiltIn("list-tail", 2, 2, listtail),
+		"list=":                           NewBuiltIn("list=", 1, -1, listeq),
+		"list?":                           NewBuiltIn("list?", 1, 1, list_),
+		"list->string":                    NewBuiltIn("list->string", 1, 1, listtostr),
+		"list->char-set":                  NewBuiltIn("list->char-set", 1, 2, listtocharset),
+		"list-delete-neighbor-dups":       NewBuiltIn("list-delete-neighbor-dups", 2, 2, listdeleteneighbordups),
+		"list-merge":                      NewBuiltIn("list-merge", 3, 3, listmerge),
+		"list-sort":                       NewBuiltIn("list-sort", 2, 2, listsort),
+		"list-sorted?":                    NewBuiltIn("list-sorted?", 2, 2, listsorted),
+		"list-stable-sort":                NewBuiltIn("list-stable-sort", 2, 2, listsort),
+		"load":                            NewBuiltIn("load", 1, 1, load),
+		"load-path":                       SliceToExprList(environmentPath()),
+		"load-verbose":                    Boolean(true),
+		"log":                             NewBuiltIn("log", 1, 1, log),
+		"lset-adjoin":                     NewBuiltIn("lset-adjoin", 2, -1, lsetadjoin),
+		"lset-diff+intersection":          NewBuiltIn("lset-diff+intersection", 2, -1, lsetdiffintersection),
+		"lset-diff+intersection!":         NewBuiltIn("lset-diff+intersection!", 2, -1, lsetdiffintersection),
+		"lset-difference":                 NewBuiltIn("lset-difference", 2, -1, lsetdifference),
+		"lset-difference!":                NewBuiltIn("lset-difference!", 2, -1, lsetdifference),
+		"lset-intersection":               NewBuiltIn("lset-intersection", 2, -1, lsetintersection),
+		"lset-intersection!":              NewBuiltIn("lset-intersection!", 2, -1, lsetintersection),
+		"lset-union":                      NewBuiltIn("lset-union", 1, -1, lsetunion),
+		"lset-union!":                     NewBuiltIn("lset-union!", 1, -1, lsetunion),
+		"lset-xor":                        NewBuiltIn("lset-xor", 1, -1, lsetxor),
+		"lset-xor!":                       NewBuiltIn("lset-xor!", 1, -1, lsetxor),
+		"lset<=":                          NewBuiltIn("lset<=", 1, -1, lsetle),
+		"lset=":                           NewBuiltIn("lset=", 1, -1, lseteq),
+		"magnitude":                       NewBuiltIn("magnitude", 1, 1, magnitude),
+		"make-list":                       NewBuiltIn("make-list", 1, 2, makelist),
+		"make-polar":                      NewBuiltIn("make-polar", 2, 2, makepolar),
+		"make-bytevector":                 NewBuiltIn("make-bytevector", 1, 2, makebytevector),
+		"make-custom-input-port":          NewBuiltIn("make-custom-input-port", 1, 2, makecustominputport),
+		"make-custom-output-port":         NewBuiltIn("make-custom-output-port", 1, 2, makecustomoutputport),
+		"make-hash-table":                 NewBuiltIn("make-hash-table", 0, 2, makehashtable),
+		"make-pipe":                       NewBuiltIn("make-pipe", 0, 0, makepipe),
+		"make-readtable":                  NewBuiltIn("make-readtable", 0, 1, makereadtable),
+		"make-rectangular":                NewBuiltIn("make-rectangular", 2, 2, makerect),
+		"make-vector":                     NewBuiltIn("make-vector", 1, 2, makevec),
+		"map":                             NewBuiltIn("map", 2, -1, map_),
+		"map-in-order":                    NewBuiltIn("map-in-order", 2, -1, map_),
+		"member":                          NewBuiltIn("member", 2, 3, member),
+		"memq":                            NewBuiltIn("memq", 2, 2, memq),
+		"memv":                            NewBuiltIn("memv", 2, 2, memv),
+		"modulo":                          NewBuiltIn("modulo", 2, 2, modulo),
+		"newline":                         NewBuiltIn("newline", 0, 1, newline),
+		"ninth":                           NewBuiltIn("ninth", 1, 1, nth("ninth", 8)),
+		"not":                             NewBuiltIn("not", 1, 1, not),
+		"not-pair?":                       NewBuiltIn("not-pair?", 1, 1, notpair_),
+		"null-environment":                NewBuiltIn("null-environment", 0, 1, nullEnv),
+		"null-list?":                      NewBuiltIn("null-list?", 1, 1, nulllist_),
+		"null?":                           NewBuiltIn("null?", 1, 1, null_),
+		"number->string":                  NewBuiltIn("number->string", 1, 2, numtostr),
+		"number?":                         NewBuiltIn("number?", 1, 1, number_),
+		"open-file":                       NewBuiltIn("open-file", 1, -1, openfile),
+		"open-input-file":                 NewBuiltIn("open-input-file", 1, 1, openinfile),
+		"open-input-bytevector":           NewBuiltIn("open-input-bytevector", 1, 1, openinputbytevector),
+		"open-input-string":               NewBuiltIn("open-input-string", 1, 1, openinputstring),
+		"open-output-file":                NewBuiltIn("open-output-file", 1, 1, openoutfile),
+		"open-output-bytevector":          NewBuiltIn("open-output-bytevector", 0, 0, openoutputbytevector),
+		"open-output-string":              NewBuiltIn("open-output-string", 0, 0, openoutputstring),
+		"output-port?":                    NewBuiltIn("output-port?", 1, 1, outputport_),
+		"pair-fold":                       NewBuiltIn("pair-fold", 3, -1, pairfold),
+		"pair-fold-right":                 NewBuiltIn("pair-fold-right", 3, -1, pairfoldright),
+		"pair-for-each":                   NewBuiltIn("pair-for-each", 2, -1, pairforeach),
+		"pair?":                           NewBuiltIn("pair?", 1, 1, pair_),
+		"partition":                       NewBuiltIn("partition", 2, 2, partition),
+		"partition!":                      NewBuiltIn("partition!", 2, 2, partition),
+		"peek-char":                       NewBuiltIn("peek-char", 0, 1, peekchar),
+		"peek-u8":                         NewBuiltIn("peek-u8", 0, 1, peeku8),
+		"port->list":                      NewBuiltIn("port->list", 0, 2, porttolist),
+		"port-buffering":                  NewBuiltIn("port-buffering", 1, 1, portbuffering),
+		"port-fold":                       NewBuiltIn("port-fold", 2, 4, portfold),
+		"port-position":                   NewBuiltIn("port-position", 1, 1, portposition),
+		"port-for-each-line":              NewBuiltIn("port-for-each-line", 1, 2, portforeachline),
 		//"pmap": NewBuiltIn("pmap", 2, -1, pmap),
-		"procedure?":     NewBuiltIn("procedure?", 1, 1, procedure_),
-		"real-part":      NewBuiltIn("real-part", 1, 1, realpart),
-		"read-bytes":     NewBuiltIn("read-bytes", 1, 2, readbytes),
-		"read-char":      NewBuiltIn("read-char", 0, 1, readchar),
-		"remainder":      NewBuiltIn("remainder", 2, 2, remainder),
-		"round":          NewBuiltIn("round", 1, 1, round),
-		"sin":            NewBuiltIn("sin", 1, 1, sin),
-		"sleep":          NewBuiltIn("sleep", 1, 1, sleep),
-		"sqrt":           NewBuiltIn("sqrt", 1, 1, sqrt),
-		"string->list":   NewBuiltIn("string->list", 1, 1, strtolist),
-		"string->number": NewBuiltIn("string->number", 1, 1, strtonum),
-		"string->symbol": NewBuiltIn("string->symbol", 1, 1, strtosym),
-		"string?":        NewBuiltIn("string?", 1, 1, string_),
-		"symbol->string": NewBuiltIn("symbol->string", 1, 1, symtostr),
-		"symbol?":        NewBuiltIn("symbol?", 1, 1, symbol_),
-		"tan":            NewBuiltIn("tan", 1, 1, tan),
-		"truncate":       NewBuiltIn("truncate", 1, 1, truncate),
-		"vector?":        NewBuiltIn("vector?", 1, 1, vector_),
-		"vector-length":  NewBuiltIn("vector-length", 1, 1, vectorlen),
-		"vector-ref":     NewBuiltIn("vector-ref", 2, 2, vectorref),
-		"vector-set!":    NewBuiltIn("vector-set!", 3, 3, vectorset),
-		"write":          NewBuiltIn("write", 1, 2, write),
-		"write-char":     NewBuiltIn("write-char", 1, 2, writechar),
+		"procedure?":                  NewBuiltIn("procedure?", 1, 1, procedure_),
+		"proper-list?":                NewBuiltIn("proper-list?", 1, 1, properlist_),
+		"provide":                     NewBuiltIn("provide", 1, 1, provide),
+		"read-all":                    NewBuiltIn("read-all", 0, 1, readall),
+		"procedure-arity":             NewBuiltIn("procedure-arity", 1, 1, procarity),
+		"procedure-documentation":     NewBuiltIn("procedure-documentation", 1, 1, procdoc),
+		"procedure-name":              NewBuiltIn("procedure-name", 1, 1, procname),
+		"procedure-source":            NewBuiltIn("procedure-source", 1, 1, procsource),
+		"readtable?":                  NewBuiltIn("readtable?", 1, 1, readtable_),
+		"real-part":                   NewBuiltIn("real-part", 1, 1, realpart),
+		"read":                        NewBuiltIn("read", 0, 1, read),
+		"read-bytes":                  NewBuiltIn("read-bytes", 1, 2, readbytes),
+		"read-bytevector":             NewBuiltIn("read-bytevector", 1, 2, readbytevector),
+		"read-bytevector!":            NewBuiltIn("read-bytevector!", 1, 4, readbytevector_),
+		"read-char":                   NewBuiltIn("read-char", 0, 1, readchar),
+		"read-line":                   NewBuiltIn("read-line", 0, 1, readline),
+		"read-string":                 NewBuiltIn("read-string", 1, 2, readstring),
+		"read-u8":                     NewBuiltIn("read-u8", 0, 1, readu8),
+		"reduce":                      NewBuiltIn("reduce", 3, 3, reduce),
+		"reduce-right":                NewBuiltIn("reduce-right", 3, 3, reduceright),
+		"regexp":                      NewBuiltIn("regexp", 1, 1, makeregexp),
+		"regexp-match":                NewBuiltIn("regexp-match", 2, 4, regexpmatch),
+		"regexp-match-positions":      NewBuiltIn("regexp-match-positions", 2, 4, regexpmatchpositions),
+		"regexp-replace":              NewBuiltIn("regexp-replace", 3, 3, regexpreplace),
+		"regexp-replace-all":          NewBuiltIn("regexp-replace-all", 3, 3, regexpreplaceall),
+		"regexp-search-all":           NewBuiltIn("regexp-search-all", 2, 4, regexpsearchall),
+		"regexp-split":                NewBuiltIn("regexp-split", 2, 2, regexpsplit),
+		"regexp?":                     NewBuiltIn("regexp?", 1, 1, regexp_),
+		"remainder":                   NewBuiltIn("remainder", 2, 2, remainder),
+		"remove":                      NewBuiltIn("remove", 2, 2, remove),
+		"remove!":                     NewBuiltIn("remove!", 2, 2, remove),
+		"require":                     NewBuiltIn("require", 1, 2, require),
+		"reverse":                     NewBuiltIn("reverse", 1, 1, reverse),
+		"reverse!":                    NewBuiltIn("reverse!", 1, 1, reverse),
+		"reverse-list->vector":        NewBuiltIn("reverse-list->vector", 1, 1, reverselisttovector),
+		"reverse-vector->list":        NewBuiltIn("reverse-vector->list", 1, 3, reversevectortolist),
+		"round":                       NewBuiltIn("round", 1, 1, round),
+		"second":                      NewBuiltIn("second", 1, 1, nth("second", 1)),
+		"set-port-buffering!":         NewBuiltIn("set-port-buffering!", 2, 2, setportbuffering),
+		"set-port-position!":          NewBuiltIn("set-port-position!", 2, 2, setportposition),
+		"seventh":                     NewBuiltIn("seventh", 1, 1, nth("seventh", 6)),
+		"sin":                         NewBuiltIn("sin", 1, 1, sin),
+		"sixth":                       NewBuiltIn("sixth", 1, 1, nth("sixth", 5)),
+		"sleep":                       NewBuiltIn("sleep", 1, 1, sleep),
+		"some?":                       NewBuiltIn("some?", 2, 2, some_),
+		"sort":                        NewBuiltIn("sort", 2, 2, sort_),
+		"span":                        NewBuiltIn("span", 2, 2, span),
+		"span!":                       NewBuiltIn("span!", 2, 2, span),
+		"split":                       NewBuiltIn("split", 2, 2, split),
+		"split-at":                    NewBuiltIn("split-at", 2, 2, splitat),
+		"split-at!":                   NewBuiltIn("split-at!", 2, 2, splitat),
+		"sqrt":                        NewBuiltIn("sqrt", 1, 1, sqrt),
+		"string->list":                NewBuiltIn("string->list", 1, 1, strtolist),
+		"string->number":              NewBuiltIn("string->number", 1, 1, strtonum),
+		"string->symbol":              NewBuiltIn("string->symbol", 1, 1, strtosym),
+		"string->char-set":            NewBuiltIn("string->char-set", 1, 2, stringtocharset),
+		"string->uninterned-symbol":   NewBuiltIn("string->uninterned-symbol", 1, 1, strtouninternedsym),
+		"string->utf8":                NewBuiltIn("string->utf8", 1, 3, stringtoutf8),
+		"string->vector":              NewBuiltIn("string->vector", 1, 3, stringtovector),
+		"string-hash":                 NewBuiltIn("string-hash", 1, 2, stringhash),
+		"string-append":               NewBuiltIn("string-append", 0, -1, stringappend),
+		"string-contains":             NewBuiltIn("string-contains", 2, 4, stringcontains),
+		"string-cursor->index":        NewBuiltIn("string-cursor->index", 2, 2, stringcursortoindex),
+		"string-cursor-back":          NewBuiltIn("string-cursor-back", 2, 3, stringcursorback),
+		"string-cursor-diff":          NewBuiltIn("string-cursor-diff", 1, 3, stringcursordiff),
+		"string-cursor-end":           NewBuiltIn("string-cursor-end", 1, 1, stringcursorend),
+		"string-cursor-forward":       NewBuiltIn("string-cursor-forward", 2, 3, stringcursorforward),
+		"string-cursor-next":          NewBuiltIn("string-cursor-next", 2, 2, stringcursornext),
+		"string-cursor-prev":          NewBuiltIn("string-cursor-prev", 2, 2, stringcursorprev),
+		"string-cursor-ref":           NewBuiltIn("string-cursor-ref", 2, 2, stringcursorref),
+		"string-cursor-start":         NewBuiltIn("string-cursor-start", 1, 1, stringcursorstart),
+		"string-copy":                 NewBuiltIn("string-copy", 1, 3, stringcopy),
+		"string-copy!":                NewBuiltIn("string-copy!", 3, 5, stringcopy_),
+		"string-downcase":             NewBuiltIn("string-downcase", 1, 1, stringdowncase),
+		"string-fill!":                NewBuiltIn("string-fill!", 2, 4, stringfill),
+		"string-foldcase":             NewBuiltIn("string-foldcase", 1, 1, stringfoldcase),
+		"string-for-each":             NewBuiltIn("string-for-each", 2, -1, stringforeach),
+		"string-for-each-cursor":      NewBuiltIn("string-for-each-cursor", 2, 4, stringforeachcursor),
+		"string-index":                NewBuiltIn("string-index", 2, 4, stringindex),
+		"string-index->cursor":        NewBuiltIn("string-index->cursor", 2, 2, stringindextocursor),
+		"string-index-right":          NewBuiltIn("string-index-right", 2, 4, stringindexright),
+		"string-join":                 NewBuiltIn("string-join", 1, 3, stringjoin),
+		"string-length":               NewBuiltIn("string-length", 1, 1, stringlen),
+		"string-map":                  NewBuiltIn("string-map", 2, -1, stringmap),
+		"string-pad":                  NewBuiltIn("string-pad", 2, 5, stringpad),
+		"string-pad-right":            NewBuiltIn("string-pad-right", 2, 5, stringpadright),
+		"string-prefix?":              NewBuiltIn("string-prefix?", 2, 2, stringprefix_),
+		"string-ref":                  NewBuiltIn("string-ref", 2, 2, stringref),
+		"string-replace":              NewBuiltIn("string-replace", 4, 6, stringreplace),
+		"string-reverse":              NewBuiltIn("string-reverse", 1, 3, stringreverse),
+		"string-set!":                 NewBuiltIn("string-set!", 3, 3, stringset),
+		"string-split":                NewBuiltIn("string-split", 2, 6, stringsplit),
+		"string-suffix?":              NewBuiltIn("string-suffix?", 2, 2, stringsuffix_),
+		"string-trim":                 NewBuiltIn("string-trim", 1, 4, stringtrim),
+		"string-trim-both":            NewBuiltIn("string-trim-both", 1, 4, stringtrimboth),
+		"string-trim-right":           NewBuiltIn("string-trim-right", 1, 4, stringtrimright),
+		"string-upcase":               NewBuiltIn("string-upcase", 1, 1, stringupcase),
+		"string=?":                    NewBuiltIn("string=?", 1, -1, stringeq),
+		"string?":                     NewBuiltIn("string?", 1, 1, string_),
+		"substring":                   NewBuiltIn("substring", 2, 3, substring),
+		"subvector":                   NewBuiltIn("subvector", 3, 3, subvector),
+		"symbol->string":              NewBuiltIn("symbol->string", 1, 1, symtostr),
+		"symbol=?":                    NewBuiltIn("symbol=?", 1, -1, symboleq),
+		"symbol?":                     NewBuiltIn("symbol?", 1, 1, symbol_),
+		"take":                        NewBuiltIn("take", 2, 2, take),
+		"take!":                       NewBuiltIn("take!", 2, 2, take),
+		"take-right":                  NewBuiltIn("take-right", 2, 2, takeright),
+		"take-while":                  NewBuiltIn("take-while", 2, 2, takewhile),
+		"take-while!":                 NewBuiltIn("take-while!", 2, 2, takewhile),
+		"tan":                         NewBuiltIn("tan", 1, 1, tan),
+		"tenth":                       NewBuiltIn("tenth", 1, 1, nth("tenth", 9)),
+		"third":                       NewBuiltIn("third", 1, 1, nth("third", 2)),
+		"truncate":                    NewBuiltIn("truncate", 1, 1, truncate),
+		"truncate-file":               NewBuiltIn("truncate-file", 1, 2, truncatefile),
+		"ucs-range->char-set":         NewBuiltIn("ucs-range->char-set", 2, 2, ucsrangetocharset),
+		"u8-ready?":                   NewBuiltIn("u8-ready?", 0, 1, u8ready_),
+		"unfold":                      NewBuiltIn("unfold", 4, 5, unfold),
+		"unfold-right":                NewBuiltIn("unfold-right", 4, 5, unfoldright),
+		"unzip1":                      NewBuiltIn("unzip1", 1, 1, unzip1),
+		"unzip2":                      NewBuiltIn("unzip2", 1, 1, unzip2),
+		"unzip3":                      NewBuiltIn("unzip3", 1, 1, unzip3),
+		"unzip4":                      NewBuiltIn("unzip4", 1, 1, unzip4),
+		"unzip5":                      NewBuiltIn("unzip5", 1, 1, unzip5),
+		"utf8->string":                NewBuiltIn("utf8->string", 1, 3, utf8tostring),
+		"vector":                      NewBuiltIn("vector", 0, -1, vector),
+		"vector->list":                NewBuiltIn("vector->list", 1, 3, vectortolist),
+		"vector->string":              NewBuiltIn("vector->string", 1, 3, vectortostring),
+		"vector-any":                  NewBuiltIn("vector-any", 2, -1, vectorany),
+		"vector-append":               NewBuiltIn("vector-append", 0, -1, vectorappend),
+		"vector-concatenate":          NewBuiltIn("vector-concatenate", 1, 1, vectorconcatenate),
+		"vector-copy":                 NewBuiltIn("vector-copy", 1, 3, vectorcopy),
+		"vector-copy!":                NewBuiltIn("vector-copy!", 3, 5, vectorcopy_),
+		"vector-count":                NewBuiltIn("vector-count", 2, -1, vectorcount),
+		"vector-cumulate":             NewBuiltIn("vector-cumulate", 3, 3, vectorcumulate),
+		"vector-empty?":               NewBuiltIn("vector-empty?", 1, 1, vectorempty_),
+		"vector-every":                NewBuiltIn("vector-every", 2, -1, vectorevery),
+		"vector-fill!":                NewBuiltIn("vector-fill!", 2, 4, vectorfill),
+		"vector-fold":                 NewBuiltIn("vector-fold", 3, -1, vectorfold),
+		"vector-fold-right":           NewBuiltIn("vector-fold-right", 3, -1, vectorfoldright),
+		"vector-for-each":             NewBuiltIn("vector-for-each", 2, -1, vectorforeach),
+		"vector-index":                NewBuiltIn("vector-index", 2, -1, vectorindex),
+		"vector-index-right":          NewBuiltIn("vector-index-right", 2, -1, vectorindexright),
+		"vector-map":                  NewBuiltIn("vector-map", 2, -1, vectormap),
+		"vector-map!":                 NewBuiltIn("vector-map!", 2, -1, vectormap_),
+		"vector-partition":            NewBuiltIn("vector-partition", 2, 2, vectorpartition),
+		"vector-reverse!":             NewBuiltIn("vector-reverse!", 1, 3, vectorreverse),
+		"vector-reverse-copy":         NewBuiltIn("vector-reverse-copy", 1, 3, vectorreversecopy),
+		"vector-skip":                 NewBuiltIn("vector-skip", 2, -1, vectorskip),
+		"vector-skip-right":           NewBuiltIn("vector-skip-right", 2, -1, vectorskipright),
+		"vector-swap!":                NewBuiltIn("vector-swap!", 3, 3, vectorswap),
+		"vector-unfold":               NewBuiltIn("vector-unfold", 2, -1, vectorunfold),
+		"vector-unfold-right":         NewBuiltIn("vector-unfold-right", 2, -1, vectorunfoldright),
+		"vector=":                     NewBuiltIn("vector=", 1, -1, vectoreq),
+		"vector?":                     NewBuiltIn("vector?", 1, 1, vector_),
+		"vector-length":               NewBuiltIn("vector-length", 1, 1, vectorlen),
+		"vector-ref":                  NewBuiltIn("vector-ref", 2, 2, vectorref),
+		"vector-set!":                 NewBuiltIn("vector-set!", 3, 3, vectorset),
+		"vector-binary-search":        NewBuiltIn("vector-binary-search", 3, 5, vectorbinarysearch),
+		"vector-delete-neighbor-dups": NewBuiltIn("vector-delete-neighbor-dups", 2, 4, vectordeleteneighbordups),
+		"vector-merge":                NewBuiltIn("vector-merge", 3, 3, vectormerge),
+		"vector-sort":                 NewBuiltIn("vector-sort", 2, 4, vectorsort),
+		"vector-sort!":                NewBuiltIn("vector-sort!", 2, 4, vectorsort_),
+		"vector-sorted?":              NewBuiltIn("vector-sorted?", 2, 4, vectorsorted),
+		"vector-stable-sort":          NewBuiltIn("vector-stable-sort", 2, 4, vectorsort),
+		"vector-stable-sort!":         NewBuiltIn("vector-stable-sort!", 2, 4, vectorsort_),
+		"with-output-to-string":       NewBuiltIn("with-output-to-string", 1, 1, withoutputtostring),
+		"write":                       NewBuiltIn("write", 1, 2, write),
+		"write-char":                  NewBuiltIn("write-char", 1, 2, writechar),
+		"write-shared":                NewBuiltIn("write-shared", 1, 2, writeshared),
+		"write-simple":                NewBuiltIn("write-simple", 1, 2, writesimple),
+		"write-string":                NewBuiltIn("write-string", 1, 4, writestring),
+		"write-bytevector":            NewBuiltIn("write-bytevector", 1, 4, writebytevector),
+		"write-u8":                    NewBuiltIn("write-u8", 1, 2, writeu8),
+		"xcons":                       NewBuiltIn("xcons", 2, 2, xcons),
+		"zip":                         NewBuiltIn("zip", 1, -1, zip),
 		//TODO: eq?
-	}, map[Symbol]transformer{}, nil}
+	}, map[Symbol]transformer{}, nil, false}
+	for name, cs := range standardCharSets {
+		e.Local["char-set:"+name] = cs
+	}
+	for _, k := range numKinds {
+		for _, p := range k.procs() {
+			e.Local[p.name] = p
+		}
+	}
 	dirc, err := ioutil.ReadDir("std")
 	if err != nil {
 		panic("Error while loading standard library")
@@ -166,19 +502,14 @@ func StandardEnv() Environment {
 		if !strings.HasSuffix(fi.Name(), ".scm") {
 			continue
 		}
-		in, err := ioutil.ReadFile("std/" + fi.Name())
+		f, err := os.Open("std/" + fi.Name())
 		if err != nil {
 			panic("Error while loading standard library")
 		}
-		ins := string(in)
-		t := Tokenize(ins)
-		for len(t) != 0 {
-			r := Eval(Parse(&t, true), e)
-			if s, ok := r.(Symbol); !ok || string(s) != "" {
-				fmt.Println(r)
-			}
-		}
+		evalReader(newInputPort(f, nopCloser{}), e, true)
+		f.Close()
 	}
+	registerStandardLibraries(e)
 	return e
 }
 
@@ -330,6 +661,28 @@ func chartobytes(e Environment, args ...Expr) Expr {
 	}
 }
 
+//Returns the value of a decimal digit char (general category Nd), or #f if
+//the char is not a digit.
+func digitvalue(e Environment, args ...Expr) Expr {
+	v, ok := args[0].(Character)
+	if !ok {
+		return Error{"digit-value: Argument 1 is not a char."}
+	}
+	//Every range in the Nd table is made up of whole blocks of ten digits
+	//starting at zero.
+	for _, r := range unicode.Nd.R16 {
+		if rune(v) >= rune(r.Lo) && rune(v) <= rune(r.Hi) {
+			return Number((rune(v) - rune(r.Lo)) % 10)
+		}
+	}
+	for _, r := range unicode.Nd.R32 {
+		if rune(v) >= rune(r.Lo) && rune(v) <= rune(r.Hi) {
+			return Number((rune(v) - rune(r.Lo)) % 10)
+		}
+	}
+	return Boolean(false)
+}
+
 func chartoint(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(Character); !ok {
 		return Error{"char->integer: Argument 1 is not a character."}
@@ -343,14 +696,13 @@ func charready_(e Environment, args ...Expr) Expr {
 	if len(args) == 1 {
 		ep = args[0]
 	} else {
-		ep = e.Local["current-input-port"]
+		ep = currentPort(e, "current-input-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.r == nil {
 		return Error{"char-ready?: Not an input port."}
 	}
-	_, err := p.r.Peek(1)
-	return Boolean(err == nil)
+	return Boolean(p.ready())
 }
 
 func char_(e Environment, args ...Expr) Expr {
@@ -374,6 +726,31 @@ func chardown(e Environment, args ...Expr) Expr {
 	}
 }
 
+func charfoldcase(e Environment, args ...Expr) Expr {
+	if v, ok := args[0].(Character); !ok {
+		return Error{"char-foldcase: Argument 1 is not a char."}
+	} else {
+		return Character(foldRune(rune(v)))
+	}
+}
+
+//Returns the two letter Unicode general category of the char as a symbol,
+//e.g. Lu for upper case letters. Unassigned code points are Cn.
+func chargeneralcategory(e Environment, args ...Expr) Expr {
+	v, ok := args[0].(Character)
+	if !ok {
+		return Error{"char-general-category: Argument 1 is not a char."}
+	}
+	for name, t := range unicode.Categories {
+		//Skips the major categories (L) and the LC group, which overlap the
+		//categories we want.
+		if len(name) == 2 && unicode.IsLower(rune(name[1])) && unicode.Is(t, rune(v)) {
+			return Intern(name)
+		}
+	}
+	return Intern("Cn")
+}
+
 func charlower_(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(Character); !ok {
 		return Error{"char-lower-case?: Argument 1 is not a char."}
@@ -433,7 +810,7 @@ func serror(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(String); !ok {
 		return Error{"error: Argument 1 is not a string."}
 	} else {
-		return Error{string(v)}
+		return Error{unwrapString(v)}
 	}
 }
 
@@ -461,7 +838,7 @@ func filesize(e Environment, args ...Expr) Expr {
 	if s, ok := args[0].(String); !ok {
 		return Error{"file-size: Argument 1 is not a string."}
 	} else {
-		fi, err := os.Stat(string(s))
+		fi, err := os.Stat(unwrapString(s))
 		if err != nil {
 			return Error{err.Error()}
 		}
@@ -476,13 +853,15 @@ func flush(e Environment, args ...Expr) Expr {
 	if len(args) == 1 {
 		ep = args[0]
 	} else {
-		ep = e.Local["current-output-port"]
+		ep = currentPort(e, "current-output-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.w == nil {
 		return Error{"flush: Not an output port."}
 	}
-	p.w.Flush()
+	if err := p.flush(); err != nil {
+		return Error{err.Error()}
+	}
 	return Boolean(true)
 }
 
@@ -539,7 +918,7 @@ func listtostr(e Environment, args ...Expr) Expr {
 		c := v.(Character)
 		s[i] = rune(c)
 	}
-	return String(s)
+	return runesToString(s)
 }
 
 func newline(e Environment, args ...Expr) Expr {
@@ -547,13 +926,15 @@ func newline(e Environment, args ...Expr) Expr {
 	if len(args) == 1 {
 		ep = args[0]
 	} else {
-		ep = e.Local["current-output-port"]
+		ep = currentPort(e, "current-output-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.w == nil {
 		return Error{"newline: Not an output port."}
 	}
-	fmt.Fprintln(p.w, "")
+	if err := p.writeString("\n"); err != nil {
+		return Error{err.Error()}
+	}
 	return Boolean(true)
 }
 
@@ -572,12 +953,25 @@ func nullEnv(e Environment, args ...Expr) Expr {
 	return R5RSNullEnv()
 }
 
+//(number->string z [radix]) only accepts a radix other than 10 for integers.
 func numtostr(e Environment, args ...Expr) Expr {
-	if v, ok := args[0].(Number); !ok {
+	v, ok := args[0].(Number)
+	if !ok {
 		return Error{"number->string: Argument 1 is not a number."}
-	} else {
-		return String(strconv.FormatFloat(float64(v), 'f', -1, 64))
 	}
+	radix := 10
+	if len(args) == 2 {
+		r, ok := args[1].(Number)
+		if !ok || (r != 2 && r != 8 && r != 10 && r != 16) {
+			return Error{"number->string: Argument 2 is not a radix (2, 8, 10 or 16)."}
+		}
+		radix = int(r)
+	}
+	s, err := formatRadix("number->string", float64(v), radix)
+	if err != nil {
+		return err
+	}
+	return NewString(s)
 }
 
 func number_(e Environment, args ...Expr) Expr {
@@ -589,11 +983,11 @@ func openinfile(e Environment, args ...Expr) Expr {
 	if s, ok := args[0].(String); !ok {
 		return Error{"open-input-file: Argument 1 is not a string."}
 	} else {
-		f, err := os.Open(string(s))
+		f, err := os.Open(unwrapString(s))
 		if err != nil {
 			return Error{err.Error()}
 		}
-		return Port{f, nil, bufio.NewReader(f), nil}
+		return newInputPort(f, f)
 	}
 }
 
@@ -601,11 +995,11 @@ func openoutfile(e Environment, args ...Expr) Expr {
 	if s, ok := args[0].(String); !ok {
 		return Error{"open-output-file: Argument 1 is not a string."}
 	} else {
-		f, err := os.Create(string(s))
+		f, err := os.Create(unwrapString(s))
 		if err != nil {
 			return Error{err.Error()}
 		}
-		return Port{nil, f, nil, bufio.NewWriter(f)}
+		return newOutputPort(f, f)
 	}
 }
 
@@ -622,18 +1016,18 @@ func pair_(e Environment, args ...Expr) Expr {
 func peekchar(e Environment, args ...Expr) Expr {
 	var p Port
 	if len(args) == 0 {
-		p, _ = e.Local["current-input-port"].(Port)
+		p, _ = currentPort(e, "current-input-port").(Port)
 	} else if p2, ok := args[0].(Port); !ok {
 		return Error{"peek-char: Argument 1 is not a port."}
 	} else {
 		p = p2
 	}
-	if p.r == nil {
+	if p.port == nil || p.r == nil {
 		return Error{"peek-char: Not an input port."}
 	}
 	r, _, err := p.r.ReadRune()
 	if err != nil {
-		return Error{err.Error()}
+		return readFailure(err)
 	}
 	p.r.UnreadRune()
 	return Character(r)
@@ -649,7 +1043,7 @@ func readbytes(e Environment, args ...Expr) Expr {
 	if len(args) == 2 {
 		ep = args[1]
 	} else {
-		ep = e.Local["current-input-port"]
+		ep = currentPort(e, "current-input-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.r == nil {
@@ -659,9 +1053,12 @@ func readbytes(e Environment, args ...Expr) Expr {
 		return Error{"read-bytes: Argument 1 is not a number."}
 	} else {
 		buf := make([]byte, int64(n))
-		p.r.Read(buf)
-		r := make([]Expr, int64(n))
-		for i, b := range buf {
+		read, err := io.ReadFull(p.r, buf)
+		if read == 0 && n > 0 {
+			return readFailure(err)
+		}
+		r := make([]Expr, read)
+		for i, b := range buf[:read] {
 			r[i] = Byte(b)
 		}
 		return SliceToExprList(r)
@@ -673,7 +1070,7 @@ func readchar(e Environment, args ...Expr) Expr {
 	if len(args) == 1 {
 		ep = args[0]
 	} else {
-		ep = e.Local["current-input-port"]
+		ep = currentPort(e, "current-input-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.r == nil {
@@ -681,7 +1078,7 @@ func readchar(e Environment, args ...Expr) Expr {
 	}
 	r, _, err := p.r.ReadRune()
 	if err != nil {
-		return Error{err.Error()}
+		return readFailure(err)
 	}
 	return Character(r)
 }
@@ -699,7 +1096,7 @@ func symtostr(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(Symbol); !ok {
 		return Error{"symbol->string: Argument 1 is not a symbol"}
 	} else {
-		return String(string(v))
+		return NewString(v.name)
 	}
 }
 
@@ -708,6 +1105,53 @@ func symbol_(e Environment, args ...Expr) Expr {
 	return Boolean(ok)
 }
 
+//(symbol=? symbol1 symbol2 ...) returns #t if all the symbols are the same.
+func symboleq(e Environment, args ...Expr) Expr {
+	for i, a := range args {
+		if _, ok := a.(Symbol); !ok {
+			return Error{"symbol=?: Argument " + strconv.Itoa(i+1) + " is not a symbol."}
+		}
+	}
+	for _, a := range args[1:] {
+		if a != args[0] {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+//(string->uninterned-symbol string) returns a new symbol named string that
+//is not eq? to any other symbol.
+func strtouninternedsym(e Environment, args ...Expr) Expr {
+	if v, ok := args[0].(String); !ok {
+		return Error{"string->uninterned-symbol: Argument 1 is not a string."}
+	} else {
+		return Symbol{&symbol{unwrapString(v)}}
+	}
+}
+
+//gensyms counts the symbols made by generate-uninterned-symbol, so that their
+//names are unique.
+var gensyms uint64
+
+//(generate-uninterned-symbol [prefix]) returns a new uninterned symbol whose
+//name is prefix, a string or symbol which defaults to g, followed by a number.
+func generateuninternedsym(e Environment, args ...Expr) Expr {
+	prefix := "g"
+	if len(args) > 0 {
+		switch v := args[0].(type) {
+		case String:
+			prefix = unwrapString(v)
+		case Symbol:
+			prefix = v.name
+		default:
+			return Error{"generate-uninterned-symbol: Argument 1 is not a string or a symbol."}
+		}
+	}
+	n := atomic.AddUint64(&gensyms, 1)
+	return Symbol{&symbol{prefix + strconv.FormatUint(n, 10)}}
+}
+
 func schan(e Environment, args ...Expr) Expr {
 	return Channel(make(chan Expr))
 }
@@ -741,46 +1185,17 @@ func closeoutport(e Environment, args ...Expr) Expr {
 		if p.w == nil {
 			return Error{"close-output-port: Not an output port."}
 		}
-		p.w.Flush()
+		p.flush()
+		p.mu.Lock()
 		p.w = nil
+		p.mu.Unlock()
+		unregisterOutputPort(p.port)
 		p.wclose.Close()
 		return Boolean(true)
 	}
 }
 
 //TODO: Could allow loading multiple files in one call.
-func load(e Environment, args ...Expr) Expr {
-	var s string
-	if v, ok := args[0].(Symbol); !ok {
-		if v2, ok2 := args[0].(String); !ok2 {
-			return Error{"load: Argument 1 is not a string"}
-		} else {
-			s = string(v2)
-		}
-	} else {
-		s = string(v)
-	}
-	fmt.Println("Reading file " + s + "...")
-	in, err := ioutil.ReadFile(s)
-	if err != nil && !strings.HasSuffix(s, ".scm") {
-		s += ".scm"
-		fmt.Println("Not found, reading file " + s + "...")
-		in, err = ioutil.ReadFile(s)
-		if err != nil {
-			return Boolean(false)
-		}
-	}
-	ins := string(in)
-	t := Tokenize(ins)
-	for len(t) != 0 {
-		r := Eval(Parse(&t, true), GlobalEnv)
-		if s, ok := r.(Symbol); !ok || string(s) != "" {
-			fmt.Println(r)
-		}
-	}
-	return Boolean(true)
-}
-
 func log(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(Number); !ok {
 		return Error{"log: Argument 1 is not a number"}
@@ -824,8 +1239,8 @@ func strtolist(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(String); !ok {
 		return Error{"string->list: Argument 1 is not a string."}
 	} else {
-		r := make([]Expr, len(v))
-		for i, c := range v {
+		r := make([]Expr, len(*v.runes))
+		for i, c := range *v.runes {
 			r[i] = Character(c)
 		}
 		return SliceToExprList(r)
@@ -836,7 +1251,7 @@ func strtonum(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(String); !ok {
 		return Error{"string->number: Argument 1 is not a string."}
 	} else {
-		r, err := strconv.ParseFloat(string(v), 64)
+		r, err := strconv.ParseFloat(unwrapString(v), 64)
 		if err != nil {
 			return Error{"string->number: Error parsing string."}
 		}
@@ -848,7 +1263,7 @@ func strtosym(e Environment, args ...Expr) Expr {
 	if v, ok := args[0].(String); !ok {
 		return Error{"string->symbol: Argument 1 is not a string."}
 	} else {
-		return Symbol(string(v))
+		return Intern(unwrapString(v))
 	}
 }
 
@@ -857,27 +1272,12 @@ func string_(e Environment, args ...Expr) Expr {
 	return Boolean(ok)
 }
 
-func write(e Environment, args ...Expr) Expr {
-	var ep Expr
-	if len(args) == 2 {
-		ep = args[1]
-	} else {
-		ep = e.Local["current-output-port"]
-	}
-	p, ok := ep.(Port)
-	if !ok || p.w == nil {
-		return Error{"write: Not an output port."}
-	}
-	fmt.Fprint(p.w, args[0])
-	return Boolean(true)
-}
-
 func writechar(e Environment, args ...Expr) Expr {
 	var ep Expr
 	if len(args) == 2 {
 		ep = args[1]
 	} else {
-		ep = e.Local["current-output-port"]
+		ep = currentPort(e, "current-output-port")
 	}
 	p, ok := ep.(Port)
 	if !ok || p.w == nil {
@@ -886,8 +1286,7 @@ func writechar(e Environment, args ...Expr) Expr {
 	if c, ok2 := args[0].(Character); !ok2 {
 		return Error{"write-char: Argument 1 is not a character."}
 	} else {
-		_, err := p.w.WriteRune(rune(c))
-		if err != nil {
+		if err := p.writeString(string(rune(c))); err != nil {
 			return Error{err.Error()}
 		}
 	}
@@ -0,0 +1,286 @@
+package goscheme
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//indexArg converts args[i] to an int in the range [0, max]. The returned Expr
+//is an Error if the argument is not a valid index and nil otherwise.
+func indexArg(name string, args []Expr, i, max int) (int, Expr) {
+	n, ok := args[i].(Number)
+	if !ok || float64(n) != float64(int(n)) {
+		return 0, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not an integer."}
+	}
+	if int(n) < 0 || int(n) > max {
+		return 0, Error{name + ": Index " + strconv.Itoa(int(n)) + " is out of range."}
+	}
+	return int(n), nil
+}
+
+//rangeArgs reads the optional start and end arguments starting at args[i],
+//defaulting to the whole sequence of length l.
+func rangeArgs(name string, args []Expr, i, l int) (int, int, Expr) {
+	start, end := 0, l
+	var err Expr
+	if len(args) > i {
+		if start, err = indexArg(name, args, i, l); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(args) > i+1 {
+		if end, err = indexArg(name, args, i+1, l); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start > end {
+		return 0, 0, Error{name + ": Start index is greater than end index."}
+	}
+	return start, end, nil
+}
+
+//stringArgs checks that every argument from args[from] onwards is a string and
+//returns their runes.
+func stringArgs(name string, args []Expr, from int) ([][]rune, Expr) {
+	ret := make([][]rune, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		s, ok := args[i].(String)
+		if !ok {
+			return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a string."}
+		}
+		ret = append(ret, *s.runes)
+	}
+	return ret, nil
+}
+
+func stringappend(e Environment, args ...Expr) Expr {
+	ss, err := stringArgs("string-append", args, 0)
+	if err != nil {
+		return err
+	}
+	l := 0
+	for _, s := range ss {
+		l += len(s)
+	}
+	r := make([]rune, 0, l)
+	for _, s := range ss {
+		r = append(r, s...)
+	}
+	return runesToString(r)
+}
+
+func stringcopy(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-copy: Argument 1 is not a string."}
+	}
+	start, end, err := rangeArgs("string-copy", args, 1, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	r := make([]rune, end-start)
+	copy(r, (*s.runes)[start:end])
+	return runesToString(r)
+}
+
+//(string-copy! to at from [start [end]])
+func stringcopy_(e Environment, args ...Expr) Expr {
+	to, ok := args[0].(String)
+	if !ok {
+		return Error{"string-copy!: Argument 1 is not a string."}
+	}
+	at, err := indexArg("string-copy!", args, 1, len(*to.runes))
+	if err != nil {
+		return err
+	}
+	from, ok := args[2].(String)
+	if !ok {
+		return Error{"string-copy!: Argument 3 is not a string."}
+	}
+	start, end, err := rangeArgs("string-copy!", args, 3, len(*from.runes))
+	if err != nil {
+		return err
+	}
+	if at+end-start > len(*to.runes) {
+		return Error{"string-copy!: Not enough room in destination string."}
+	}
+	//copy handles overlapping slices, so copying within one string is safe.
+	copy((*to.runes)[at:], (*from.runes)[start:end])
+	return to
+}
+
+func stringdowncase(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"string-downcase: Argument 1 is not a string."}
+	} else {
+		return NewString(strings.ToLower(unwrapString(s)))
+	}
+}
+
+func stringfill(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-fill!: Argument 1 is not a string."}
+	}
+	c, ok := args[1].(Character)
+	if !ok {
+		return Error{"string-fill!: Argument 2 is not a character."}
+	}
+	start, end, err := rangeArgs("string-fill!", args, 2, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	for i := start; i < end; i++ {
+		(*s.runes)[i] = rune(c)
+	}
+	return s
+}
+
+//foldRune does simple case folding. Going through upper case first makes
+//characters with several lower case forms (e.g. the Greek sigma) fold to the
+//same rune.
+func foldRune(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+func stringfoldcase(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"string-foldcase: Argument 1 is not a string."}
+	} else {
+		r := make([]rune, len(*s.runes))
+		for i, c := range *s.runes {
+			r[i] = foldRune(c)
+		}
+		return runesToString(r)
+	}
+}
+
+//Calls the procedure with the i:th character of each string, stopping at the
+//end of the shortest string. Used by both string-map and string-for-each.
+func stringiter(e Environment, name string, args []Expr, fn func(int, Expr) Expr) Expr {
+	p, ok := args[0].(Proc)
+	if !ok {
+		return Error{name + ": Argument 1 is not a procedure."}
+	}
+	ss, err := stringArgs(name, args, 1)
+	if err != nil {
+		return err
+	}
+	l := len(ss[0])
+	for _, s := range ss {
+		if len(s) < l {
+			l = len(s)
+		}
+	}
+	for i := 0; i < l; i++ {
+		cs := make([]Expr, len(ss))
+		for j, s := range ss {
+			cs[j] = Character(s[i])
+		}
+		r := callProc(e, p, cs...)
+		if _, ok := r.(Error); ok {
+			return r
+		}
+		if r = fn(i, r); r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+func stringforeach(e Environment, args ...Expr) Expr {
+	if err := stringiter(e, "string-for-each", args, func(int, Expr) Expr { return nil }); err != nil {
+		return err
+	}
+	return Boolean(true)
+}
+
+func stringlen(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"string-length: Argument 1 is not a string."}
+	} else {
+		return Number(len(*s.runes))
+	}
+}
+
+func stringmap(e Environment, args ...Expr) Expr {
+	var r []rune
+	err := stringiter(e, "string-map", args, func(i int, c Expr) Expr {
+		cc, ok := c.(Character)
+		if !ok {
+			return Error{"string-map: Procedure returned a non-character."}
+		}
+		r = append(r, rune(cc))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return runesToString(r)
+}
+
+func stringref(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-ref: Argument 1 is not a string."}
+	}
+	k, err := indexArg("string-ref", args, 1, len(*s.runes)-1)
+	if err != nil {
+		return err
+	}
+	return Character((*s.runes)[k])
+}
+
+func stringset(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"string-set!: Argument 1 is not a string."}
+	}
+	k, err := indexArg("string-set!", args, 1, len(*s.runes)-1)
+	if err != nil {
+		return err
+	}
+	c, ok := args[2].(Character)
+	if !ok {
+		return Error{"string-set!: Argument 3 is not a character."}
+	}
+	(*s.runes)[k] = rune(c)
+	return s
+}
+
+func stringupcase(e Environment, args ...Expr) Expr {
+	if s, ok := args[0].(String); !ok {
+		return Error{"string-upcase: Argument 1 is not a string."}
+	} else {
+		return NewString(strings.ToUpper(unwrapString(s)))
+	}
+}
+
+//string=? compares contents, since eqv? on strings compares identity.
+func stringeq(e Environment, args ...Expr) Expr {
+	ss, err := stringArgs("string=?", args, 0)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(ss); i++ {
+		if string(ss[i]) != string(ss[0]) {
+			return Boolean(false)
+		}
+	}
+	return Boolean(true)
+}
+
+func substring(e Environment, args ...Expr) Expr {
+	s, ok := args[0].(String)
+	if !ok {
+		return Error{"substring: Argument 1 is not a string."}
+	}
+	start, end, err := rangeArgs("substring", args, 1, len(*s.runes))
+	if err != nil {
+		return err
+	}
+	r := make([]rune, end-start)
+	copy(r, (*s.runes)[start:end])
+	return runesToString(r)
+}
@@ -0,0 +1,49 @@
+package goscheme
+
+import "testing"
+
+//Strings are mutable, and every reference to a string sees its changes.
+func TestStringMutation(t *testing.T) {
+	env := testEnv(t)
+	s := NewString("aaa")
+	env.Local["shared-string"] = s
+	defer delete(env.Local, "shared-string")
+	evalString(t, `(define copied (string-copy shared-string)) (string-set! shared-string 1 #\λ)`)
+	if got := unwrapString(s); got != "aλa" {
+		t.Errorf("string-set! changed the string to %q, want \"aλa\"", got)
+	}
+	if got := Sprint(evalString(t, "copied")); got != `"aaa"` {
+		t.Errorf("string-set! changed a copy of the string to %s", got)
+	}
+	if got := Sprint(evalString(t, `(define o (string-copy "abcdef")) (string-copy! o 2 o 0 4) o`)); got != `"ababcd"` {
+		t.Errorf("string-copy! between overlapping ranges gives %s, want \"ababcd\"", got)
+	}
+	if got := Sprint(evalString(t, `(define f (string-copy "abcdef")) (string-fill! f #\x 1 3) f`)); got != `"axxdef"` {
+		t.Errorf("string-fill! from 1 to 3 gives %s, want \"axxdef\"", got)
+	}
+}
+
+//Strings are indexed by character, not by byte.
+func TestStringIndexing(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`(string-length "héλlo")`, "5"},
+		{`(string-ref "héλlo" 2)`, `#\λ`},
+		{`(substring "héλlo" 1 3)`, `"éλ"`},
+		{`(string-append "a" "λ" "")`, `"aλ"`},
+		{`(string-map char-upcase "héλ")`, `"HÉΛ"`},
+		{`(string-map (lambda (a b) (if (char<? a b) a b)) "adc" "bbbb")`, `"abb"`},
+		{`(define acc '()) (string-for-each (lambda (a b) (set! acc (cons (string a b) acc))) "ab" "xyz") acc`, `("by" "ax")`},
+		{`(string-downcase "ΑΒΓ")`, `"αβγ"`},
+		{`(string-ref "abc" 3)`, "string-ref: Index 3 is out of range."},
+		{`(string-ref "abc" 1.5)`, "string-ref: Argument 2 is not an integer."},
+		{`(substring "abc" 2 1)`, "substring: Start index is greater than end index."},
+		{`(string-set! (string-copy "abc") 0 1)`, "string-set!: Argument 3 is not a character."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"math/cmplx"
 	"strconv"
+	"sync"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -18,9 +20,29 @@ type Environment struct {
 	Local       map[string]Expr
 	LocalSyntax map[Symbol]transformer
 	Parent      *Environment
+	//lexical is true in the environments of a library and of the procedures
+	//defined in it. A procedure made in such an environment looks names up
+	//where it was defined instead of where it is called. See libraryScope.
+	lexical bool
 }
 
 func (e Environment) isExpr() {}
+func (e Environment) String() string {
+	return Sprint(e)
+}
+
+//get returns the value bound to s, or nil if s is unbound. A variable imported
+//from a library is looked up in the library.
+func (e *Environment) get(s string) Expr {
+	scope := e.find(s)
+	if scope == nil {
+		return nil
+	}
+	if v, ok := scope[s].(importedVar); ok {
+		return v.scope.get(v.name)
+	}
+	return scope[s]
+}
 
 func (e *Environment) find(s string) map[string]Expr {
 	if e.Local[s] != nil {
@@ -41,7 +63,7 @@ func (e *Environment) copy() Environment {
 	for k, v := range e.LocalSyntax {
 		nsm[k] = v
 	}
-	return Environment{nm, nsm, e.Parent}
+	return Environment{nm, nsm, e.Parent, e.lexical}
 }
 
 /*
@@ -52,27 +74,79 @@ values right now. 64 bit float should be sufficiently accurate for large ints.
 type Number float64
 
 func (n Number) isExpr() {}
+func (n Number) String() string {
+	return Sprint(n)
+}
 func unwrapNumber(n Expr) float64 {
 	return float64(n.(Number))
 }
 
 /*
 Symbol type
-The type used for variables. Can also be used as strings as long as it doesn't
-contain spaces...
+The type used for variables. Symbols are interned, so every symbol with a
+given name is the same pointer and comparing symbols is a pointer comparison.
+An uninterned symbol is only ever equal to itself. Variables are looked up by
+name though, so an uninterned symbol used as a variable names the same
+variable as the interned symbol with its name.
 */
-type Symbol string
+type Symbol struct {
+	*symbol
+}
+
+type symbol struct {
+	name string
+}
+
+//symbols is the intern table, mapping names to symbols.
+var symbols sync.Map
+
+//Intern returns the interned symbol with the given name.
+func Intern(name string) Symbol {
+	if s, ok := symbols.Load(name); ok {
+		return s.(Symbol)
+	}
+	s, _ := symbols.LoadOrStore(name, Symbol{&symbol{name}})
+	return s.(Symbol)
+}
 
 func (s Symbol) isExpr() {}
+
+//Name returns the name of s.
+func (s Symbol) Name() string {
+	return s.name
+}
+
 func unwrapSymbol(s Expr) string {
-	return string(s.(Symbol))
+	return s.(Symbol).name
+}
+
+/*
+String type
+Strings are mutable, so the runes live behind a pointer that is shared by every
+copy of the value. Storing runes rather than a Go string keeps indexing O(1)
+for strings containing multi-byte characters.
+*/
+type String struct {
+	runes *[]rune
+}
+
+//NewString creates a new mutable String with the contents of s.
+func NewString(s string) String {
+	return runesToString([]rune(s))
 }
 
-type String string
+//runesToString wraps r without copying it.
+func runesToString(r []rune) String {
+	return String{&r}
+}
 
 func (s String) isExpr() {}
 func (s String) String() string {
-	return "\"" + string(s) + "\""
+	return Sprint(s)
+}
+
+func unwrapString(s Expr) string {
+	return string(*s.(String).runes)
 }
 
 type Boolean bool
@@ -85,6 +159,13 @@ func (b Boolean) String() string {
 	return "#f"
 }
 
+//truthy reports whether x counts as true in a conditional. Everything except
+//#f is true.
+func truthy(x Expr) bool {
+	b, ok := x.(Boolean)
+	return x != nil && (!ok || bool(b))
+}
+
 type Byte byte
 
 func (b Byte) isExpr() {}
@@ -92,16 +173,25 @@ func (b Byte) String() string {
 	return fmt.Sprintf("0x%x", byte(b))
 }
 
+//EOFObject is returned by input procedures when the port has no more input.
+type EOFObject struct{}
+
+func (o EOFObject) isExpr() {}
+func (o EOFObject) String() string {
+	return "#<eof>"
+}
+
 type Character rune
 
 func (c Character) isExpr() {}
 func (c Character) String() string {
-	return fmt.Sprintf("#\\%c", c)
+	return Sprint(c)
 }
 
 func decodeCharacter(s string) Character {
 	charMap := map[string]Character{
 		"nul":       Character(0x00),
+		"null":      Character(0x00),
 		"soh":       Character(0x01),
 		"stx":       Character(0x02),
 		"etx":       Character(0x03),
@@ -151,16 +241,28 @@ func decodeCharacter(s string) Character {
 		r, _ := utf8.DecodeRuneInString(s)
 		return Character(r)
 	}
+	//#\xHH... is a character given by its hexadecimal code point.
+	if len(s) > 1 && (s[0] == 'x' || s[0] == 'X') {
+		if r, err := strconv.ParseUint(s[1:], 16, 32); err == nil && r <= unicode.MaxRune {
+			return Character(r)
+		}
+	}
 	return charMap[s]
 }
 
 type Channel chan Expr
 
 func (c Channel) isExpr() {}
+func (c Channel) String() string {
+	return Sprint(c)
+}
 
 type Complex complex128
 
 func (c Complex) isExpr() {}
+func (c Complex) String() string {
+	return Sprint(c)
+}
 
 func complex_(e Environment, args ...Expr) Expr {
 	_, ok := args[0].(Complex)
@@ -228,6 +330,8 @@ func realpart(e Environment, args ...Expr) Expr {
 
 //An EvalBlock wraps an expression and delays evaluation of the expr.
 //Primarily(only?) used for actions involving apostrophes.
+//
+//Deprecated: Only the deprecated Parse makes EvalBlocks.
 type EvalBlock struct {
 	e Expr
 }
@@ -255,28 +359,14 @@ func (el ExprList) Length() int {
 }
 
 func (el ExprList) String() string {
-	var b bytes.Buffer
-	b.WriteString("(")
-	it := &el
-	for {
-		if it == nil || it.car == nil {
-			break
-		}
-		fmt.Fprint(&b, *it.car)
-		if it.cdr != nil && it.cdr.car != nil {
-			b.WriteString(" ")
-		}
-		it = it.cdr
-	}
-	b.WriteString(")")
-	return b.String()
+	return Sprint(el)
 }
 
 func ExprListToSlice(el ExprList) []Expr {
 	ret := make([]Expr, 0, el.Length())
 	it := &el
 	for {
-		if it.car == nil || it == nil {
+		if it == nil || it.car == nil {
 			break
 		}
 		ret = append(ret, *it.car)
@@ -297,19 +387,83 @@ func SliceToExprList(el []Expr) ExprList {
 /*
 A port can either be written to or read from.
 bufio.Reader/Writer is used for some conveniences, but ports also must be closeable.
-The assumption is that for input-ports w and wclose will be nil, and vice versa.
+The assumption is that for input-ports w and wclose will be nil, and vice versa,
+except for ports opened with open-file in read-write mode, which have both.
 If r != nil then rclose must also be != nil, same with w and wclose.
-While R5RS seems to only deal with files this implementation is vague enough that
-adding network support shouldn't be a big deal.
+While R5RS seems to only deal with files, any io.Reader or io.Writer can be
+made into a port with NewInputPort and NewOutputPort.
+The state is kept behind a pointer so that every copy of a Port sees it being
+closed.
 */
 type Port struct {
+	*port
+}
+
+type port struct {
 	rclose io.Closer
 	wclose io.Closer
 	r      *bufio.Reader
 	w      *bufio.Writer
+	//The reader behind r, used to tell if input is available.
+	src io.Reader
+	//The file or reader behind the port if it supports seeking, otherwise nil.
+	seek io.Seeker
+	//Serializes writes to the port. It is a pointer so that outputPorts can
+	//hold it without keeping the port alive.
+	mu        *sync.Mutex
+	buffering bufferMode
+	//The buffer that string and bytevector output ports write to.
+	out *bytes.Buffer
+	//Set by #!fold-case and cleared by #!no-fold-case in the text read from
+	//the port.
+	foldCase bool
 }
 
 func (p Port) isExpr() {}
+func (p Port) String() string {
+	return Sprint(p)
+}
+
+func newInputPort(r io.Reader, c io.Closer) Port {
+	s, _ := r.(io.Seeker)
+	return Port{&port{rclose: c, r: bufio.NewReader(r), src: r, seek: s}}
+}
+
+func newOutputPort(w io.Writer, c io.Closer) Port {
+	s, _ := w.(io.Seeker)
+	p := &port{wclose: c, w: bufio.NewWriter(w), seek: s, mu: &sync.Mutex{}}
+	switch w.(type) {
+	case *bytes.Buffer, *io.PipeWriter:
+	default:
+		registerOutputPort(p)
+	}
+	return Port{p}
+}
+
+//NewInputPort wraps r as an input port, so that anything implementing
+//io.Reader, like a network connection, can be read from Scheme. If r is also
+//an io.Closer it is closed when the port is.
+func NewInputPort(r io.Reader) Port {
+	if c, ok := r.(io.Closer); ok {
+		return newInputPort(r, c)
+	}
+	return newInputPort(r, nopCloser{})
+}
+
+//NewOutputPort wraps w as an output port. If w is also an io.Closer it is
+//closed when the port is.
+func NewOutputPort(w io.Writer) Port {
+	if c, ok := w.(io.Closer); ok {
+		return newOutputPort(w, c)
+	}
+	return newOutputPort(w, nopCloser{})
+}
+
+//nopCloser is used as the closer for ports that have nothing to release, like
+//string ports.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
 
 type Func func(...Expr) Expr
 
@@ -339,12 +493,40 @@ type UserProc struct {
 	//contain those args and the UserProc will be returned so the last arg can be fulfilled
 	partialArgs []Expr
 	body        Expr
+	//The name the procedure was first bound to by define, empty for anonymous procedures.
+	name string
+	//The docstring, if the lambda body started with a string literal.
+	doc string
+	//The lambda expression the procedure was created from.
+	source ExprList
 }
 
 func (u UserProc) isExpr() {}
 
 func (u UserProc) String() string {
-	return "<closure>"
+	var b bytes.Buffer
+	b.WriteString("#<procedure ")
+	if u.name != "" {
+		b.WriteString(u.name + " ")
+	}
+	params := ExprListToSlice(u.params)
+	if u.variadic && len(params) == 1 {
+		b.WriteString(Sprint(params[0]))
+	} else {
+		b.WriteString("(")
+		for i, p := range params {
+			if i != 0 {
+				b.WriteString(" ")
+			}
+			if u.variadic && i == len(params)-1 {
+				b.WriteString(". ")
+			}
+			b.WriteString(Sprint(p))
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(">")
+	return b.String()
 }
 
 func (u UserProc) eval(e Environment, args ...Expr) Expr {
@@ -353,6 +535,9 @@ func (u UserProc) eval(e Environment, args ...Expr) Expr {
 			e.Local[k] = v
 		}
 	}
+	if u.env.lexical {
+		e = libraryScope(u.env, e)
+	}
 	if len(args)+len(u.partialArgs) < u.params.Length() {
 		if !u.variadic || len(args) != u.params.Length()-1 {
 			for _, arg := range args {
@@ -396,6 +581,10 @@ type BuiltIn struct {
 
 func (b BuiltIn) isExpr() {}
 
+func (b BuiltIn) String() string {
+	return "#<procedure " + b.name + ">"
+}
+
 func (b BuiltIn) eval(e Environment, args ...Expr) Expr {
 	if len(args)+len(b.partialArgs) < b.minParams {
 		for _, arg := range args {
@@ -436,7 +625,7 @@ func replace(curr, last Expr, m map[Symbol]Match) (Expr, bool) {
 		if m[s].direct != nil {
 			return m[s].direct, true
 		}
-		if string(s) == "..." {
+		if s == Intern("...") {
 			lastSymbol, ok2 := last.(Symbol)
 			if !ok2 {
 				return nil, false
@@ -465,7 +654,7 @@ func replace(curr, last Expr, m map[Symbol]Match) (Expr, bool) {
 			}
 			lastExpr = v
 			//if v is an ellipsis the result will be a vector that should be expanded in place instead of replacing the variable
-			if s, ok2 := v.(Symbol); ok2 && string(s) == "..." {
+			if s, ok2 := v.(Symbol); ok2 && s == Intern("...") {
 				rel = append(rel, []Expr(e.(Vector))...)
 			} else {
 				rel = append(rel, e)
@@ -532,7 +721,7 @@ func match(p Pattern, literals map[Symbol]bool, env map[Symbol]Match, e Expr, el
 		return env, bool(eq(Environment{}, p, e).(Boolean))
 	} else {
 		if len(elSlice) < len(pelSlice) {
-			if ps, ok3 := pelSlice[len(pelSlice)-1].(Symbol); !ok3 || string(ps) != "..." {
+			if ps, ok3 := pelSlice[len(pelSlice)-1].(Symbol); !ok3 || ps != Intern("...") {
 				//There is no case where the input matches the pattern while also being shorter than the pattern (unless there is an ellipsis that matches nothing)
 				//From here on we can be a bit reckless with indexing into elSlice since we know it's at least as big as pelSlice, so any valid index in pelSlice is valid in elSlice.
 				return env, false
@@ -540,7 +729,7 @@ func match(p Pattern, literals map[Symbol]bool, env map[Symbol]Match, e Expr, el
 		}
 		if ps, ok3 := pelSlice[len(pelSlice)-2].(Symbol); ok3 {
 			//"P is of the form (P1 P2 ... Pn . Px) and F is a list or improper list of n or more elements whose first n elements match P1 through Pn and whose nth cdr matches Px,"
-			if string(ps) == "." {
+			if ps == Intern(".") {
 				//n = len(pelSlice)-2
 				for i, v := range pelSlice[:len(pelSlice)-2] {
 					nEnv, match := match(v.(Pattern), literals, env, elSlice[i], false)
@@ -555,7 +744,7 @@ func match(p Pattern, literals map[Symbol]bool, env map[Symbol]Match, e Expr, el
 		}
 		if ps, ok3 := pelSlice[len(pelSlice)-1].(Symbol); ok3 {
 			//"P is of the form (P1 ... Pn Px ...) and F is a proper list of n or more elements whose first n elements match P1 through Pn and whose remaining elements each match Px,"
-			if string(ps) == "..." {
+			if ps == Intern("...") {
 				//n = len(pelSlice)-2
 				for i, v := range pelSlice[:len(pelSlice)-1] {
 					nEnv, match := match(v.(Pattern), literals, env, elSlice[i], false)
@@ -1,33 +1,65 @@
 package goscheme
 
 import (
-	"bytes"
-	"fmt"
+	"strconv"
 )
 
+/*
+The R7RS vector procedures and the vector library from SRFI 133. Every index
+is checked, so an index out of range is an Error rather than a panic.
+Procedures that return two values in SRFI 133, like vector-partition, return a
+list of the two values.
+*/
+
 type Vector []Expr
 
 func (v Vector) isExpr() {}
 
 func (v Vector) String() string {
-	var b bytes.Buffer
-	fmt.Fprint(&b, "#(")
-	for i, e := range []Expr(v) {
-		fmt.Fprint(&b, e)
-		if i != len([]Expr(v))-1 {
-			fmt.Fprint(&b, " ")
+	return Sprint(v)
+}
+
+func vectorArg(name string, args []Expr, i int) (Vector, Expr) {
+	v, ok := args[i].(Vector)
+	if !ok {
+		return nil, Error{name + ": Argument " + strconv.Itoa(i+1) + " is not a vector."}
+	}
+	return v, nil
+}
+
+//vectorsArgs checks that every argument from args[from] onwards is a vector.
+func vectorsArgs(name string, args []Expr, from int) ([][]Expr, Expr) {
+	ret := make([][]Expr, 0, len(args)-from)
+	for i := from; i < len(args); i++ {
+		v, err := vectorArg(name, args, i)
+		if err != nil {
+			return nil, err
 		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+//procAndVectorRows reads a procedure followed by one or more vectors and
+//returns the rows of their elements, see rows.
+func procAndVectorRows(name string, args []Expr, from int) (Proc, [][]Expr, Expr) {
+	p, err := procArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	vs, err := vectorsArgs(name, args, from)
+	if err != nil {
+		return nil, nil, err
 	}
-	fmt.Fprint(&b, ")")
-	return b.String()
+	return p, rows(vs), nil
 }
 
 func makevec(e Environment, args ...Expr) Expr {
-	i, ok := args[0].(Number)
-	if !ok {
-		return Error{"make-vector: Argument 1 is not a number."}
+	n, err := countArg("make-vector", args, 0)
+	if err != nil {
+		return err
 	}
-	ret := make([]Expr, int(i))
+	ret := make([]Expr, n)
 	if len(args) == 2 {
 		for i := range ret {
 			ret[i] = args[1]
@@ -36,6 +68,68 @@ func makevec(e Environment, args ...Expr) Expr {
 	return Vector(ret)
 }
 
+//(list->vector list [start [end]])
+func listtovector(e Environment, args ...Expr) Expr {
+	l, err := listArg("list->vector", args, 0)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeArgs("list->vector", args, 1, len(l))
+	if err != nil {
+		return err
+	}
+	return Vector(l[start:end])
+}
+
+//(reverse-list->vector list)
+func reverselisttovector(e Environment, args ...Expr) Expr {
+	l, err := listArg("reverse-list->vector", args, 0)
+	if err != nil {
+		return err
+	}
+	reverseExprs(l)
+	return Vector(l)
+}
+
+//(reverse-vector->list vector [start [end]])
+func reversevectortolist(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("reverse-vector->list", args, 0)
+	if err != nil {
+		return err
+	}
+	l := append([]Expr{}, v...)
+	reverseExprs(l)
+	return SliceToExprList(l)
+}
+
+func reverseExprs(s []Expr) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+//(string->vector string [start [end]])
+func stringtovector(e Environment, args ...Expr) Expr {
+	r, start, end, err := stringAndRange("string->vector", args, 1)
+	if err != nil {
+		return err
+	}
+	ret := make(Vector, end-start)
+	for i, c := range r[start:end] {
+		ret[i] = Character(c)
+	}
+	return ret
+}
+
+//(subvector vector start end)
+func subvector(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("subvector", args, 0)
+	if err != nil {
+		return err
+	}
+	return append(Vector{}, v...)
+}
+
 func vector(e Environment, args ...Expr) Expr {
 	return Vector(args)
 }
@@ -45,6 +139,249 @@ func vector_(e Environment, args ...Expr) Expr {
 	return Boolean(ok)
 }
 
+//(vector-any pred vector ...) returns the first true value pred returns.
+func vectorany(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndVectorRows("vector-any", args, 1)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if v := callProc(e, pred, r...); truthy(v) {
+			return v
+		}
+	}
+	return Boolean(false)
+}
+
+//(vector-append vector ...)
+func vectorappend(e Environment, args ...Expr) Expr {
+	vs, err := vectorsArgs("vector-append", args, 0)
+	if err != nil {
+		return err
+	}
+	return Vector(concat(vs))
+}
+
+//(vector-concatenate list-of-vectors)
+func vectorconcatenate(e Environment, args ...Expr) Expr {
+	l, err := listArg("vector-concatenate", args, 0)
+	if err != nil {
+		return err
+	}
+	for _, x := range l {
+		if _, ok := x.(Vector); !ok {
+			return Error{"vector-concatenate: Argument 1 is not a list of vectors."}
+		}
+	}
+	return vectorappend(e, l...)
+}
+
+//(vector-copy vector [start [end]])
+func vectorcopy(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("vector-copy", args, 0)
+	if err != nil {
+		return err
+	}
+	return append(Vector{}, v...)
+}
+
+//(vector-copy! to at from [start [end]]) works even if to and from overlap.
+func vectorcopy_(e Environment, args ...Expr) Expr {
+	to, err := vectorArg("vector-copy!", args, 0)
+	if err != nil {
+		return err
+	}
+	at, err := indexArg("vector-copy!", args, 1, len(to))
+	if err != nil {
+		return err
+	}
+	from, err := vectorRangeArg("vector-copy!", args, 2)
+	if err != nil {
+		return err
+	}
+	if at+len(from) > len(to) {
+		return Error{"vector-copy!: Not enough room in destination vector."}
+	}
+	copy(to[at:], from)
+	return to
+}
+
+//(vector-count pred vector ...)
+func vectorcount(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndVectorRows("vector-count", args, 1)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, r := range rs {
+		ok, err := callPred(e, pred, r...)
+		if err != nil {
+			return err
+		}
+		if ok {
+			n++
+		}
+	}
+	return Number(n)
+}
+
+//(vector-cumulate f knil vector) returns the vector of the running results of
+//(f acc element).
+func vectorcumulate(e Environment, args ...Expr) Expr {
+	f, err := procArg("vector-cumulate", args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := vectorArg("vector-cumulate", args, 2)
+	if err != nil {
+		return err
+	}
+	ret := make(Vector, len(v))
+	acc := args[1]
+	for i, x := range v {
+		if acc = callProc(e, f, acc, x); isError(acc) {
+			return acc
+		}
+		ret[i] = acc
+	}
+	return ret
+}
+
+func vectorempty_(e Environment, args ...Expr) Expr {
+	v, err := vectorArg("vector-empty?", args, 0)
+	if err != nil {
+		return err
+	}
+	return Boolean(len(v) == 0)
+}
+
+//(vector= elt=? vector ...)
+func vectoreq(e Environment, args ...Expr) Expr {
+	eq, err := procArg("vector=", args, 0)
+	if err != nil {
+		return err
+	}
+	vs, err := vectorsArgs("vector=", args, 1)
+	if err != nil {
+		return err
+	}
+	ls := make([]Expr, len(vs))
+	for i, v := range vs {
+		ls[i] = SliceToExprList(v)
+	}
+	return listeq(e, append([]Expr{eq}, ls...)...)
+}
+
+//(vector-every pred vector ...) returns #f as soon as pred does, otherwise
+//the last value pred returns.
+func vectorevery(e Environment, args ...Expr) Expr {
+	pred, rs, err := procAndVectorRows("vector-every", args, 1)
+	if err != nil {
+		return err
+	}
+	var v Expr = Boolean(true)
+	for _, r := range rs {
+		if v = callProc(e, pred, r...); !truthy(v) {
+			return v
+		}
+	}
+	return v
+}
+
+//(vector-fill! vector fill [start [end]])
+func vectorfill(e Environment, args ...Expr) Expr {
+	v, err := vectorArg("vector-fill!", args, 0)
+	if err != nil {
+		return err
+	}
+	start, end, err := rangeArgs("vector-fill!", args, 2, len(v))
+	if err != nil {
+		return err
+	}
+	for i := start; i < end; i++ {
+		v[i] = args[1]
+	}
+	return v
+}
+
+//(vector-fold kons state vector ...) calls (kons state element ...) from left
+//to right.
+func vectorfold(e Environment, args ...Expr) Expr {
+	kons, rs, err := procAndVectorRows("vector-fold", args, 2)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for _, r := range rs {
+		if acc = callProc(e, kons, append([]Expr{acc}, r...)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+//(vector-fold-right kons state vector ...) is vector-fold from right to left.
+func vectorfoldright(e Environment, args ...Expr) Expr {
+	kons, rs, err := procAndVectorRows("vector-fold-right", args, 2)
+	if err != nil {
+		return err
+	}
+	acc := args[1]
+	for i := len(rs) - 1; i >= 0; i-- {
+		if acc = callProc(e, kons, append([]Expr{acc}, rs[i]...)...); isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+//(vector-for-each f vector ...)
+func vectorforeach(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndVectorRows("vector-for-each", args, 1)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if v := callProc(e, f, r...); isError(v) {
+			return v
+		}
+	}
+	return Boolean(true)
+}
+
+//vectorIndex returns the index of the first row, or the last if right is
+//true, for which pred returns want.
+func vectorIndex(e Environment, name string, want, right bool, args []Expr) Expr {
+	pred, rs, err := procAndVectorRows(name, args, 1)
+	if err != nil {
+		return err
+	}
+	for j := range rs {
+		i := j
+		if right {
+			i = len(rs) - 1 - j
+		}
+		ok, err := callPred(e, pred, rs[i]...)
+		if err != nil {
+			return err
+		}
+		if ok == want {
+			return Number(i)
+		}
+	}
+	return Boolean(false)
+}
+
+//(vector-index pred vector ...)
+func vectorindex(e Environment, args ...Expr) Expr {
+	return vectorIndex(e, "vector-index", true, false, args)
+}
+
+//(vector-index-right pred vector ...)
+func vectorindexright(e Environment, args ...Expr) Expr {
+	return vectorIndex(e, "vector-index-right", true, true, args)
+}
+
 func vectorlen(e Environment, args ...Expr) Expr {
 	v, ok := args[0].(Vector)
 	if !ok {
@@ -53,16 +390,88 @@ func vectorlen(e Environment, args ...Expr) Expr {
 	return Number(len([]Expr(v)))
 }
 
+//(vector-map f vector ...)
+func vectormap(e Environment, args ...Expr) Expr {
+	f, rs, err := procAndVectorRows("vector-map", args, 1)
+	if err != nil {
+		return err
+	}
+	ret := make(Vector, len(rs))
+	for i, r := range rs {
+		if ret[i] = callProc(e, f, r...); isError(ret[i]) {
+			return ret[i]
+		}
+	}
+	return ret
+}
+
+//(vector-map! f vector ...) stores the results in the first vector.
+func vectormap_(e Environment, args ...Expr) Expr {
+	r := vectormap(e, args...)
+	if v, ok := r.(Vector); ok {
+		copy(args[1].(Vector), v)
+		return args[1]
+	}
+	return r
+}
+
+//(vector-partition pred vector) returns a list of a vector with the elements
+//satisfying pred followed by the others, and the number of elements that do.
+func vectorpartition(e Environment, args ...Expr) Expr {
+	pred, err := procArg("vector-partition", args, 0)
+	if err != nil {
+		return err
+	}
+	v, err := vectorArg("vector-partition", args, 1)
+	if err != nil {
+		return err
+	}
+	in, out := []Expr{}, []Expr{}
+	for _, x := range v {
+		ok, err := callPred(e, pred, x)
+		if err != nil {
+			return err
+		}
+		if ok {
+			in = append(in, x)
+		} else {
+			out = append(out, x)
+		}
+	}
+	return SliceToExprList([]Expr{Vector(append(in, out...)), Number(len(in))})
+}
+
 func vectorref(e Environment, args ...Expr) Expr {
 	v, ok := args[0].(Vector)
 	if !ok {
 		return Error{"vector-ref: Argument 1 is not a vector."}
 	}
-	i, ok := args[1].(Number)
-	if !ok {
-		return Error{"vector-ref: Argument 2 is not a number."}
+	i, err := indexArg("vector-ref", args, 1, len(v)-1)
+	if err != nil {
+		return err
+	}
+	return v[i]
+}
+
+//(vector-reverse! vector [start [end]])
+func vectorreverse(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("vector-reverse!", args, 0)
+	if err != nil {
+		return err
 	}
-	return []Expr(v)[int(i)]
+	reverseExprs(v)
+	return args[0]
+}
+
+//(vector-reverse-copy vector [start [end]])
+func vectorreversecopy(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("vector-reverse-copy", args, 0)
+	if err != nil {
+		return err
+	}
+	ret := append(Vector{}, v...)
+	reverseExprs(ret)
+	return ret
 }
 
 func vectorset(e Environment, args ...Expr) Expr {
@@ -70,10 +479,112 @@ func vectorset(e Environment, args ...Expr) Expr {
 	if !ok {
 		return Error{"vector-set!: Argument 1 is not a vector."}
 	}
-	i, ok := args[1].(Number)
-	if !ok {
-		return Error{"vector-set!: Argument 2 is not a number."}
+	i, err := indexArg("vector-set!", args, 1, len(v)-1)
+	if err != nil {
+		return err
 	}
-	[]Expr(v)[int(i)] = args[2]
+	v[i] = args[2]
 	return v
 }
+
+//(vector-skip pred vector ...) returns the index of the first elements which
+//do not satisfy pred.
+func vectorskip(e Environment, args ...Expr) Expr {
+	return vectorIndex(e, "vector-skip", false, false, args)
+}
+
+//(vector-skip-right pred vector ...)
+func vectorskipright(e Environment, args ...Expr) Expr {
+	return vectorIndex(e, "vector-skip-right", false, true, args)
+}
+
+//(vector-swap! vector i j)
+func vectorswap(e Environment, args ...Expr) Expr {
+	v, err := vectorArg("vector-swap!", args, 0)
+	if err != nil {
+		return err
+	}
+	i, err := indexArg("vector-swap!", args, 1, len(v)-1)
+	if err != nil {
+		return err
+	}
+	j, err := indexArg("vector-swap!", args, 2, len(v)-1)
+	if err != nil {
+		return err
+	}
+	v[i], v[j] = v[j], v[i]
+	return v
+}
+
+//(vector->list vector [start [end]])
+func vectortolist(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("vector->list", args, 0)
+	if err != nil {
+		return err
+	}
+	return SliceToExprList(v)
+}
+
+//(vector->string vector [start [end]])
+func vectortostring(e Environment, args ...Expr) Expr {
+	v, err := vectorRangeArg("vector->string", args, 0)
+	if err != nil {
+		return err
+	}
+	r := make([]rune, len(v))
+	for i, x := range v {
+		c, ok := x.(Character)
+		if !ok {
+			return Error{"vector->string: All elements of the vector must be characters."}
+		}
+		r[i] = rune(c)
+	}
+	return runesToString(r)
+}
+
+//(vector-unfold f length seed ...) calls (f index seed ...), which returns the
+//element and the next seeds as a list, or just the element if there are no
+//seeds.
+func vectorunfold(e Environment, args ...Expr) Expr {
+	return vectorUnfold(e, "vector-unfold", false, args)
+}
+
+//(vector-unfold-right f length seed ...) is vector-unfold filling the vector
+//from right to left.
+func vectorunfoldright(e Environment, args ...Expr) Expr {
+	return vectorUnfold(e, "vector-unfold-right", true, args)
+}
+
+func vectorUnfold(e Environment, name string, right bool, args []Expr) Expr {
+	f, err := procArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	n, err := countArg(name, args, 1)
+	if err != nil {
+		return err
+	}
+	seeds := args[2:]
+	ret := make(Vector, n)
+	for j := range ret {
+		i := j
+		if right {
+			i = n - 1 - j
+		}
+		r := callProc(e, f, append([]Expr{Number(i)}, seeds...)...)
+		if isError(r) {
+			return r
+		}
+		if len(seeds) == 0 {
+			ret[i] = r
+			continue
+		}
+		l, ok := r.(ExprList)
+		if !ok || l.Length() != len(seeds)+1 {
+			return Error{name + ": The function must return a list of the element and the next seeds."}
+		}
+		vals := ExprListToSlice(l)
+		ret[i], seeds = vals[0], vals[1:]
+	}
+	return ret
+}
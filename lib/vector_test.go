@@ -0,0 +1,66 @@
+package goscheme
+
+import (
+	"strings"
+	"testing"
+)
+
+//No index given to a vector procedure can panic the interpreter, only return
+//an error.
+func TestVectorBounds(t *testing.T) {
+	templates := []string{
+		"(vector-ref (vector 1 2) I)",
+		"(vector-set! (vector 1 2) I 0)",
+		"(subvector (vector 1 2) I 2)",
+		"(subvector (vector 1 2) 0 I)",
+		"(vector-copy (vector 1 2) I)",
+		"(vector-copy (vector 1 2) 0 I)",
+		"(vector-copy! (vector 1 2) I (vector 1))",
+		"(vector-copy! (vector 1 2) 0 (vector 1 2) I)",
+		"(vector-swap! (vector 1 2) 0 I)",
+		"(vector-fill! (vector 1 2) 0 I)",
+		"(vector->list (vector 1 2) I)",
+		"(vector->string (vector #\\a) I)",
+		"(vector-sort! (vector 1 2) < I)",
+		"(vector-binary-search (vector 1 2) 1 - I)",
+		"(reverse-vector->list (vector 1 2) 0 I)",
+	}
+	for _, tmpl := range templates {
+		for _, i := range []string{"-1", "3", "1.5", "1e300", "(/ 0. 0.)", "'a"} {
+			src := strings.Replace(tmpl, "I", i, 1)
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s panics: %v", src, r)
+					}
+				}()
+				if _, ok := evalString(t, src).(Error); !ok {
+					t.Errorf("%s does not return an error", src)
+				}
+			}()
+		}
+	}
+}
+
+func TestVectorProcedures(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(define overlap (vector 1 2 3 4 5)) (vector-copy! overlap 1 overlap 0 3) overlap", "#(1 1 2 3 5)"},
+		{"(vector-map + #(1 2) #(10 20 30))", "#(11 22)"},
+		{"(vector-fold (lambda (s x) (cons x s)) '() #(1 2 3))", "(3 2 1)"},
+		{"(vector-fold-right (lambda (s x) (cons x s)) '() #(1 2 3))", "(1 2 3)"},
+		{"(vector-index even? #(1 3 4))", "2"},
+		{"(vector-count < #(1 5) #(2 3))", "1"},
+		{"(vector-cumulate + 0 #(1 2 3))", "#(1 3 6)"},
+		{"(vector->string #(#\\a #\\b) 1)", `"b"`},
+		{"(vector->string #(#\\a 1))", "vector->string: All elements of the vector must be characters."},
+		{"(vector-copy! (vector 1 2) 1 #(1 2))", "vector-copy!: Not enough room in destination vector."},
+		{"(vector-ref #(1 2) 2)", "vector-ref: Index 2 is out of range."},
+	}
+	for _, test := range tests {
+		if got := Sprint(evalString(t, test.src)); got != test.want {
+			t.Errorf("%s = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
@@ -22,23 +22,43 @@ import (
 	"flag"
 	"fmt"
 	"github.com/jackbister/goscheme/lib"
+	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 )
 
+//pathFlag collects the directories given with -L.
+type pathFlag []string
+
+func (p *pathFlag) String() string {
+	return strings.Join(*p, string(filepath.ListSeparator))
+}
+
+func (p *pathFlag) Set(dir string) error {
+	*p = append(*p, dir)
+	return nil
+}
+
 func main() {
+	var paths pathFlag
 	maxp := flag.Int("cores", runtime.NumCPU(), "Sets the number of CPU cores that the interpreter may use. If not given, all available cores will be used.")
 	interactive := flag.Bool("i", false, "Enters interactive mode after executing the given files. If no files are given this is the default.")
+	quiet := flag.Bool("q", false, "Quiet mode. Loading a file does not print its name or the values of the expressions in it.")
+	flag.Var(&paths, "L", "Adds a directory to the load path and the library path. May be given more than once. GOSCHEME_PATH adds directories after these.")
 	flag.Parse()
 	runtime.GOMAXPROCS(*maxp)
 	goscheme.GlobalEnv = goscheme.StandardEnv()
+	goscheme.AddLoadPath(paths...)
+	if *quiet {
+		goscheme.GlobalEnv.Local["load-verbose"] = goscheme.Boolean(false)
+	}
 	for _, a := range flag.Args() {
-		eval("(load (quote " + a + "))")
+		eval("(load (quote " + a + "))", *quiet)
 	}
 	if *interactive || len(flag.Args()) == 0 {
 		readLoop()
 	}
+	goscheme.FlushOutput()
 }
 
 var replFuncs = map[string]func(){
@@ -49,29 +69,27 @@ var replFuncs = map[string]func(){
 func readLoop() {
 	replStart()
 	for {
+		goscheme.FlushOutput()
 		fmt.Print(">>")
 		in := readLine()
 		in = strings.Trim(in, " \r\n")
 		if replFuncs[in] != nil {
 			replFuncs[in]()
 		} else {
-			eval(in)
+			eval(in, false)
 		}
 	}
 }
 
-func eval(s string) {
-	t := goscheme.Tokenize(s)
-	p := goscheme.Parse(&t, true)
-	r := goscheme.Eval(p, goscheme.GlobalEnv)
-	if f, ok := r.(goscheme.Number); ok {
-		fs := strconv.FormatFloat(float64(f), 'f', -1, 64)
-		r = goscheme.Symbol(fs)
-	}
-	if _, ok := r.(goscheme.Error); ok {
-		fmt.Print("Error: ")
-	}
-	if s, ok := r.(goscheme.Symbol); !ok || string(s) != "" {
-		fmt.Println(r)
+//eval evaluates s in GlobalEnv and prints the results, or only the errors if
+//quiet is true.
+func eval(s string, quiet bool) {
+	for _, p := range goscheme.ParseString(s) {
+		r := goscheme.Eval(p, goscheme.GlobalEnv)
+		if _, ok := r.(goscheme.Error); ok {
+			goscheme.Println("Error: " + goscheme.Sprint(r))
+		} else if s, ok := r.(goscheme.Symbol); !quiet && (!ok || s.Name() != "") {
+			goscheme.Println(goscheme.Sprint(r))
+		}
 	}
 }
@@ -3,19 +3,19 @@
 package main
 
 import (
+	"github.com/jackbister/goscheme/lib"
 	"github.com/jackbister/goscheme/lib/terminal"
-	"os"
 )
 
 var t *terminal.Terminal
 
 func exit() {
-	t.ReleaseFromStdInOut()
-	os.Exit(0)
+	goscheme.Exit(0)
 }
 
 func replStart() {
 	t, _ = terminal.NewWithStdInOut()
+	goscheme.OnExit(func() { t.ReleaseFromStdInOut() })
 }
 
 func readLine() string {
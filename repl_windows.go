@@ -2,13 +2,14 @@ package main
 
 import (
 	"bufio"
+	"github.com/jackbister/goscheme/lib"
 	"os"
 )
 
 var reader *bufio.Reader
 
 func exit() {
-	os.Exit(0)
+	goscheme.Exit(0)
 }
 
 func replStart() {
@@ -46,9 +46,6 @@
 	(if (not (and (char? x) (char? y))) (error "char-ci>=?: Argument is not a char.")
 	  (>= (char->integer (char-downcase x)) (char->integer (char-downcase y))))))
 
-(define string=? (lambda (x y)
-	(eqv? x y)))
-
 (define string<? (lambda (x y)
 	(begin
 	  (define xli (string->list x))
@@ -80,7 +77,7 @@
 	    (not (some? (lambda (z) (eqv? z #f)) (map char>=? xli yli))))))))
 
 (define string-ci=? (lambda (x y)
-	(eqv? (map char-downcase (string->list x)) (map char-downcase (string->list y)))))
+	(equal? (map char-downcase (string->list x)) (map char-downcase (string->list y)))))
 
 (define string-ci<? (lambda (x y)
 	(begin
@@ -2,7 +2,7 @@
 	(begin
 	  (define sfiter (lambda (file)
 		(begin
-		  (if (not (char-ready? file))
+		  (if (eof-object? (peek-char file))
 		    '()
 		    (cons (read-char file) (sfiter file))))))
 	  (sfiter (open-input-file fn)))))
@@ -4,14 +4,10 @@
 (define string (lambda chars
 	(list->string chars)))
 
-(define string-append (lambda strings
-	(list->string (concatenate (map string->list strings)))))
-
-(define string-length (lambda (s)
-	(length (string->list s))))
-
-(define string-ref (lambda (s k)
-	(list-ref (string->list s) k)))
-
-(define substring (lambda (s start end)
-	(list->string (take (list-tail (string->list s) start) (- end start)))))
+;String cursors are indexes, so they are compared like numbers.
+(define string-cursor? integer?)
+(define string-cursor=? =)
+(define string-cursor<? <)
+(define string-cursor>? >)
+(define string-cursor<=? <=)
+(define string-cursor>=? >=)